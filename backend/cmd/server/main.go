@@ -1,25 +1,181 @@
 package main
 
 import (
+    "context"
+    "fmt"
     "log"
+    "net"
+    "net/http"
+    "os"
+    "os/signal"
+    "strconv"
+    "sync"
+    "syscall"
+    "time"
     "github.com/gin-gonic/gin"
     "backend/internal/api"
+    "backend/internal/authtoken"
     "backend/internal/database"
+    "backend/internal/loginguard"
+    "backend/internal/mail"
+    "backend/internal/oidc"
     "backend/internal/config"
+    "backend/internal/services"
+    "backend/internal/storage"
 )
 
 func main() {
 
-    config.LoadConfig()
+    if err := config.LoadConfig(); err != nil {
+        log.Fatalf("Could not load config: %v", err)
+    }
+
+    if len(os.Args) > 1 {
+        switch os.Args[1] {
+        case "migrate":
+            runMigrate(os.Args[2:])
+            return
+        case "unlock":
+            runUnlock(os.Args[2:])
+            return
+        case "config":
+            // print the effective config, secrets redacted
+            dump, err := config.GetConfig().Redacted()
+            if err != nil {
+                log.Fatalf("Could not print config: %v", err)
+            }
+            fmt.Print(dump)
+            return
+        }
+    }
+
+    authtoken.Init()
 
     database.Connect()
+
+    loginguard.Init()
+
+    storage.Init()
+
+    mail.Init()
+
+    oidc.Init()
+
+    services.Init()
+
+    // SIGINT or SIGTERM start a graceful shutdown
+    ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+    defer stop()
+
+    // publish and expire jobs on schedule, delete uploads no application uses and
+    // old login attempts
+    schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+    var schedulers sync.WaitGroup
+    schedulers.Add(3)
+    go func() {
+        defer schedulers.Done()
+        services.RunJobScheduler(schedulerCtx, time.Minute)
+    }()
+    go func() {
+        defer schedulers.Done()
+        services.RunUploadSweeper(schedulerCtx, time.Hour)
+    }()
+    go func() {
+        defer schedulers.Done()
+        loginguard.Get().RunPurger(schedulerCtx, time.Hour, config.GetConfig().LoginGuard.AuditRetention)
+    }()
+    schedulerDone := make(chan struct{})
+    go func() {
+        schedulers.Wait()
+        close(schedulerDone)
+    }()
     
-    router := gin.Default()
+    // probes poll every second or so, leave them out of the request log
+    router := gin.New()
+    router.Use(gin.LoggerWithConfig(gin.LoggerConfig{SkipPaths: []string{"/healthz", "/readyz"}}), gin.Recovery())
+
+    // The client address decides login backoff and is recorded with sessions, only
+    // take it from X-Forwarded-For when a known proxy set the header
+    if err := router.SetTrustedProxies(config.GetConfig().Server.TrustedProxies); err != nil {
+        log.Fatalf("Invalid trusted proxies: %v", err)
+    }
 
     api.SetupRoutes(router)
     
-    log.Println("Starting server on :8080")
-    if err := router.Run(":8080"); err != nil {
+    cfg := config.GetConfig().Server
+    server := &http.Server{
+        Addr:              net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+        Handler:           router,
+        ReadHeaderTimeout: cfg.ReadTimeout,
+        ReadTimeout:       cfg.ReadTimeout,
+        WriteTimeout:      cfg.WriteTimeout,
+        IdleTimeout:       cfg.IdleTimeout,
+    }
+
+    // database details stay off the public listener, see api.SetupProbeRoutes
+    var probeServer *http.Server
+    if cfg.ProbeAddr != "" {
+        probeRouter := gin.New()
+        probeRouter.Use(gin.Recovery())
+        api.SetupProbeRoutes(probeRouter)
+        probeServer = &http.Server{
+            Addr:              cfg.ProbeAddr,
+            Handler:           probeRouter,
+            ReadHeaderTimeout: cfg.ReadTimeout,
+            ReadTimeout:       cfg.ReadTimeout,
+            WriteTimeout:      cfg.WriteTimeout,
+            IdleTimeout:       cfg.IdleTimeout,
+        }
+    }
+
+    serverErr := make(chan error, 2)
+    if probeServer != nil {
+        go func() {
+            log.Printf("Starting probe listener on %s", probeServer.Addr)
+            serverErr <- probeServer.ListenAndServe()
+        }()
+    }
+    go func() {
+        var err error
+        if cfg.TLSCertFile != "" {
+            log.Printf("Starting server on %s with TLS", server.Addr)
+            err = server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+        } else {
+            log.Printf("Starting server on %s", server.Addr)
+            err = server.ListenAndServe()
+        }
+        serverErr <- err
+    }()
+
+    select {
+    case err := <-serverErr:
         log.Fatalf("Could not start server: %s\n", err)
+    case <-ctx.Done():
+    }
+    stop() // a second signal kills the process right away
+
+    // Stop accepting connections and let in-flight requests finish, then stop the
+    // schedulers and close the database they were using
+    log.Printf("Shutting down, waiting up to %s for requests to finish", cfg.ShutdownTimeout)
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+    defer cancel()
+
+    if err := server.Shutdown(shutdownCtx); err != nil {
+        log.Printf("Could not drain requests: %v", err)
+    }
+    if probeServer != nil {
+        if err := probeServer.Shutdown(shutdownCtx); err != nil {
+            log.Printf("Could not stop the probe listener: %v", err)
+        }
+    }
+    stopScheduler()
+    select {
+    case <-schedulerDone:
+    case <-shutdownCtx.Done():
+        log.Printf("Schedulers did not stop in time")
+    }
+    if err := database.GetDB().Close(); err != nil {
+        log.Printf("Could not close the database: %v", err)
     }
-}
\ No newline at end of file
+    log.Println("Server stopped")
+}
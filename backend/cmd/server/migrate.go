@@ -0,0 +1,64 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+    "backend/internal/database"
+)
+
+const migrateUsage = "usage: server migrate up | down [steps] | status"
+
+// runMigrate implements `server migrate up|down|status` against the configured database.
+func runMigrate(args []string) {
+    if len(args) == 0 {
+        log.Fatal(migrateUsage)
+    }
+
+    database.Connect()
+    db := database.GetDB()
+    defer db.Close()
+
+    ctx := context.Background()
+
+    switch args[0] {
+    case "up":
+        if err := database.MigrateUp(ctx, db); err != nil {
+            log.Fatalf("Migration failed: %v", err)
+        }
+        log.Println("Database is up to date")
+
+    case "down":
+        steps := 1
+        if len(args) > 1 {
+            var err error
+            if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+                log.Fatal(migrateUsage)
+            }
+        }
+        if err := database.MigrateDown(ctx, db, steps); err != nil {
+            log.Fatalf("Migration failed: %v", err)
+        }
+
+    case "status":
+        states, err := database.MigrationStatus(ctx, db)
+        if err != nil {
+            log.Fatalf("Could not read migration status: %v", err)
+        }
+        for _, state := range states {
+            status := "pending"
+            if state.AppliedAt != nil {
+                status = "applied " + state.AppliedAt.Format("2006-01-02 15:04:05")
+            }
+            if state.Modified {
+                status += " (modified since applied)"
+            }
+            fmt.Fprintf(os.Stdout, "%04d_%-45s %s\n", state.Version, state.Name, status)
+        }
+
+    default:
+        log.Fatal(migrateUsage)
+    }
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+    "context"
+    "log"
+    "backend/internal/config"
+    "backend/internal/database"
+    "backend/internal/loginguard"
+)
+
+const unlockUsage = "usage: server unlock <email>"
+
+// runUnlock implements `server unlock <email>`, clearing the failed logins of an
+// account. Only operators with access to the server and its database can run it.
+func runUnlock(args []string) {
+    if len(args) != 1 {
+        log.Fatal(unlockUsage)
+    }
+    // The memory store lives in the server process, this process cannot reach it
+    if backend := config.GetConfig().LoginGuard.Backend; backend != "postgres" {
+        log.Fatalf("Cannot unlock with the %s login guard, restarting the server clears it", backend)
+    }
+
+    database.Connect()
+    defer database.GetDB().Close()
+    loginguard.Init()
+
+    if err := loginguard.Get().Unlock(context.Background(), args[0]); err != nil {
+        log.Fatalf("Unlock failed: %v", err)
+    }
+    log.Printf("Unlocked logins of %s", args[0])
+}
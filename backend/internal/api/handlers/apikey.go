@@ -0,0 +1,58 @@
+package handlers
+
+import (
+    "backend/internal/models"
+    "backend/internal/services"
+    "errors"
+    "net/http"
+    "github.com/gin-gonic/gin"
+)
+
+// CreateAPIKeyH creates an API key; the key is only shown in this response
+func CreateAPIKeyH(ctx *gin.Context) {
+    var req models.APIKey
+    if err := ctx.ShouldBindJSON(&req); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Invalid input", "error": err.Error()})
+        return
+    }
+
+    key, err := services.CreateAPIKey(ctx, &req)
+    if err != nil {
+        switch {
+        case errors.Is(err, services.ErrInvalidAPIKeyRequest):
+            ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": err.Error()})
+        case errors.Is(err, services.ErrOrganizationDoesNotExist), errors.Is(err, services.ErrInsufficientRole):
+            ctx.JSON(http.StatusForbidden, gin.H{"msg": "Forbidden", "error": err.Error()})
+        default:
+            ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Failed to create api key", "error": err.Error()})
+        }
+        return
+    }
+
+    ctx.JSON(http.StatusCreated, key)
+}
+
+// ListAPIKeysH lists the user's API keys without the keys themselves
+func ListAPIKeysH(ctx *gin.Context) {
+    keys, err := services.ListAPIKeys(ctx)
+    if err != nil {
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Failed to retrieve api keys", "error": err.Error()})
+        return
+    }
+
+    ctx.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKeyH stops one of the user's API keys from working
+func RevokeAPIKeyH(ctx *gin.Context) {
+    if err := services.RevokeAPIKey(ctx, ctx.Param("keyId")); err != nil {
+        if errors.Is(err, services.ErrAPIKeyDoesNotExist) {
+            ctx.JSON(http.StatusNotFound, gin.H{"msg": "Not found", "error": err.Error()})
+            return
+        }
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Failed to revoke api key", "error": err.Error()})
+        return
+    }
+
+    ctx.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+    "backend/internal/models"
+    "backend/internal/services"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "github.com/gin-gonic/gin"
+)
+
+// SubmitApplicationH lets a candidate apply to an active job without logging in
+func SubmitApplicationH(ctx *gin.Context) {
+    var application models.Application
+    if err := ctx.ShouldBindJSON(&application); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    if err := services.SubmitApplication(ctx, ctx.Param("shareToken"), &application); err != nil {
+        switch {
+        case errors.Is(err, services.ErrJobDoesNotExist):
+            ctx.JSON(http.StatusNotFound, gin.H{"message": "Job not found"})
+        case errors.Is(err, services.ErrInvalidApplication),
+            errors.Is(err, services.ErrJobNotAcceptingApplications):
+            ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": err.Error()})
+        default:
+            ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Failed to submit application", "error": err.Error()})
+        }
+        return
+    }
+
+    ctx.JSON(http.StatusCreated, application)
+}
+
+// parseApplicationFilter reads answer filters from query maps such as
+// ?min[Q_Experience]=3&contains[Q_Skills]=Python,SQL&match[Q_Education]=computer
+func parseApplicationFilter(ctx *gin.Context) (services.ApplicationFilter, error) {
+    filter := services.ApplicationFilter{
+        Min:      map[string]float64{},
+        Max:      map[string]float64{},
+        Equals:   ctx.QueryMap("eq"),
+        Contains: map[string][]string{},
+        Match:    ctx.QueryMap("match"),
+    }
+
+    for bound, target := range map[string]map[string]float64{"min": filter.Min, "max": filter.Max} {
+        for key, value := range ctx.QueryMap(bound) {
+            number, err := strconv.ParseFloat(value, 64)
+            if err != nil {
+                return filter, fmt.Errorf("%w: %s[%s] must be a number", services.ErrInvalidApplicationFilter, bound, key)
+            }
+            target[key] = number
+        }
+    }
+
+    for key, value := range ctx.QueryMap("contains") {
+        for _, option := range strings.Split(value, ",") {
+            if option = strings.TrimSpace(option); option != "" {
+                filter.Contains[key] = append(filter.Contains[key], option)
+            }
+        }
+    }
+
+    return filter, nil
+}
+
+// ListApplicationsH lists the applications of one of the user's jobs, filtered by answers
+func ListApplicationsH(ctx *gin.Context) {
+    filter, err := parseApplicationFilter(ctx)
+    if err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": err.Error()})
+        return
+    }
+
+    applications, err := services.ListApplications(ctx, ctx.Param("jobId"), filter)
+    if err != nil {
+        if errors.Is(err, services.ErrJobDoesNotExist) {
+            ctx.JSON(http.StatusNotFound, gin.H{"message": "Job not found"})
+            return
+        }
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Failed to retrieve applications", "error": err.Error()})
+        return
+    }
+
+    ctx.JSON(http.StatusOK, applications)
+}
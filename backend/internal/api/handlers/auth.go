@@ -1,11 +1,26 @@
 package handlers
 
 import (
+    "errors"
+    "math"
     "net/http"
+    "strconv"
+    "strings"
     "github.com/gin-gonic/gin"
+    "backend/internal/authtoken"
+    "backend/internal/config"
+    "backend/internal/loginguard"
+    "backend/internal/oidc"
     "backend/internal/services"
 )
 
+// sessionMeta describes the client opening a session
+func sessionMeta(ctx *gin.Context) services.SessionMeta {
+    return services.SessionMeta{
+        UserAgent: ctx.Request.UserAgent(),
+        IPAddress: ctx.ClientIP(),
+    }
+}
 
 func LoginH(ctx *gin.Context) {
     var loginReq services.LoginRequest
@@ -14,16 +29,123 @@ func LoginH(ctx *gin.Context) {
         ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Invalid input", "error": err.Error()})
         return
     }
+    loginReq.Meta = sessionMeta(ctx)
+
+    token, challenge, err := services.Login(ctx, &loginReq)
+    if err != nil {
+        var locked *loginguard.LockedError
+        switch {
+        case errors.As(err, &locked):
+            ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+            ctx.JSON(http.StatusTooManyRequests, gin.H{"msg": "Too many requests", "error": err.Error()})
+        case errors.Is(err, services.ErrInvalidCredentials):
+            ctx.JSON(http.StatusUnauthorized, gin.H{"msg": "Unauthorized", "error": err.Error()})
+        default:
+            ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Could not log in", "error": err.Error()})
+        }
+        return
+    }
+    if challenge != nil {
+        ctx.JSON(http.StatusOK, gin.H{"mfa_required": true, "challenge": challenge})
+        return
+    }
+
+    ctx.JSON(http.StatusOK, gin.H{"token": token})
+}
+
+// LoginMFAH completes a login challenge with an authenticator or recovery code
+func LoginMFAH(ctx *gin.Context) {
+    var mfaReq services.MFALoginRequest
+    if err := ctx.ShouldBindJSON(&mfaReq); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Invalid input", "error": err.Error()})
+        return
+    }
+    mfaReq.Meta = sessionMeta(ctx)
 
-    token, err := services.Login(ctx, &loginReq)
+    token, err := services.CompleteMFALogin(ctx, &mfaReq)
     if err != nil {
-        ctx.JSON(http.StatusUnauthorized, gin.H{"msg": "Unauthorized", "error": err.Error()})
+        var locked *loginguard.LockedError
+        switch {
+        case errors.As(err, &locked):
+            ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+            ctx.JSON(http.StatusTooManyRequests, gin.H{"msg": "Too many requests", "error": err.Error()})
+        case errors.Is(err, services.ErrInvalidMFAChallenge) || errors.Is(err, services.ErrInvalidMFACode):
+            ctx.JSON(http.StatusUnauthorized, gin.H{"msg": "Unauthorized", "error": err.Error()})
+        default:
+            ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Could not log in", "error": err.Error()})
+        }
         return
     }
 
     ctx.JSON(http.StatusOK, gin.H{"token": token})
 }
 
+// ssoLoginCookie carries the binding of a single sign-on login from start to callback
+const ssoLoginCookie = "sso_login"
+
+// setSSOLoginCookie sets, or with an empty value clears, the binding cookie. It is
+// HttpOnly and only sent to the SSO endpoints; Lax lets the frontend's callback
+// request carry it while pages of other sites cannot.
+func setSSOLoginCookie(ctx *gin.Context, value string) {
+    maxAge := 0 // until the browser closes, the login itself expires sooner
+    if value == "" {
+        maxAge = -1
+    }
+    secure := ctx.Request.TLS != nil || strings.HasPrefix(config.GetConfig().AppURL, "https://")
+    ctx.SetSameSite(http.SameSiteLaxMode)
+    ctx.SetCookie(ssoLoginCookie, value, maxAge, "/api/sso", "", secure, true)
+}
+
+// StartSSOLoginH returns the identity provider URL to send the user to
+func StartSSOLoginH(ctx *gin.Context) {
+    start, err := services.StartSSOLogin(ctx)
+    if err != nil {
+        if errors.Is(err, services.ErrSSODisabled) {
+            ctx.JSON(http.StatusNotFound, gin.H{"msg": "Not found", "error": err.Error()})
+            return
+        }
+        ctx.JSON(http.StatusBadGateway, gin.H{"msg": "Could not start single sign-on", "error": err.Error()})
+        return
+    }
+
+    setSSOLoginCookie(ctx, start.Binding)
+    ctx.JSON(http.StatusOK, start)
+}
+
+// SSOCallbackH completes a single sign-on login with the code and state the
+// identity provider redirected back with
+func SSOCallbackH(ctx *gin.Context) {
+    var callbackReq services.SSOCallbackRequest
+    if err := ctx.ShouldBindJSON(&callbackReq); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Invalid input", "error": err.Error()})
+        return
+    }
+    callbackReq.Meta = sessionMeta(ctx)
+    callbackReq.Binding, _ = ctx.Cookie(ssoLoginCookie)
+
+    // The state works once whatever happens next, so its binding is no longer needed
+    setSSOLoginCookie(ctx, "")
+    token, challenge, err := services.CompleteSSOLogin(ctx, &callbackReq)
+    if err != nil {
+        switch {
+        case errors.Is(err, services.ErrSSODisabled):
+            ctx.JSON(http.StatusNotFound, gin.H{"msg": "Not found", "error": err.Error()})
+        case errors.Is(err, services.ErrInvalidSSOState), errors.Is(err, oidc.ErrExchange), errors.Is(err, oidc.ErrInvalidIDToken):
+            ctx.JSON(http.StatusUnauthorized, gin.H{"msg": "Unauthorized", "error": err.Error()})
+        case errors.Is(err, services.ErrSSOEmailNotVerified), errors.Is(err, services.ErrSSODomainNotAllowed):
+            ctx.JSON(http.StatusForbidden, gin.H{"msg": "Forbidden", "error": err.Error()})
+        default:
+            ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Could not log in", "error": err.Error()})
+        }
+        return
+    }
+    if challenge != nil {
+        ctx.JSON(http.StatusOK, gin.H{"mfa_required": true, "challenge": challenge})
+        return
+    }
+
+    ctx.JSON(http.StatusOK, gin.H{"token": token})
+}
 
 func RegisterH(ctx *gin.Context) {
     var registerReq services.RegisterRequest
@@ -31,6 +153,7 @@ func RegisterH(ctx *gin.Context) {
         ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Invalid input", "error": err.Error()})
         return
     }
+    registerReq.Meta = sessionMeta(ctx)
 
     authResponse, err := services.Register(ctx, &registerReq)
     if err != nil {
@@ -43,4 +166,146 @@ func RegisterH(ctx *gin.Context) {
     }
 
     ctx.JSON(http.StatusCreated, authResponse)
-}
\ No newline at end of file
+}
+
+// RefreshTokenH trades a refresh token for a new token pair
+func RefreshTokenH(ctx *gin.Context) {
+    var refreshReq services.RefreshRequest
+    if err := ctx.ShouldBindJSON(&refreshReq); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Invalid input", "error": err.Error()})
+        return
+    }
+
+    tokens, err := services.RefreshSession(ctx, &refreshReq)
+    if err != nil {
+        if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+            ctx.JSON(http.StatusUnauthorized, gin.H{"msg": "Unauthorized", "error": err.Error()})
+            return
+        }
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Could not refresh token", "error": err.Error()})
+        return
+    }
+
+    ctx.JSON(http.StatusOK, tokens)
+}
+
+// LogoutH ends the session of the current access token
+func LogoutH(ctx *gin.Context) {
+    if err := services.Logout(ctx); err != nil && !errors.Is(err, services.ErrSessionDoesNotExist) {
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Could not log out", "error": err.Error()})
+        return
+    }
+
+    ctx.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// ListSessionsH lists the user's active sessions
+func ListSessionsH(ctx *gin.Context) {
+    sessions, err := services.ListSessions(ctx)
+    if err != nil {
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Could not retrieve sessions", "error": err.Error()})
+        return
+    }
+
+    ctx.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSessionH ends one of the user's sessions
+func RevokeSessionH(ctx *gin.Context) {
+    if err := services.RevokeSession(ctx, ctx.Param("sessionId")); err != nil {
+        if errors.Is(err, services.ErrSessionDoesNotExist) {
+            ctx.JSON(http.StatusNotFound, gin.H{"msg": "Not found", "error": err.Error()})
+            return
+        }
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Could not revoke session", "error": err.Error()})
+        return
+    }
+
+    ctx.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// ForgotPasswordH mails a password reset link if the email has an account
+func ForgotPasswordH(ctx *gin.Context) {
+    var forgotReq services.ForgotPasswordRequest
+    if err := ctx.ShouldBindJSON(&forgotReq); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Invalid input", "error": err.Error()})
+        return
+    }
+
+    forgotReq.IPAddress = ctx.ClientIP()
+
+    if err := services.RequestPasswordReset(ctx, &forgotReq); err != nil {
+        var locked *loginguard.LockedError
+        if errors.As(err, &locked) {
+            ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+            ctx.JSON(http.StatusTooManyRequests, gin.H{"msg": "Too many requests", "error": err.Error()})
+            return
+        }
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Could not request a password reset", "error": err.Error()})
+        return
+    }
+
+    // Same answer whether or not the account exists, or the email could be sent
+    ctx.JSON(http.StatusAccepted, gin.H{"message": "If the email has an account, a reset link was sent to it"})
+}
+
+// ResetPasswordH sets a new password with a reset token
+func ResetPasswordH(ctx *gin.Context) {
+    var resetReq services.ResetPasswordRequest
+    if err := ctx.ShouldBindJSON(&resetReq); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Invalid input", "error": err.Error()})
+        return
+    }
+
+    if err := services.ResetPassword(ctx, &resetReq); err != nil {
+        if errors.Is(err, services.ErrInvalidAccountToken) {
+            ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": err.Error()})
+            return
+        }
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Could not reset password", "error": err.Error()})
+        return
+    }
+
+    ctx.JSON(http.StatusOK, gin.H{"message": "Password reset successfully, log in again"})
+}
+
+// VerifyEmailH confirms the user's email address with a verification token
+func VerifyEmailH(ctx *gin.Context) {
+    var verifyReq services.VerifyEmailRequest
+    if err := ctx.ShouldBindJSON(&verifyReq); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Invalid input", "error": err.Error()})
+        return
+    }
+
+    if err := services.VerifyEmail(ctx, &verifyReq); err != nil {
+        if errors.Is(err, services.ErrInvalidAccountToken) {
+            ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": err.Error()})
+            return
+        }
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Could not verify email", "error": err.Error()})
+        return
+    }
+
+    ctx.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerificationEmailH mails the user a new verification link
+func ResendVerificationEmailH(ctx *gin.Context) {
+    if err := services.ResendVerificationEmail(ctx); err != nil {
+        if errors.Is(err, services.ErrEmailAlreadyVerified) {
+            ctx.JSON(http.StatusConflict, gin.H{"msg": "Conflict", "error": err.Error()})
+            return
+        }
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Could not send verification email", "error": err.Error()})
+        return
+    }
+
+    ctx.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
+
+// JWKSH publishes the public keys access tokens are signed with, so other
+// services can verify them
+func JWKSH(ctx *gin.Context) {
+    ctx.Header("Cache-Control", "public, max-age=300")
+    ctx.JSON(http.StatusOK, authtoken.Get().JWKS())
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+    "backend/internal/database"
+    "backend/internal/version"
+    "context"
+    "fmt"
+    "log"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// databaseProbeTimeout bounds the ping, a probe must answer even when the database hangs
+const databaseProbeTimeout = 2 * time.Second
+
+// DatabaseHealthH pings the database and reports the connection pool, 503 when
+// the database is unavailable
+func DatabaseHealthH(ctx *gin.Context) {
+    probeCtx, cancel := context.WithTimeout(ctx.Request.Context(), databaseProbeTimeout)
+    defer cancel()
+
+    health, err := database.CheckHealth(probeCtx)
+    if err != nil {
+        // the error names hosts and addresses, keep it out of the public response
+        log.Printf("Database health check failed: %v", err)
+        ctx.JSON(http.StatusServiceUnavailable, health)
+        return
+    }
+
+    ctx.JSON(http.StatusOK, health)
+}
+
+// HealthzH answers as long as the process serves requests, without touching the
+// database, so a slow database does not get the server restarted
+func HealthzH(ctx *gin.Context) {
+    ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ReadyzH reports whether the server can take traffic: the database answers and
+// every migration of this build has been applied. 503 otherwise. A schema ahead of
+// this build, migrated by a newer release during a rollout, still counts as ready
+func ReadyzH(ctx *gin.Context) {
+    probeCtx, cancel := context.WithTimeout(ctx.Request.Context(), databaseProbeTimeout)
+    defer cancel()
+
+    checks := gin.H{"database": "ok", "migrations": "ok"}
+    ready := true
+
+    db := database.GetDB()
+    if err := db.PingContext(probeCtx); err != nil {
+        log.Printf("Readiness check, database: %v", err)
+        checks["database"], checks["migrations"] = "unavailable", "unknown"
+        ready = false
+    } else if schema, err := database.CheckSchema(probeCtx, db); err != nil {
+        log.Printf("Readiness check, migrations: %v", err)
+        checks["migrations"] = "invalid"
+        ready = false
+    } else if !schema.Current() {
+        checks["migrations"] = fmt.Sprintf("%d pending", schema.Pending)
+        ready = false
+    } else if schema.Ahead > 0 {
+        checks["migrations"] = fmt.Sprintf("ahead by %d", schema.Ahead)
+    }
+
+    if !ready {
+        ctx.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"status": "ready", "checks": checks})
+}
+
+// VersionH describes the running build and the schema version of the database,
+// null when the database cannot be read
+func VersionH(ctx *gin.Context) {
+    probeCtx, cancel := context.WithTimeout(ctx.Request.Context(), databaseProbeTimeout)
+    defer cancel()
+
+    var schemaVersion *int
+    if schema, err := database.CheckSchema(probeCtx, database.GetDB()); err == nil {
+        schemaVersion = &schema.Version
+    }
+
+    ctx.JSON(http.StatusOK, gin.H{"build": version.Get(), "schema_version": schemaVersion})
+}
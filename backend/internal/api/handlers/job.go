@@ -3,11 +3,23 @@ package handlers
 import (
 	"backend/internal/models"
 	"backend/internal/services"
-	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
 	"github.com/gin-gonic/gin"
 )
 
+// isJobLifecycleError reports whether err rejects a job status or schedule
+func isJobLifecycleError(err error) bool {
+    return errors.Is(err, services.ErrInvalidJobStatus) ||
+        errors.Is(err, services.ErrIllegalJobStatusTransition) ||
+        errors.Is(err, services.ErrInvalidJobSchedule)
+}
+
 // CreateJob handles the creation of a new job
 func CreateJobH(ctx *gin.Context) {
     var job models.Job
@@ -18,7 +30,11 @@ func CreateJobH(ctx *gin.Context) {
 
     if err := services.CreateJob(ctx, &job); err != nil {
 
-        if err == services.ErrJobExists {
+        if errors.Is(err, services.ErrEmailNotVerified) {
+            ctx.JSON(http.StatusForbidden, gin.H{"msg": "Forbidden", "error": err.Error()})
+            return
+        }
+        if err == services.ErrJobExists || isJobLifecycleError(err) {
             ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": err.Error()})
             return
         }
@@ -47,7 +63,11 @@ func UpdateJobH(ctx *gin.Context) {
 
     if err := services.UpdateJob(ctx, &updateJob); err != nil {
 
-        if err == services.ErrJobDoesNotExist {
+        if errors.Is(err, services.ErrEmailNotVerified) {
+            ctx.JSON(http.StatusForbidden, gin.H{"msg": "Forbidden", "error": err.Error()})
+            return
+        }
+        if err == services.ErrJobDoesNotExist || isJobLifecycleError(err) {
             ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": err.Error()})
             return
         }
@@ -65,7 +85,7 @@ func GetJobByIdH(ctx *gin.Context) {
     job, err := services.GetJobById(ctx, jobId)
     if err != nil {
 
-        if err == sql.ErrNoRows {
+        if errors.Is(err, services.ErrJobDoesNotExist) {
             ctx.JSON(http.StatusNotFound, gin.H{"message": "Job not found"})
             return
         }
@@ -78,13 +98,34 @@ func GetJobByIdH(ctx *gin.Context) {
 }
 
 
-// GetJobsByTitleH retrieves jobs by job title
-func GetJobsByTitleH(ctx *gin.Context) {
-    jobtitle := ctx.Param("jobtitle")
-    
-    jobs, err := services.GetJobsByTitle(ctx, jobtitle)
+// parseListOptions reads ?limit=&cursor=&sort=&order= shared by the job listings
+func parseListOptions(ctx *gin.Context) (services.ListOptions, error) {
+    opts := services.ListOptions{
+        Cursor: ctx.Query("cursor"),
+        SortBy: ctx.Query("sort"),
+        Order:  ctx.Query("order"),
+    }
+
+    if limit := ctx.Query("limit"); limit != "" {
+        var err error
+        if opts.Limit, err = strconv.Atoi(limit); err != nil {
+            return opts, fmt.Errorf("%w: limit must be a number", services.ErrInvalidListOptions)
+        }
+    }
+    return opts, nil
+}
+
+// respondJobList writes a page of jobs, or maps the listing error to a response
+func respondJobList(ctx *gin.Context, jobs *services.JobList, err error) {
     if err != nil {
 
+        if errors.Is(err, services.ErrInvalidListOptions) ||
+            errors.Is(err, services.ErrInvalidJobSearch) ||
+            errors.Is(err, services.ErrInvalidJobStatus) {
+            ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": err.Error()})
+            return
+        }
+
         ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve jobs"})
         return
     }
@@ -92,30 +133,120 @@ func GetJobsByTitleH(ctx *gin.Context) {
     ctx.JSON(http.StatusOK, jobs)
 }
 
+// GetJobsByTitleH retrieves jobs by job title
+func GetJobsByTitleH(ctx *gin.Context) {
+    jobtitle := ctx.Param("jobtitle")
+
+    opts, err := parseListOptions(ctx)
+    if err != nil {
+        respondJobList(ctx, nil, err)
+        return
+    }
+
+    jobs, err := services.GetJobsByTitle(ctx, jobtitle, opts)
+    respondJobList(ctx, jobs, err)
+}
+
 // GetJobsByStatus retrieves jobs by status
 func GetJobsByStatusH(ctx *gin.Context) {
     status := ctx.Param("status")
-    
-    jobs, err := services.GetJobsByStatus(ctx, status)
-    if err != nil {
 
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve jobs"})
+    opts, err := parseListOptions(ctx)
+    if err != nil {
+        respondJobList(ctx, nil, err)
         return
     }
 
-    ctx.JSON(http.StatusOK, jobs)
+    jobs, err := services.GetJobsByStatus(ctx, status, opts)
+    respondJobList(ctx, jobs, err)
 }
 
 // ListUserJobs retrieves all jobs for a user
 func ListUserJobsH(ctx *gin.Context) {
-    
-    jobs, err := services.GetJobsByUserId(ctx)
+
+    opts, err := parseListOptions(ctx)
     if err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve jobs"})
+        respondJobList(ctx, nil, err)
         return
     }
 
-    ctx.JSON(http.StatusOK, jobs)
+    jobs, err := services.GetJobsByUserId(ctx, opts)
+    respondJobList(ctx, jobs, err)
+}
+
+// splitList reads a comma separated query parameter
+func splitList(value string) []string {
+    var items []string
+    for _, item := range strings.Split(value, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+// parseTime accepts RFC 3339 timestamps or plain dates
+func parseTime(name, value string) (*time.Time, error) {
+    if value == "" {
+        return nil, nil
+    }
+    for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+        if t, err := time.Parse(layout, value); err == nil {
+            return &t, nil
+        }
+    }
+    return nil, fmt.Errorf("%w: %s must be a date or RFC 3339 timestamp", services.ErrInvalidJobSearch, name)
+}
+
+// parseJobSearch reads the filters of the search endpoint, e.g.
+// ?q=backend engineer&status=active,paused&skills_all=Go,SQL&created_after=2025-01-01&attr[location]=Remote
+// attr values are JSON when they parse as such (numbers, booleans), strings otherwise.
+func parseJobSearch(ctx *gin.Context) (services.JobSearch, error) {
+    search := services.JobSearch{
+        Text:      strings.TrimSpace(ctx.Query("q")),
+        Statuses:  splitList(ctx.Query("status")),
+        SkillsAny: splitList(ctx.Query("skills_any")),
+        SkillsAll: splitList(ctx.Query("skills_all")),
+    }
+
+    var err error
+    if search.CreatedAfter, err = parseTime("created_after", ctx.Query("created_after")); err != nil {
+        return search, err
+    }
+    if search.CreatedBefore, err = parseTime("created_before", ctx.Query("created_before")); err != nil {
+        return search, err
+    }
+
+    if attrs := ctx.QueryMap("attr"); len(attrs) > 0 {
+        search.Attributes = make(map[string]interface{}, len(attrs))
+        for key, raw := range attrs {
+            var value interface{}
+            if err := json.Unmarshal([]byte(raw), &value); err != nil {
+                value = raw
+            }
+            search.Attributes[key] = value
+        }
+    }
+
+    return search, nil
+}
+
+// SearchJobsH retrieves the user's jobs matching a combination of filters
+func SearchJobsH(ctx *gin.Context) {
+    search, err := parseJobSearch(ctx)
+    if err != nil {
+        respondJobList(ctx, nil, err)
+        return
+    }
+
+    opts, err := parseListOptions(ctx)
+    if err != nil {
+        respondJobList(ctx, nil, err)
+        return
+    }
+
+    jobs, err := services.SearchJobs(ctx, search, opts)
+    respondJobList(ctx, jobs, err)
 }
 
 // DeleteJob deletes a specific job
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+    "backend/internal/loginguard"
+    "backend/internal/services"
+    "errors"
+    "math"
+    "net/http"
+    "strconv"
+    "github.com/gin-gonic/gin"
+)
+
+// mfaError maps two-factor service errors to a response
+func mfaError(ctx *gin.Context, err error, msg string) {
+    var locked *loginguard.LockedError
+    switch {
+    case errors.As(err, &locked):
+        ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+        ctx.JSON(http.StatusTooManyRequests, gin.H{"msg": "Too many requests", "error": err.Error()})
+    case errors.Is(err, services.ErrInvalidMFACode):
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": err.Error()})
+    case errors.Is(err, services.ErrMFAAlreadyEnabled), errors.Is(err, services.ErrMFANotEnabled),
+        errors.Is(err, services.ErrMFANotEnrolling):
+        ctx.JSON(http.StatusConflict, gin.H{"msg": "Conflict", "error": err.Error()})
+    default:
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": msg, "error": err.Error()})
+    }
+}
+
+// bindMFACode reads the code confirming a two-factor change
+func bindMFACode(ctx *gin.Context) (*services.MFACodeRequest, bool) {
+    var req services.MFACodeRequest
+    if err := ctx.ShouldBindJSON(&req); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Invalid input", "error": err.Error()})
+        return nil, false
+    }
+    req.Meta = sessionMeta(ctx)
+    return &req, true
+}
+
+// StartTOTPEnrollmentH returns a new authenticator secret and its otpauth URI
+func StartTOTPEnrollmentH(ctx *gin.Context) {
+    enrollment, err := services.StartTOTPEnrollment(ctx)
+    if err != nil {
+        mfaError(ctx, err, "Could not start two-factor enrollment")
+        return
+    }
+
+    ctx.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTOTPEnrollmentH enables two-factor authentication and returns recovery codes
+func ConfirmTOTPEnrollmentH(ctx *gin.Context) {
+    req, ok := bindMFACode(ctx)
+    if !ok {
+        return
+    }
+
+    codes, err := services.ConfirmTOTPEnrollment(ctx, req)
+    if err != nil {
+        mfaError(ctx, err, "Could not enable two-factor authentication")
+        return
+    }
+
+    ctx.JSON(http.StatusOK, codes)
+}
+
+// DisableTOTPH turns two-factor authentication off
+func DisableTOTPH(ctx *gin.Context) {
+    req, ok := bindMFACode(ctx)
+    if !ok {
+        return
+    }
+
+    if err := services.DisableTOTP(ctx, req); err != nil {
+        mfaError(ctx, err, "Could not disable two-factor authentication")
+        return
+    }
+
+    ctx.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodesH replaces the user's recovery codes
+func RegenerateRecoveryCodesH(ctx *gin.Context) {
+    req, ok := bindMFACode(ctx)
+    if !ok {
+        return
+    }
+
+    codes, err := services.RegenerateRecoveryCodes(ctx, req)
+    if err != nil {
+        mfaError(ctx, err, "Could not regenerate recovery codes")
+        return
+    }
+
+    ctx.JSON(http.StatusOK, codes)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+    "backend/internal/models"
+    "backend/internal/services"
+    "errors"
+    "net/http"
+    "strconv"
+    "github.com/gin-gonic/gin"
+)
+
+// organizationError maps organization service errors to a response
+func organizationError(ctx *gin.Context, err error, msg string) {
+    switch {
+    case errors.Is(err, services.ErrMemberDoesNotExist):
+        ctx.JSON(http.StatusNotFound, gin.H{"msg": "Not found", "error": err.Error()})
+    case errors.Is(err, services.ErrInsufficientRole):
+        ctx.JSON(http.StatusForbidden, gin.H{"msg": "Forbidden", "error": err.Error()})
+    case errors.Is(err, services.ErrMemberExists), errors.Is(err, services.ErrLastOwner), errors.Is(err, services.ErrLastOrganization):
+        ctx.JSON(http.StatusConflict, gin.H{"msg": "Conflict", "error": err.Error()})
+    case errors.Is(err, services.ErrInvalidRole):
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": err.Error()})
+    default:
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": msg, "error": err.Error()})
+    }
+}
+
+// CreateOrganizationH creates an organization owned by the user
+func CreateOrganizationH(ctx *gin.Context) {
+    var req models.Organization
+    if err := ctx.ShouldBindJSON(&req); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Invalid input", "error": err.Error()})
+        return
+    }
+
+    org, err := services.CreateOrganization(ctx, req.Name)
+    if err != nil {
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Failed to create organization", "error": err.Error()})
+        return
+    }
+
+    ctx.JSON(http.StatusCreated, org)
+}
+
+// ListOrganizationsH lists the organizations the user belongs to
+func ListOrganizationsH(ctx *gin.Context) {
+    orgs, err := services.ListOrganizations(ctx)
+    if err != nil {
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Failed to retrieve organizations", "error": err.Error()})
+        return
+    }
+
+    ctx.JSON(http.StatusOK, orgs)
+}
+
+// DeleteOrganizationH deletes an organization and its jobs
+func DeleteOrganizationH(ctx *gin.Context) {
+    if err := services.DeleteOrganization(ctx); err != nil {
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Failed to delete organization", "error": err.Error()})
+        return
+    }
+
+    ctx.JSON(http.StatusOK, gin.H{"message": "Organization deleted successfully"})
+}
+
+// ListMembersH lists the members of an organization
+func ListMembersH(ctx *gin.Context) {
+    members, err := services.ListMembers(ctx)
+    if err != nil {
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Failed to retrieve members", "error": err.Error()})
+        return
+    }
+
+    ctx.JSON(http.StatusOK, members)
+}
+
+// AddMemberH adds an existing user to an organization. The response is the same
+// whether or not the email belongs to a user.
+func AddMemberH(ctx *gin.Context) {
+    var req services.AddMemberRequest
+    if err := ctx.ShouldBindJSON(&req); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Invalid input", "error": err.Error()})
+        return
+    }
+
+    if err := services.AddMember(ctx, &req); err != nil {
+        organizationError(ctx, err, "Failed to add member")
+        return
+    }
+
+    ctx.JSON(http.StatusAccepted, gin.H{"message": "If a user with this email exists, they are now a member"})
+}
+
+// memberParam reads the :userId of a member route
+func memberParam(ctx *gin.Context) (int, bool) {
+    userID, err := strconv.Atoi(ctx.Param("userId"))
+    if err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Invalid input", "error": "user id must be a number"})
+        return 0, false
+    }
+    return userID, true
+}
+
+// UpdateMemberH changes the role of a member
+func UpdateMemberH(ctx *gin.Context) {
+    userID, ok := memberParam(ctx)
+    if !ok {
+        return
+    }
+
+    var req services.UpdateMemberRequest
+    if err := ctx.ShouldBindJSON(&req); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Invalid input", "error": err.Error()})
+        return
+    }
+
+    if err := services.UpdateMemberRole(ctx, userID, &req); err != nil {
+        organizationError(ctx, err, "Failed to update member")
+        return
+    }
+
+    ctx.JSON(http.StatusOK, gin.H{"message": "Member updated successfully"})
+}
+
+// RemoveMemberH removes a member from an organization
+func RemoveMemberH(ctx *gin.Context) {
+    userID, ok := memberParam(ctx)
+    if !ok {
+        return
+    }
+
+    if err := services.RemoveMember(ctx, userID); err != nil {
+        organizationError(ctx, err, "Failed to remove member")
+        return
+    }
+
+    ctx.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
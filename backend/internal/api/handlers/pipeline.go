@@ -0,0 +1,90 @@
+package handlers
+
+import (
+    "backend/internal/models"
+    "backend/internal/services"
+    "errors"
+    "net/http"
+    "strconv"
+    "github.com/gin-gonic/gin"
+)
+
+// pipelineError maps pipeline service errors to a response
+func pipelineError(ctx *gin.Context, err error, msg string) {
+    switch {
+    case errors.Is(err, services.ErrJobDoesNotExist), errors.Is(err, services.ErrApplicationDoesNotExist):
+        ctx.JSON(http.StatusNotFound, gin.H{"msg": "Not found", "error": err.Error()})
+    case errors.Is(err, services.ErrInvalidPipeline):
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": err.Error()})
+    case errors.Is(err, services.ErrIllegalTransition):
+        ctx.JSON(http.StatusConflict, gin.H{"msg": "Conflict", "error": err.Error()})
+    default:
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": msg, "error": err.Error()})
+    }
+}
+
+// GetPipelineH retrieves the stage pipeline of a job
+func GetPipelineH(ctx *gin.Context) {
+    pipeline, err := services.GetPipeline(ctx, ctx.Param("jobId"))
+    if err != nil {
+        pipelineError(ctx, err, "Failed to retrieve pipeline")
+        return
+    }
+
+    ctx.JSON(http.StatusOK, pipeline)
+}
+
+// SetPipelineH replaces the stage pipeline of a job
+func SetPipelineH(ctx *gin.Context) {
+    var pipeline models.Pipeline
+    if err := ctx.ShouldBindJSON(&pipeline); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    if err := services.SetPipeline(ctx, ctx.Param("jobId"), &pipeline); err != nil {
+        pipelineError(ctx, err, "Failed to update pipeline")
+        return
+    }
+
+    ctx.JSON(http.StatusOK, pipeline)
+}
+
+// TransitionApplicationH moves an application to another stage
+func TransitionApplicationH(ctx *gin.Context) {
+    applicationID, err := strconv.Atoi(ctx.Param("applicationId"))
+    if err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": "invalid application id"})
+        return
+    }
+
+    var transition models.StageTransition
+    if err := ctx.ShouldBindJSON(&transition); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    if err := services.TransitionApplication(ctx, ctx.Param("jobId"), applicationID, &transition); err != nil {
+        pipelineError(ctx, err, "Failed to move application")
+        return
+    }
+
+    ctx.JSON(http.StatusCreated, transition)
+}
+
+// ListTransitionsH retrieves the stage history of an application
+func ListTransitionsH(ctx *gin.Context) {
+    applicationID, err := strconv.Atoi(ctx.Param("applicationId"))
+    if err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": "invalid application id"})
+        return
+    }
+
+    transitions, err := services.ListTransitions(ctx, ctx.Param("jobId"), applicationID)
+    if err != nil {
+        pipelineError(ctx, err, "Failed to retrieve transitions")
+        return
+    }
+
+    ctx.JSON(http.StatusOK, transitions)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+    "backend/internal/models"
+    "backend/internal/services"
+    "errors"
+    "net/http"
+    "github.com/gin-gonic/gin"
+)
+
+// questionnaireError maps questionnaire service errors to a response
+func questionnaireError(ctx *gin.Context, err error, msg string) {
+    switch {
+    case errors.Is(err, services.ErrJobDoesNotExist), errors.Is(err, services.ErrQuestionnaireDoesNotExist):
+        ctx.JSON(http.StatusNotFound, gin.H{"msg": "Not found", "error": err.Error()})
+    case errors.Is(err, services.ErrQuestionnaireExists), errors.Is(err, services.ErrInvalidQuestionnaire):
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": err.Error()})
+    default:
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": msg, "error": err.Error()})
+    }
+}
+
+// CreateQuestionnaireH attaches a questionnaire to a job
+func CreateQuestionnaireH(ctx *gin.Context) {
+    var questionnaire models.Questionnaire
+    if err := ctx.ShouldBindJSON(&questionnaire); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    if err := services.CreateQuestionnaire(ctx, ctx.Param("jobId"), &questionnaire); err != nil {
+        questionnaireError(ctx, err, "Failed to create questionnaire")
+        return
+    }
+
+    ctx.JSON(http.StatusCreated, questionnaire)
+}
+
+// GetQuestionnaireH retrieves the questionnaire of a job
+func GetQuestionnaireH(ctx *gin.Context) {
+    questionnaire, err := services.GetQuestionnaire(ctx, ctx.Param("jobId"))
+    if err != nil {
+        questionnaireError(ctx, err, "Failed to retrieve questionnaire")
+        return
+    }
+
+    ctx.JSON(http.StatusOK, questionnaire)
+}
+
+// UpdateQuestionnaireH replaces the questionnaire of a job
+func UpdateQuestionnaireH(ctx *gin.Context) {
+    var questionnaire models.Questionnaire
+    if err := ctx.ShouldBindJSON(&questionnaire); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    if err := services.UpdateQuestionnaire(ctx, ctx.Param("jobId"), &questionnaire); err != nil {
+        questionnaireError(ctx, err, "Failed to update questionnaire")
+        return
+    }
+
+    ctx.JSON(http.StatusOK, questionnaire)
+}
+
+// DeleteQuestionnaireH removes the questionnaire of a job
+func DeleteQuestionnaireH(ctx *gin.Context) {
+    if err := services.DeleteQuestionnaire(ctx, ctx.Param("jobId")); err != nil {
+        questionnaireError(ctx, err, "Failed to delete questionnaire")
+        return
+    }
+
+    ctx.JSON(http.StatusOK, gin.H{"message": "Questionnaire deleted successfully"})
+}
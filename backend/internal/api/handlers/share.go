@@ -0,0 +1,78 @@
+package handlers
+
+import (
+    "backend/internal/services"
+    "errors"
+    "net/http"
+    "github.com/gin-gonic/gin"
+)
+
+// shareLinkError maps share link service errors to a response
+func shareLinkError(ctx *gin.Context, err error, msg string) {
+    switch {
+    case errors.Is(err, services.ErrJobDoesNotExist), errors.Is(err, services.ErrShareLinkDoesNotExist):
+        ctx.JSON(http.StatusNotFound, gin.H{"msg": "Not found", "error": err.Error()})
+    case errors.Is(err, services.ErrShareLinkExists):
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": err.Error()})
+    default:
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": msg, "error": err.Error()})
+    }
+}
+
+// GetShareLinkH retrieves the share token of a job
+func GetShareLinkH(ctx *gin.Context) {
+    link, err := services.GetShareLink(ctx, ctx.Param("jobId"))
+    if err != nil {
+        shareLinkError(ctx, err, "Failed to retrieve share link")
+        return
+    }
+
+    ctx.JSON(http.StatusOK, link)
+}
+
+// CreateShareLinkH creates a share token for a job
+func CreateShareLinkH(ctx *gin.Context) {
+    link, err := services.CreateShareLink(ctx, ctx.Param("jobId"), false)
+    if err != nil {
+        shareLinkError(ctx, err, "Failed to create share link")
+        return
+    }
+
+    ctx.JSON(http.StatusCreated, link)
+}
+
+// RotateShareLinkH replaces the share token of a job, breaking the old link
+func RotateShareLinkH(ctx *gin.Context) {
+    link, err := services.CreateShareLink(ctx, ctx.Param("jobId"), true)
+    if err != nil {
+        shareLinkError(ctx, err, "Failed to rotate share link")
+        return
+    }
+
+    ctx.JSON(http.StatusOK, link)
+}
+
+// RevokeShareLinkH removes the share token of a job
+func RevokeShareLinkH(ctx *gin.Context) {
+    if err := services.RevokeShareLink(ctx, ctx.Param("jobId")); err != nil {
+        shareLinkError(ctx, err, "Failed to revoke share link")
+        return
+    }
+
+    ctx.JSON(http.StatusOK, gin.H{"message": "Share link revoked successfully"})
+}
+
+// GetPublicJobH shows an active job and its questionnaire to candidates through a share token
+func GetPublicJobH(ctx *gin.Context) {
+    job, err := services.GetPublicJob(ctx, ctx.Param("shareToken"))
+    if err != nil {
+        if errors.Is(err, services.ErrJobDoesNotExist) {
+            ctx.JSON(http.StatusNotFound, gin.H{"message": "Job not found"})
+            return
+        }
+        ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Failed to retrieve job", "error": err.Error()})
+        return
+    }
+
+    ctx.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+    "backend/internal/config"
+    "backend/internal/services"
+    "backend/internal/storage"
+    "errors"
+    "net/http"
+    "strconv"
+    "github.com/gin-gonic/gin"
+)
+
+// UploadApplicationFileH accepts a candidate's file (multipart field "file") for a job,
+// returning the key to submit as the answer to a file question
+func UploadApplicationFileH(ctx *gin.Context) {
+    maxBytes := config.GetConfig().Storage.MaxUploadBytes
+
+    // Leave some room for the multipart envelope around the file
+    ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBytes+1<<20)
+
+    header, err := ctx.FormFile("file")
+    if err != nil {
+        var maxErr *http.MaxBytesError
+        if errors.As(err, &maxErr) {
+            ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"msg": "Bad request", "error": services.ErrFileTooLarge.Error()})
+            return
+        }
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    file, err := header.Open()
+    if err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    defer file.Close()
+
+    uploaded, err := services.UploadApplicationFile(ctx, ctx.Param("shareToken"), header.Filename, file, header.Size, ctx.ClientIP())
+    if err != nil {
+        switch {
+        case errors.Is(err, services.ErrJobDoesNotExist):
+            ctx.JSON(http.StatusNotFound, gin.H{"message": "Job not found"})
+        case errors.Is(err, services.ErrFileTooLarge):
+            ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"msg": "Bad request", "error": err.Error()})
+        case errors.Is(err, services.ErrUnsupportedFileType):
+            ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"msg": "Bad request", "error": err.Error()})
+        case errors.Is(err, services.ErrUploadQuotaExceeded):
+            ctx.JSON(http.StatusTooManyRequests, gin.H{"msg": "Too many requests", "error": err.Error()})
+        case errors.Is(err, services.ErrJobNotAcceptingApplications):
+            ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": err.Error()})
+        default:
+            ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Failed to upload file", "error": err.Error()})
+        }
+        return
+    }
+
+    ctx.JSON(http.StatusCreated, uploaded)
+}
+
+// GetApplicationFileH returns an expiring download link for a file answer of an application
+func GetApplicationFileH(ctx *gin.Context) {
+    applicationID, err := strconv.Atoi(ctx.Param("applicationId"))
+    if err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"msg": "Bad request", "error": "invalid application id"})
+        return
+    }
+
+    link, err := services.GetApplicationFileLink(ctx, ctx.Param("jobId"), applicationID, ctx.Param("questionId"))
+    if err != nil {
+        switch {
+        case errors.Is(err, services.ErrJobDoesNotExist),
+            errors.Is(err, services.ErrApplicationDoesNotExist),
+            errors.Is(err, services.ErrFileDoesNotExist):
+            ctx.JSON(http.StatusNotFound, gin.H{"msg": "Not found", "error": err.Error()})
+        default:
+            ctx.JSON(http.StatusInternalServerError, gin.H{"msg": "Failed to create download link", "error": err.Error()})
+        }
+        return
+    }
+
+    ctx.JSON(http.StatusOK, link)
+}
+
+// DownloadFileH serves files from local storage through links signed by GetApplicationFileH
+func DownloadFileH(ctx *gin.Context) {
+    local, ok := storage.Get().(*storage.Local)
+    if !ok {
+        ctx.JSON(http.StatusNotFound, gin.H{"message": "File not found"})
+        return
+    }
+
+    key := ctx.Param("key")[1:] // strip leading "/" of the wildcard
+    file, err := local.Open(key, ctx.Query("expires"), ctx.Query("signature"))
+    if err != nil {
+        switch {
+        case errors.Is(err, storage.ErrInvalidSignature):
+            ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+        case errors.Is(err, storage.ErrNotFound):
+            ctx.JSON(http.StatusNotFound, gin.H{"message": "File not found"})
+        default:
+            ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+        }
+        return
+    }
+    defer file.Close()
+
+    info, err := file.Stat()
+    if err != nil {
+        ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+        return
+    }
+
+    ctx.Header("Content-Disposition", "attachment")
+    ctx.Header("Cache-Control", "private, no-store")
+    http.ServeContent(ctx.Writer, ctx.Request, "", info.ModTime(), file)
+}
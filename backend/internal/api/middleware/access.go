@@ -0,0 +1,43 @@
+package middleware
+
+import (
+    "fmt"
+    "github.com/gin-gonic/gin"
+    "backend/internal/services"
+)
+
+// Access is what a route requires of its callers. Every route declares one when
+// it is registered, see api.SetupRoutes.
+type Access struct {
+    public     bool
+    permission string
+}
+
+var (
+    // Public routes need no token, e.g. login or the candidate facing routes.
+    Public = Access{public: true}
+    // Authenticated routes need a valid token and act on the user, outside of any
+    // organization. API keys are not accepted.
+    Authenticated = Access{}
+)
+
+// Require is the access of routes that act in an organization: a valid token and
+// a role there that grants permission. Unknown permissions panic at startup.
+func Require(permission string) Access {
+    if services.IsPermission(permission) {
+        return Access{permission: permission}
+    }
+    panic(fmt.Sprintf("permission %q is not granted by any role", permission))
+}
+
+// Handlers returns the middleware that enforces the access, to run before the route's handler.
+func (a Access) Handlers() []gin.HandlerFunc {
+    switch {
+    case a.public:
+        return nil
+    case a.permission == "":
+        return []gin.HandlerFunc{AuthMiddleware(), SessionOnly()}
+    default:
+        return []gin.HandlerFunc{AuthMiddleware(), Authorize(a.permission)}
+    }
+}
@@ -5,71 +5,67 @@ import (
     "strings"
     "net/http"
     "github.com/gin-gonic/gin"
-    "github.com/golang-jwt/jwt/v4"
-    "backend/internal/config"
+    "backend/internal/authtoken"
+    "backend/internal/services"
 )
 
+// APIKeyHeader carries an API key, as an alternative to a Bearer token.
+const APIKeyHeader = "X-API-Key"
+
 var (
     ErrMissingToken = errors.New("missing authentication token")
     ErrInvalidToken = errors.New("invalid authentication token")
+    ErrRevokedToken = errors.New("session has ended, log in again")
 )
 
-
+// AuthMiddleware rejects requests without a valid access token of an active session
+// or a valid API key. Routes get it through their Access, public routes do not.
 func AuthMiddleware() gin.HandlerFunc {
     return func(ctx *gin.Context) {
-        // Skip auth for unauthenticated apis
-        if isPublicPath(ctx.Request.URL.Path) {
-            ctx.Next()
+        if key := ctx.GetHeader(APIKeyHeader); key != "" {
+            authenticateAPIKey(ctx, key)
             return
         }
 
-        userID, err := validateToken(ctx, config.GetConfig().JWTSecret)
+        userID, sessionID, err := validateToken(ctx)
         if err != nil {
             ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
             ctx.Abort()
             return
         }
 
-        // Add user ID to context
+        // Tokens stop working as soon as their session is revoked
+        active, err := services.SessionActive(ctx, userID, sessionID)
+        if err != nil {
+            ctx.JSON(http.StatusInternalServerError, gin.H{"error": "could not verify session"})
+            ctx.Abort()
+            return
+        }
+        if !active {
+            ctx.JSON(http.StatusUnauthorized, gin.H{"error": ErrRevokedToken.Error()})
+            ctx.Abort()
+            return
+        }
+
+        // Add user and session ID to context
         ctx.Set("userID", userID)
+        ctx.Set("sessionID", sessionID)
         ctx.Next()
     }
 }
 
-func isPublicPath(path string) bool {
-    publicPaths := map[string]bool{
-        "/api/login":    true,
-        "/api/register": true,
-    }
-    return publicPaths[path]
-}
-
-func validateToken(ctx *gin.Context, secret string) (int, error) {
+func validateToken(ctx *gin.Context) (int, string, error) {
     tokenString := extractToken(ctx)
     if tokenString == "" {
-        return 0, ErrMissingToken
-    }
-
-    token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-        return []byte(secret), nil
-    })
-
-    if err != nil || !token.Valid {
-        return 0, ErrInvalidToken
+        return 0, "", ErrMissingToken
     }
 
-    claims, ok := token.Claims.(jwt.MapClaims)
-
-    if !ok {
-        return 0, ErrInvalidToken
-    }
-
-    userIDFloat, ok := claims["user_id"].(float64)
-    if !ok {
-        return 0, ErrInvalidToken
+    claims, err := authtoken.Get().Verify(tokenString)
+    if err != nil {
+        return 0, "", ErrInvalidToken
     }
 
-    return int(userIDFloat), nil
+    return claims.UserID, claims.SessionID, nil
 }
 
 func extractToken(c *gin.Context) string {
@@ -79,3 +75,37 @@ func extractToken(c *gin.Context) string {
     }
     return ""
 }
+
+// authenticateAPIKey acts as the key's user. The key's scopes and organization are
+// added to the context for Authorize to enforce.
+func authenticateAPIKey(ctx *gin.Context, key string) {
+    apiKey, err := services.AuthenticateAPIKey(ctx, key)
+    if errors.Is(err, services.ErrInvalidAPIKey) {
+        ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "could not verify api key"})
+        return
+    }
+
+    ctx.Set("userID", apiKey.UserID)
+    ctx.Set("apiKeyID", apiKey.ID)
+    ctx.Set("apiKeyScopes", apiKey.Scopes)
+    if apiKey.OrgID != nil {
+        ctx.Set("apiKeyOrgID", *apiKey.OrgID)
+    }
+    ctx.Next()
+}
+
+// SessionOnly rejects API keys on routes that manage the user's own account, so a
+// leaked key cannot be used to mint new keys or end sessions.
+func SessionOnly() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        if _, ok := ctx.Get("apiKeyID"); ok {
+            forbidden(ctx, services.ErrAPIKeyNotAllowed, "")
+            return
+        }
+        ctx.Next()
+    }
+}
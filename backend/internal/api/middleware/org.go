@@ -0,0 +1,87 @@
+package middleware
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+    "github.com/gin-gonic/gin"
+    "backend/internal/services"
+)
+
+// OrgHeader selects the organization a request acts in; without it the user's
+// first organization is used. Routes with an :orgId parameter use that instead.
+const OrgHeader = "X-Organization-ID"
+
+var ErrInvalidOrganization = errors.New("invalid organization id")
+
+// Authorize resolves the organization of the request and the user's role in it,
+// adds them to the context as orgID and role, and rejects the request unless the
+// role grants permission. It must run after AuthMiddleware.
+func Authorize(permission string) gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        orgID, role, err := resolveOrganization(ctx)
+        if errors.Is(err, ErrInvalidOrganization) {
+            ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        if errors.Is(err, services.ErrOrganizationDoesNotExist) {
+            forbidden(ctx, err, permission)
+            return
+        }
+        if err != nil {
+            ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "could not resolve organization"})
+            return
+        }
+
+        if !services.HasPermission(role, permission) {
+            forbidden(ctx, services.ErrInsufficientRole, permission)
+            return
+        }
+        // API keys are limited by their scopes as well as by their user's role
+        if scopes, ok := ctx.Get("apiKeyScopes"); ok && !services.ScopesAllow(scopes.([]string), permission) {
+            forbidden(ctx, services.ErrAPIKeyScope, permission)
+            return
+        }
+
+        ctx.Set("orgID", orgID)
+        ctx.Set("role", role)
+        ctx.Next()
+    }
+}
+
+func resolveOrganization(ctx *gin.Context) (int, string, error) {
+    userID := ctx.GetInt("userID")
+
+    keyOrgID, keyBound := ctx.Get("apiKeyOrgID")
+
+    raw := ctx.Param("orgId")
+    if raw == "" {
+        raw = ctx.GetHeader(OrgHeader)
+    }
+    if raw == "" && keyBound {
+        raw = strconv.Itoa(keyOrgID.(int))
+    }
+    if raw == "" {
+        return services.DefaultOrganization(ctx, userID)
+    }
+
+    orgID, err := strconv.Atoi(raw)
+    if err != nil {
+        return 0, "", ErrInvalidOrganization
+    }
+    // A key limited to one organization cannot act in another
+    if keyBound && orgID != keyOrgID.(int) {
+        return 0, "", services.ErrOrganizationDoesNotExist
+    }
+    role, err := services.MemberRole(ctx, userID, orgID)
+    return orgID, role, err
+}
+
+// forbidden ends a request every access check denies with the same body.
+func forbidden(ctx *gin.Context, err error, permission string) {
+    ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+        "msg":        "Forbidden",
+        "error":      err.Error(),
+        "permission": permission,
+    })
+}
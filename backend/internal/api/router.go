@@ -0,0 +1,38 @@
+package api
+
+import (
+	"backend/internal/api/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routes registers handlers on a router group. Unlike gin's own methods every
+// route has to declare its access, so none can ship without an access check.
+type routes struct {
+	group *gin.RouterGroup
+}
+
+func (r routes) Group(path string) routes {
+	return routes{group: r.group.Group(path)}
+}
+
+func (r routes) GET(path string, access middleware.Access, handler gin.HandlerFunc) {
+	r.handle(http.MethodGet, path, access, handler)
+}
+
+func (r routes) POST(path string, access middleware.Access, handler gin.HandlerFunc) {
+	r.handle(http.MethodPost, path, access, handler)
+}
+
+func (r routes) PUT(path string, access middleware.Access, handler gin.HandlerFunc) {
+	r.handle(http.MethodPut, path, access, handler)
+}
+
+func (r routes) DELETE(path string, access middleware.Access, handler gin.HandlerFunc) {
+	r.handle(http.MethodDelete, path, access, handler)
+}
+
+func (r routes) handle(method, path string, access middleware.Access, handler gin.HandlerFunc) {
+	r.group.Handle(method, path, append(access.Handlers(), handler)...)
+}
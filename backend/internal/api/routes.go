@@ -3,30 +3,137 @@ package api
 import (
 	"backend/internal/api/handlers"
 	"backend/internal/api/middleware"
+	"backend/internal/config"
+	"backend/internal/models"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRoutes(router *gin.Engine) {
-	// add cors middleware
-	router.Use(cors.Default())
-	api := router.Group("/api", middleware.AuthMiddleware())
+	// add cors middleware, any origin unless CORS_ORIGINS restricts it
+	if origins := config.GetConfig().Server.CORSOrigins; len(origins) > 0 {
+		corsConfig := cors.DefaultConfig()
+		corsConfig.AllowOrigins = origins
+		corsConfig.AddAllowHeaders("Authorization", middleware.APIKeyHeader, middleware.OrgHeader)
+		corsConfig.AllowCredentials = true // the single sign-on login cookie
+		router.Use(cors.New(corsConfig))
+	} else {
+		router.Use(cors.Default())
+	}
+	root := routes{group: &router.RouterGroup}
+	api := root.Group("/api")
+
+	// access every route below declares
+	public := middleware.Public
+	authenticated := middleware.Authenticated
+	require := middleware.Require
 
 	// Authentication routes
-	api.POST("/login", handlers.LoginH)
-	api.POST("/register", handlers.RegisterH)
+	api.POST("/login", public, handlers.LoginH)
+	api.POST("/login/mfa", public, handlers.LoginMFAH)
+	api.POST("/register", public, handlers.RegisterH)
+	api.POST("/token/refresh", public, handlers.RefreshTokenH)
+	api.POST("/logout", authenticated, handlers.LogoutH)
+
+	// single sign-on through an OpenID Connect provider, when configured
+	api.GET("/sso/oidc/start", public, handlers.StartSSOLoginH)   // Identity provider URL to send the user to
+	api.POST("/sso/oidc/callback", public, handlers.SSOCallbackH) // Log in with the code and state the provider redirected back with
+
+	// account recovery and email verification, tokens arrive by email
+	api.POST("/password/forgot", public, handlers.ForgotPasswordH)                    // Mail a password reset link
+	api.POST("/password/reset", public, handlers.ResetPasswordH)                      // Set a new password with a reset token
+	api.POST("/email/verify", public, handlers.VerifyEmailH)                          // Verify email with a verification token
+	api.POST("/email/verification", authenticated, handlers.ResendVerificationEmailH) // Mail a new verification link
+
+	// two-factor authentication routes
+	api.POST("/mfa/totp", authenticated, handlers.StartTOTPEnrollmentH)               // Start enrollment, returns secret and otpauth URI
+	api.POST("/mfa/totp/confirm", authenticated, handlers.ConfirmTOTPEnrollmentH)     // Enable with a first code, returns recovery codes
+	api.DELETE("/mfa/totp", authenticated, handlers.DisableTOTPH)                     // Disable, needs a code
+	api.POST("/mfa/recovery-codes", authenticated, handlers.RegenerateRecoveryCodesH) // Replace recovery codes, needs a code
+
+	// session routes, one session per login
+	api.GET("/sessions", authenticated, handlers.ListSessionsH)                // List active sessions
+	api.DELETE("/sessions/:sessionId", authenticated, handlers.RevokeSessionH) // Revoke a session
 
-	//job routes
+	// api key routes, keys let scripts call the api without a password
+	api.POST("/keys", authenticated, handlers.CreateAPIKeyH)          // Create api key, the key is only returned once
+	api.GET("/keys", authenticated, handlers.ListAPIKeysH)            // List api keys
+	api.DELETE("/keys/:keyId", authenticated, handlers.RevokeAPIKeyH) // Revoke api key
+
+	// organization routes
+	api.POST("/orgs", authenticated, handlers.CreateOrganizationH) // Create organization owned by user
+	api.GET("/orgs", authenticated, handlers.ListOrganizationsH)   // List user's organizations and roles
+	orgs := api.Group("/orgs/:orgId")
+	{
+		orgs.DELETE("", require(models.PermOrganizationDelete), handlers.DeleteOrganizationH)     // Delete organization and its jobs
+		orgs.GET("/members", require(models.PermMembersRead), handlers.ListMembersH)              // List members
+		orgs.POST("/members", require(models.PermMembersWrite), handlers.AddMemberH)              // Add existing user as member
+		orgs.PUT("/members/:userId", require(models.PermMembersWrite), handlers.UpdateMemberH)    // Change role of member
+		orgs.DELETE("/members/:userId", require(models.PermMembersWrite), handlers.RemoveMemberH) // Remove member
+	}
+
+	//job routes, jobs belong to the organization named by the X-Organization-ID header
 	jobs := api.Group("/jobs")
 	{
-		jobs.POST("", handlers.CreateJobH)                        // Create job
-		jobs.PUT("/:jobId", handlers.UpdateJobH)                  // Update job
-		jobs.GET("/:jobId", handlers.GetJobByIdH)                 // Get specific job by id
-		jobs.GET("/jobtitle/:jobtitle", handlers.GetJobsByTitleH) // Get jobs by jobtitle - Has to include the jobtitle(could be a subset)
-		jobs.GET("/status/:status", handlers.GetJobsByStatusH)    // Get jobs by status
-		jobs.GET("", handlers.ListUserJobsH)                      // List all jobs for user
-		jobs.DELETE("/:jobId", handlers.DeleteJobH)               // Delete job
+		jobs.POST("", require(models.PermJobsWrite), handlers.CreateJobH)                       // Create job
+		jobs.PUT("/:jobId", require(models.PermJobsWrite), handlers.UpdateJobH)                 // Update job
+		jobs.GET("/:jobId", require(models.PermJobsRead), handlers.GetJobByIdH)                 // Get specific job by id
+		jobs.GET("/jobtitle/:jobtitle", require(models.PermJobsRead), handlers.GetJobsByTitleH) // Get jobs by jobtitle - Has to include the jobtitle(could be a subset)
+		jobs.GET("/status/:status", require(models.PermJobsRead), handlers.GetJobsByStatusH)    // Get jobs by status
+		jobs.GET("/search", require(models.PermJobsRead), handlers.SearchJobsH)                 // Search jobs combining text, status, skills, date and attribute filters
+		jobs.GET("", require(models.PermJobsRead), handlers.ListUserJobsH)                      // List all jobs of organization
+		jobs.DELETE("/:jobId", require(models.PermJobsDelete), handlers.DeleteJobH)             // Delete job
+
+		// questionnaire routes, one questionnaire per job
+		jobs.POST("/:jobId/questionnaire", require(models.PermJobsWrite), handlers.CreateQuestionnaireH)   // Attach questionnaire to job
+		jobs.GET("/:jobId/questionnaire", require(models.PermJobsRead), handlers.GetQuestionnaireH)        // Get questionnaire of job
+		jobs.PUT("/:jobId/questionnaire", require(models.PermJobsWrite), handlers.UpdateQuestionnaireH)    // Replace questionnaire of job
+		jobs.DELETE("/:jobId/questionnaire", require(models.PermJobsWrite), handlers.DeleteQuestionnaireH) // Delete questionnaire of job
+
+		jobs.GET("/:jobId/applications", require(models.PermApplicationsRead), handlers.ListApplicationsH)                                    // List applications, filtered by answers
+		jobs.GET("/:jobId/applications/:applicationId/files/:questionId", require(models.PermApplicationsRead), handlers.GetApplicationFileH) // Expiring download link for a file answer
+
+		// application pipeline routes
+		jobs.GET("/:jobId/pipeline", require(models.PermJobsRead), handlers.GetPipelineH)                                                    // Get stage pipeline of job
+		jobs.PUT("/:jobId/pipeline", require(models.PermJobsWrite), handlers.SetPipelineH)                                                   // Replace stage pipeline of job
+		jobs.POST("/:jobId/applications/:applicationId/transitions", require(models.PermApplicationsWrite), handlers.TransitionApplicationH) // Move application to another stage
+		jobs.GET("/:jobId/applications/:applicationId/transitions", require(models.PermApplicationsRead), handlers.ListTransitionsH)         // Stage history of application
+
+		// share link routes
+		jobs.GET("/:jobId/share", require(models.PermJobsRead), handlers.GetShareLinkH)             // Get share token of job
+		jobs.POST("/:jobId/share", require(models.PermJobsWrite), handlers.CreateShareLinkH)        // Create share token for job
+		jobs.POST("/:jobId/share/rotate", require(models.PermJobsWrite), handlers.RotateShareLinkH) // Replace share token of job
+		jobs.DELETE("/:jobId/share", require(models.PermJobsWrite), handlers.RevokeShareLinkH)      // Revoke share token of job
+	}
+
+	// candidate facing routes, no authentication. Jobs are only reachable through their share token
+	candidates := root.Group("/public")
+	{
+		candidates.GET("/jobs/:shareToken", public, handlers.GetPublicJobH)                    // Sanitized job and questionnaire
+		candidates.POST("/jobs/:shareToken/applications", public, handlers.SubmitApplicationH) // Apply to an active job
+		candidates.POST("/jobs/:shareToken/uploads", public, handlers.UploadApplicationFileH)  // Upload a resume or other file answer
 	}
 
+	// probes for load balancers and orchestrators, cheap enough to poll every second
+	root.GET("/healthz", public, handlers.HealthzH) // Process is alive
+	root.GET("/readyz", public, handlers.ReadyzH)   // Database reachable and migrations current
+	root.GET("/version", public, handlers.VersionH) // Build commit and time, schema version
+
+	// public keys for services verifying access tokens
+	root.GET("/.well-known/jwks.json", public, handlers.JWKSH)
+
+	// signed download links for locally stored files, the signature is the access check
+	root.GET("/files/*key", public, handlers.DownloadFileH)
+
+}
+
+// SetupProbeRoutes registers the routes of the internal probe listener. They
+// describe the deployment, so they are only served on SERVER_PROBE_ADDR, which
+// must not be reachable from outside; the listener is their access check.
+func SetupProbeRoutes(router *gin.Engine) {
+	root := routes{group: &router.RouterGroup}
+
+	// database reachability and connection pool statistics, for probes and dashboards
+	root.GET("/health/db", middleware.Public, handlers.DatabaseHealthH)
 }
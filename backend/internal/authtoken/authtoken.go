@@ -0,0 +1,273 @@
+// Package authtoken signs and verifies the access tokens HireEasy issues. They
+// are signed with an RSA (RS256) or Ed25519 (EdDSA) key whose public half is
+// published as a JWKS, so other services can verify them, or with the shared
+// JWT secret (HS256) when no key is configured.
+//
+// To rotate keys, sign with the new key and list the old one as a verification
+// key until the access tokens it signed have expired.
+package authtoken
+
+import (
+    "backend/internal/config"
+    "backend/internal/jwk"
+    "crypto"
+    "crypto/ed25519"
+    "crypto/rsa"
+    "crypto/x509"
+    "encoding/pem"
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+    "time"
+
+    "github.com/golang-jwt/jwt/v4"
+)
+
+var ErrInvalidToken = errors.New("invalid authentication token")
+
+// Claims of an access token.
+type Claims struct {
+    jwt.RegisteredClaims
+    UserID    int    `json:"user_id"`
+    SessionID string `json:"sid"`
+}
+
+// key is one key tokens can be verified with, and signed with if private is set.
+type key struct {
+    id      string
+    method  jwt.SigningMethod
+    private interface{}
+    public  interface{}
+}
+
+// Keyring holds the signing key and every key tokens are still accepted from.
+type Keyring struct {
+    issuer   string
+    audience string
+    signing  *key
+    keys     map[string]*key // by kid
+    order    []string        // kids, signing key first
+    methods  []string
+}
+
+func newKeyring(issuer, audience string) *Keyring {
+    return &Keyring{issuer: issuer, audience: audience, keys: map[string]*key{}}
+}
+
+func (k *Keyring) add(key *key) {
+    k.keys[key.id] = key
+    k.order = append(k.order, key.id)
+    for _, method := range k.methods {
+        if method == key.method.Alg() {
+            return
+        }
+    }
+    k.methods = append(k.methods, key.method.Alg())
+}
+
+// NewHMAC returns a keyring signing with a shared secret. Tokens carry no kid.
+func NewHMAC(secret []byte, issuer, audience string) *Keyring {
+    keyring := newKeyring(issuer, audience)
+    keyring.signing = &key{method: jwt.SigningMethodHS256, private: secret, public: secret}
+    keyring.add(keyring.signing)
+    return keyring
+}
+
+// NewAsymmetric returns a keyring signing with signer, an *rsa.PrivateKey or
+// ed25519.PrivateKey, that also accepts tokens signed by the previous keys.
+func NewAsymmetric(signer crypto.Signer, previous []crypto.PublicKey, issuer, audience string) (*Keyring, error) {
+    keyring := newKeyring(issuer, audience)
+
+    signing, err := newKey(signer.Public())
+    if err != nil {
+        return nil, err
+    }
+    signing.private = signer
+    keyring.signing = signing
+    keyring.add(signing)
+
+    for _, public := range previous {
+        key, err := newKey(public)
+        if err != nil {
+            return nil, err
+        }
+        if _, ok := keyring.keys[key.id]; !ok {
+            keyring.add(key)
+        }
+    }
+    return keyring, nil
+}
+
+// newKey picks the algorithm of a public key and names it by its thumbprint.
+func newKey(public crypto.PublicKey) (*key, error) {
+    var method jwt.SigningMethod
+    switch public := public.(type) {
+    case *rsa.PublicKey:
+        if public.N.BitLen() < 2048 {
+            return nil, errors.New("rsa keys must have at least 2048 bits")
+        }
+        method = jwt.SigningMethodRS256
+    case ed25519.PublicKey:
+        method = jwt.SigningMethodEdDSA
+    default:
+        return nil, fmt.Errorf("%w: %T, use an RSA or Ed25519 key", jwk.ErrUnsupportedKey, public)
+    }
+
+    description, err := jwk.New("", method.Alg(), public)
+    if err != nil {
+        return nil, err
+    }
+    id, err := description.Thumbprint()
+    if err != nil {
+        return nil, err
+    }
+    return &key{id: id, method: method, public: public}, nil
+}
+
+// Sign issues an access token for a session, valid for ttl.
+func (k *Keyring) Sign(userID int, sessionID string, ttl time.Duration) (string, error) {
+    now := time.Now()
+    claims := Claims{
+        RegisteredClaims: jwt.RegisteredClaims{
+            Issuer:    k.issuer,
+            Subject:   strconv.Itoa(userID),
+            Audience:  jwt.ClaimStrings{k.audience},
+            IssuedAt:  jwt.NewNumericDate(now),
+            ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+        },
+        UserID:    userID,
+        SessionID: sessionID,
+    }
+
+    token := jwt.NewWithClaims(k.signing.method, claims)
+    if k.signing.id != "" {
+        token.Header["kid"] = k.signing.id
+    }
+    return token.SignedString(k.signing.private)
+}
+
+// Verify returns the claims of a token signed by one of the keys with its own
+// algorithm, issued by and for this server and not expired.
+func (k *Keyring) Verify(tokenString string) (*Claims, error) {
+    var claims Claims
+    parser := jwt.NewParser(jwt.WithValidMethods(k.methods))
+    _, err := parser.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+        kid, _ := token.Header["kid"].(string)
+        key, ok := k.keys[kid]
+        if !ok {
+            return nil, fmt.Errorf("unknown key %q", kid)
+        }
+        // A key only verifies its own algorithm, e.g. never HS256 with an RSA public key
+        if token.Method.Alg() != key.method.Alg() {
+            return nil, fmt.Errorf("key %q does not sign %s", kid, token.Method.Alg())
+        }
+        return key.public, nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+    }
+
+    switch {
+    case !claims.VerifyIssuer(k.issuer, true):
+        return nil, fmt.Errorf("%w: issuer %q", ErrInvalidToken, claims.Issuer)
+    case !claims.VerifyAudience(k.audience, true):
+        return nil, fmt.Errorf("%w: wrong audience", ErrInvalidToken)
+    case claims.ExpiresAt == nil:
+        return nil, fmt.Errorf("%w: no expiry", ErrInvalidToken)
+    case claims.UserID == 0 || claims.SessionID == "":
+        return nil, fmt.Errorf("%w: no session", ErrInvalidToken)
+    }
+    return &claims, nil
+}
+
+// JWKS returns the public keys tokens are verified with, none for a shared secret.
+func (k *Keyring) JWKS() jwk.Set {
+    set := jwk.Set{Keys: []jwk.Key{}}
+    for _, id := range k.order {
+        key := k.keys[id]
+        if key.id == "" {
+            continue
+        }
+        description, err := jwk.New(key.id, key.method.Alg(), key.public)
+        if err != nil {
+            continue
+        }
+        set.Keys = append(set.Keys, description)
+    }
+    return set
+}
+
+var keyring *Keyring
+
+// Init loads the keys from the config.
+func Init() {
+    cfg := config.GetConfig()
+
+    if cfg.Auth.SigningKeyFile == "" {
+        keyring = NewHMAC([]byte(cfg.JWTSecret), cfg.Auth.TokenIssuer, cfg.Auth.TokenAudience)
+        log.Printf("Signing access tokens with HS256")
+        return
+    }
+
+    signer, err := loadKey(cfg.Auth.SigningKeyFile)
+    if err != nil {
+        log.Fatalf("Unable to load JWT signing key: %v", err)
+    }
+    private, ok := signer.(crypto.Signer)
+    if !ok {
+        log.Fatalf("Unable to load JWT signing key: %s is not a private key", cfg.Auth.SigningKeyFile)
+    }
+
+    var previous []crypto.PublicKey
+    for _, path := range cfg.Auth.VerificationKeyFiles {
+        key, err := loadKey(path)
+        if err != nil {
+            log.Fatalf("Unable to load JWT verification key: %v", err)
+        }
+        // A retired private key verifies as well as its public key
+        if signer, ok := key.(crypto.Signer); ok {
+            key = signer.Public()
+        }
+        previous = append(previous, key)
+    }
+
+    keyring, err = NewAsymmetric(private, previous, cfg.Auth.TokenIssuer, cfg.Auth.TokenAudience)
+    if err != nil {
+        log.Fatalf("Unable to load JWT keys: %v", err)
+    }
+    log.Printf("Signing access tokens with %s key %s", keyring.signing.method.Alg(), keyring.signing.id)
+}
+
+// Get returns the keyring.
+func Get() *Keyring {
+    return keyring
+}
+
+// Set replaces the keyring, e.g. with a freshly generated key in tests.
+func Set(k *Keyring) {
+    keyring = k
+}
+
+// loadKey reads a PEM encoded private key (PKCS#8 or PKCS#1) or public key (PKIX).
+func loadKey(path string) (interface{}, error) {
+    content, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    block, _ := pem.Decode(content)
+    if block == nil {
+        return nil, fmt.Errorf("%s: no PEM block", path)
+    }
+
+    switch block.Type {
+    case "PRIVATE KEY":
+        return x509.ParsePKCS8PrivateKey(block.Bytes)
+    case "RSA PRIVATE KEY":
+        return x509.ParsePKCS1PrivateKey(block.Bytes)
+    case "PUBLIC KEY":
+        return x509.ParsePKIXPublicKey(block.Bytes)
+    }
+    return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+}
@@ -0,0 +1,254 @@
+package authtoken
+
+import (
+    "crypto"
+    "crypto/ed25519"
+    "crypto/rand"
+    "crypto/rsa"
+    "errors"
+    "reflect"
+    "strconv"
+    "testing"
+    "time"
+
+    "github.com/golang-jwt/jwt/v4"
+)
+
+const (
+    testIssuer   = "https://hireeasy.test"
+    testAudience = "hireeasy-api"
+)
+
+var (
+    rsaKey     *rsa.PrivateKey
+    ed25519Key ed25519.PrivateKey
+)
+
+func init() {
+    var err error
+    if rsaKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+        panic(err)
+    }
+    if _, ed25519Key, err = ed25519.GenerateKey(rand.Reader); err != nil {
+        panic(err)
+    }
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+    _, private, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return private
+}
+
+func testKeyring(t *testing.T, signer crypto.Signer, previous ...crypto.PublicKey) *Keyring {
+    keyring, err := NewAsymmetric(signer, previous, testIssuer, testAudience)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return keyring
+}
+
+// forge signs claims for user 1 the way Sign would, but with any method, key and kid.
+func forge(t *testing.T, method jwt.SigningMethod, signingKey interface{}, kid string, claims Claims) string {
+    token := jwt.NewWithClaims(method, claims)
+    if kid != "" {
+        token.Header["kid"] = kid
+    }
+    signed, err := token.SignedString(signingKey)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return signed
+}
+
+func validClaims() Claims {
+    now := time.Now()
+    return Claims{
+        RegisteredClaims: jwt.RegisteredClaims{
+            Issuer:    testIssuer,
+            Subject:   strconv.Itoa(1),
+            Audience:  jwt.ClaimStrings{testAudience},
+            IssuedAt:  jwt.NewNumericDate(now),
+            ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+        },
+        UserID:    1,
+        SessionID: "session",
+    }
+}
+
+func TestSignAndVerify(t *testing.T) {
+    for name, keyring := range map[string]*Keyring{
+        "RS256": testKeyring(t, rsaKey),
+        "EdDSA": testKeyring(t, ed25519Key),
+        "HS256": NewHMAC([]byte("secret"), testIssuer, testAudience),
+    } {
+        token, err := keyring.Sign(7, "session", time.Minute)
+        if err != nil {
+            t.Fatalf("%s: Sign: %v", name, err)
+        }
+        claims, err := keyring.Verify(token)
+        if err != nil {
+            t.Fatalf("%s: Verify: %v", name, err)
+        }
+        if claims.UserID != 7 || claims.SessionID != "session" || claims.Subject != "7" {
+            t.Fatalf("%s: claims %+v", name, claims)
+        }
+    }
+}
+
+func TestVerifyRejectsSymmetricAndNone(t *testing.T) {
+    for name, keyring := range map[string]*Keyring{
+        "RS256": testKeyring(t, rsaKey),
+        "EdDSA": testKeyring(t, ed25519Key),
+    } {
+        kid := keyring.signing.id
+
+        // The classic confusion: HMAC with the public key, which anyone can fetch
+        public, _ := keyring.JWKS().Find(kid)
+        hs256 := forge(t, jwt.SigningMethodHS256, []byte(public.N+public.X), kid, validClaims())
+        if _, err := keyring.Verify(hs256); !errors.Is(err, ErrInvalidToken) {
+            t.Fatalf("%s: HS256 token: got %v, want ErrInvalidToken", name, err)
+        }
+
+        none := forge(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, kid, validClaims())
+        if _, err := keyring.Verify(none); !errors.Is(err, ErrInvalidToken) {
+            t.Fatalf("%s: unsigned token: got %v, want ErrInvalidToken", name, err)
+        }
+    }
+}
+
+func TestVerifyChecksAlgorithmOfKey(t *testing.T) {
+    // Both algorithms are accepted, but each only from its own key
+    keyring := testKeyring(t, ed25519Key, rsaKey.Public())
+    rsaKid := keyring.order[1]
+
+    token := forge(t, jwt.SigningMethodEdDSA, ed25519Key, rsaKid, validClaims())
+    if _, err := keyring.Verify(token); !errors.Is(err, ErrInvalidToken) {
+        t.Fatalf("EdDSA under the RSA kid: got %v, want ErrInvalidToken", err)
+    }
+    token = forge(t, jwt.SigningMethodRS256, rsaKey, rsaKid, validClaims())
+    if _, err := keyring.Verify(token); err != nil {
+        t.Fatalf("RS256 under the RSA kid: %v", err)
+    }
+}
+
+func TestVerifyRejectsUnknownKey(t *testing.T) {
+    keyring := testKeyring(t, ed25519Key)
+    other := testKeyring(t, newEd25519Key(t))
+
+    token, err := other.Sign(1, "session", time.Minute)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := keyring.Verify(token); !errors.Is(err, ErrInvalidToken) {
+        t.Fatalf("unknown kid: got %v, want ErrInvalidToken", err)
+    }
+
+    // Nor does a token of another key pass under a known kid
+    forged := forge(t, jwt.SigningMethodEdDSA, other.signing.private, keyring.signing.id, validClaims())
+    if _, err := keyring.Verify(forged); !errors.Is(err, ErrInvalidToken) {
+        t.Fatalf("known kid, other key: got %v, want ErrInvalidToken", err)
+    }
+}
+
+func TestVerifyChecksClaims(t *testing.T) {
+    keyring := testKeyring(t, ed25519Key)
+
+    for name, change := range map[string]func(*Claims){
+        "wrong issuer":   func(c *Claims) { c.Issuer = "https://elsewhere.test" },
+        "wrong audience": func(c *Claims) { c.Audience = jwt.ClaimStrings{"another-api"} },
+        "expired":        func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Second)) },
+        "no expiry":      func(c *Claims) { c.ExpiresAt = nil },
+        "no session":     func(c *Claims) { c.SessionID = "" },
+    } {
+        claims := validClaims()
+        change(&claims)
+        token := forge(t, jwt.SigningMethodEdDSA, ed25519Key, keyring.signing.id, claims)
+        if _, err := keyring.Verify(token); !errors.Is(err, ErrInvalidToken) {
+            t.Fatalf("%s: got %v, want ErrInvalidToken", name, err)
+        }
+    }
+
+    expired, err := keyring.Sign(1, "session", -time.Minute)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := keyring.Verify(expired); !errors.Is(err, ErrInvalidToken) {
+        t.Fatalf("signed expired: got %v, want ErrInvalidToken", err)
+    }
+}
+
+func TestRotation(t *testing.T) {
+    old := testKeyring(t, ed25519Key)
+    token, err := old.Sign(1, "session", time.Minute)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // The retired key still verifies what it signed until it is dropped
+    next := newEd25519Key(t)
+    rotated := testKeyring(t, next, ed25519Key.Public())
+    if _, err := rotated.Verify(token); err != nil {
+        t.Fatalf("token of the retired key: %v", err)
+    }
+    fresh, _ := rotated.Sign(1, "session", time.Minute)
+    if _, err := rotated.Verify(fresh); err != nil {
+        t.Fatalf("token of the new key: %v", err)
+    }
+
+    dropped := testKeyring(t, next)
+    if _, err := dropped.Verify(token); !errors.Is(err, ErrInvalidToken) {
+        t.Fatalf("after dropping the retired key: got %v, want ErrInvalidToken", err)
+    }
+}
+
+func TestJWKS(t *testing.T) {
+    keyring := testKeyring(t, rsaKey, ed25519Key.Public(), rsaKey.Public())
+
+    set := keyring.JWKS()
+    if len(set.Keys) != 2 {
+        t.Fatalf("%d keys, want the signing key and the retired one once", len(set.Keys))
+    }
+    for i, want := range []struct {
+        kty, alg string
+        public   crypto.PublicKey
+    }{
+        {"RSA", "RS256", rsaKey.Public()},
+        {"OKP", "EdDSA", ed25519Key.Public()},
+    } {
+        key := set.Keys[i]
+        if key.Kty != want.kty || key.Alg != want.alg || key.Use != "sig" {
+            t.Fatalf("key %d: %+v, want %s %s", i, key, want.kty, want.alg)
+        }
+        thumbprint, err := key.Thumbprint()
+        if err != nil || key.Kid != thumbprint {
+            t.Fatalf("key %d: kid %q, thumbprint %q (%v)", i, key.Kid, thumbprint, err)
+        }
+        public, err := key.PublicKey()
+        if err != nil || !reflect.DeepEqual(public, want.public) {
+            t.Fatalf("key %d does not decode to the configured key: %v", i, err)
+        }
+    }
+
+    token, _ := keyring.Sign(1, "session", time.Minute)
+    parsed, _, _ := jwt.NewParser().ParseUnverified(token, &Claims{})
+    if parsed.Header["kid"] != set.Keys[0].Kid {
+        t.Fatalf("token kid %v, want the first key in the set", parsed.Header["kid"])
+    }
+
+    if keys := NewHMAC([]byte("secret"), testIssuer, testAudience).JWKS().Keys; len(keys) != 0 {
+        t.Fatalf("shared secret published as %+v", keys)
+    }
+}
+
+func TestNewAsymmetricRejectsWeakKeys(t *testing.T) {
+    weak, err := rsa.GenerateKey(rand.Reader, 1024)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := NewAsymmetric(weak, nil, testIssuer, testAudience); err == nil {
+        t.Fatal("1024 bit RSA key accepted")
+    }
+}
@@ -1,42 +1,405 @@
 package config
 
 import (
+    "bytes"
+    "errors"
+    "fmt"
+    "net"
     "os"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+
+    "gopkg.in/yaml.v3"
 )
 
+// Config is loaded in three layers, later ones winning: the defaults below, an
+// optional YAML file named by CONFIG_FILE, then the environment variables named
+// in the env tags. Fields tagged secret are redacted by Redacted.
 type Config struct {
-    AWSRegion   string
-    AWSEndpoint string
-    JWTSecret string
-    DBConfig postgresConfig
+    Server      serverConfig     `yaml:"server"`
+    AWSRegion   string           `yaml:"aws_region" env:"AWS_REGION"`
+    AWSEndpoint string           `yaml:"aws_endpoint" env:"AWS_ENDPOINT"`
+    JWTSecret   string           `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+    Auth        authConfig       `yaml:"auth"`
+    LoginGuard  loginGuardConfig `yaml:"login_guard"`
+    OIDC        oidcConfig       `yaml:"oidc"`
+    DBConfig    postgresConfig   `yaml:"database"`
+    Storage     storageConfig    `yaml:"storage"`
+    Mail        mailConfig       `yaml:"mail"`
+    AppURL      string           `yaml:"app_url" env:"APP_URL"` // frontend base URL used in mailed links
+}
+
+type serverConfig struct {
+    Host            string        `yaml:"host" env:"SERVER_HOST"` // interface to listen on, empty for all
+    Port            int           `yaml:"port" env:"PORT"`
+    TLSCertFile     string        `yaml:"tls_cert_file" env:"SERVER_TLS_CERT_FILE"` // PEM certificate chain, serves HTTPS when set with the key
+    TLSKeyFile      string        `yaml:"tls_key_file" env:"SERVER_TLS_KEY_FILE"`
+    ReadTimeout     time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+    WriteTimeout    time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+    IdleTimeout     time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+    ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"`
+    CORSOrigins     []string      `yaml:"cors_origins" env:"CORS_ORIGINS"` // empty allows any origin
+    TrustedProxies  []string      `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"` // IPs or CIDRs whose X-Forwarded-For is believed, empty trusts none
+    ProbeAddr       string        `yaml:"probe_addr" env:"SERVER_PROBE_ADDR"` // host:port of the internal listener serving /health/db, empty disables it
+}
+
+type authConfig struct {
+    AccessTokenTTL  time.Duration `yaml:"access_token_ttl" env:"ACCESS_TOKEN_TTL"`
+    RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL"` // also the lifetime of a session
+    TOTPIssuer      string        `yaml:"totp_issuer" env:"TOTP_ISSUER"` // name authenticator apps show for the account
+
+    // Access tokens are signed with JWT_SECRET (HS256) unless a signing key is set
+    SigningKeyFile       string   `yaml:"signing_key_file" env:"JWT_SIGNING_KEY_FILE"` // PEM RSA or Ed25519 private key
+    VerificationKeyFiles []string `yaml:"verification_key_files" env:"JWT_VERIFICATION_KEY_FILES"` // PEM keys of retired signing keys, accepted until their tokens expire
+    TokenIssuer          string   `yaml:"token_issuer" env:"JWT_ISSUER"`
+    TokenAudience        string   `yaml:"token_audience" env:"JWT_AUDIENCE"`
+}
+
+// loginGuardConfig slows down password guessing. After threshold failures within
+// window, each further failure doubles the wait before the next try, from base_delay
+// up to max_delay.
+type loginGuardConfig struct {
+    Backend string `yaml:"backend" env:"LOGIN_GUARD_BACKEND"` // "postgres" or "memory"
+    AccountThreshold int `yaml:"account_threshold" env:"LOGIN_GUARD_ACCOUNT_THRESHOLD"`
+    IPThreshold int `yaml:"ip_threshold" env:"LOGIN_GUARD_IP_THRESHOLD"` // higher, many users can share an address
+    BaseDelay time.Duration `yaml:"base_delay" env:"LOGIN_GUARD_BASE_DELAY"`
+    MaxDelay time.Duration `yaml:"max_delay" env:"LOGIN_GUARD_MAX_DELAY"`
+    Window time.Duration `yaml:"window" env:"LOGIN_GUARD_WINDOW"` // failures older than this are forgotten
+    AuditRetention time.Duration `yaml:"audit_retention" env:"LOGIN_GUARD_AUDIT_RETENTION"` // failed logins are audited this long
+}
+
+// oidcConfig turns on single sign-on through an OpenID Connect provider.
+type oidcConfig struct {
+    Issuer string `yaml:"issuer" env:"OIDC_ISSUER"` // empty turns single sign-on off
+    ClientID string `yaml:"client_id" env:"OIDC_CLIENT_ID"`
+    ClientSecret string `yaml:"client_secret" env:"OIDC_CLIENT_SECRET" secret:"true"` // empty for a public client
+    RedirectURL string `yaml:"redirect_url" env:"OIDC_REDIRECT_URL"` // frontend page receiving the code, defaults to APP_URL/sso/callback
+    AllowedDomains []string `yaml:"allowed_domains" env:"OIDC_ALLOWED_DOMAINS"` // email domains that may log in, empty allows any
+}
+
+type storageConfig struct {
+    Backend string `yaml:"backend" env:"STORAGE_BACKEND"` // "local" or "s3"
+    LocalPath string `yaml:"local_path" env:"STORAGE_LOCAL_PATH"`
+    S3Bucket string `yaml:"s3_bucket" env:"S3_BUCKET"`
+    SigningSecret string `yaml:"signing_secret" env:"STORAGE_SIGNING_SECRET" secret:"true"` // signs local download links, defaults to the JWT secret
+    MaxUploadBytes int64 `yaml:"max_upload_bytes" env:"STORAGE_MAX_UPLOAD_BYTES"`
+    URLExpiry time.Duration `yaml:"url_expiry" env:"STORAGE_URL_EXPIRY"`
+    // Candidates upload before they apply, so uploads are limited per job and client
+    // address over a day, and the ones no application refers to are deleted after UploadTTL
+    UploadQuotaFiles int `yaml:"upload_quota_files" env:"STORAGE_UPLOAD_QUOTA_FILES"`
+    UploadQuotaBytes int64 `yaml:"upload_quota_bytes" env:"STORAGE_UPLOAD_QUOTA_BYTES"`
+    UploadTTL time.Duration `yaml:"upload_ttl" env:"STORAGE_UPLOAD_TTL"`
+}
+
+type mailConfig struct {
+    Backend string `yaml:"backend" env:"MAIL_BACKEND"` // "log", "smtp", "file" or "memory"
+    From string `yaml:"from" env:"MAIL_FROM"`
+    SMTPHost string `yaml:"smtp_host" env:"SMTP_HOST"`
+    SMTPPort int `yaml:"smtp_port" env:"SMTP_PORT"`
+    SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+    SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+    Dir string `yaml:"dir" env:"MAIL_DIR"` // where the file backend writes messages
 }
 
 type postgresConfig struct {
-    Host string
-    Port string
-    Username string
-    Password string
-    Dbname string
+    Host string `yaml:"host" env:"DB_HOST"`
+    Port int `yaml:"port" env:"DB_PORT"`
+    Username string `yaml:"username" env:"DB_USER"`
+    Password string `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+    Dbname string `yaml:"dbname" env:"DB_NAME"`
+    MaxOpenConns int `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+    MaxIdleConns int `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+    ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"` // connections are replaced after this, e.g. to follow a failover
+    ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+    ConnectTimeout time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT"` // per connection attempt
+    StartupTimeout time.Duration `yaml:"startup_timeout" env:"DB_STARTUP_TIMEOUT"` // how long startup retries an unreachable database, 0 tries once
+    SSLMode string `yaml:"sslmode" env:"DB_SSLMODE"` // disable, require, verify-ca or verify-full
+    SSLRootCert string `yaml:"sslrootcert" env:"DB_SSLROOTCERT"` // PEM CA file the server certificate must chain to
 }
 
 var globalConfig *Config
 
-func LoadConfig() error {
-    globalConfig = &Config{
-        AWSRegion:   os.Getenv("AWS_REGION"),
-        AWSEndpoint: os.Getenv("AWS_ENDPOINT"),
-        JWTSecret: "abcdefghijklmno",
+func defaults() *Config {
+    return &Config{
+        Server: serverConfig{
+            Port: 8080,
+            ReadTimeout: 15 * time.Second,
+            WriteTimeout: 30 * time.Second,
+            IdleTimeout: 60 * time.Second,
+            ShutdownTimeout: 20 * time.Second,
+        },
+        Auth: authConfig{
+            AccessTokenTTL: 15 * time.Minute,
+            RefreshTokenTTL: 30 * 24 * time.Hour,
+            TOTPIssuer: "HireEasy",
+            TokenIssuer: "hireeasy",
+            TokenAudience: "hireeasy-api",
+        },
+        LoginGuard: loginGuardConfig{
+            Backend: "postgres",
+            AccountThreshold: 5,
+            IPThreshold: 20,
+            BaseDelay: 30 * time.Second,
+            MaxDelay: 15 * time.Minute,
+            Window: time.Hour,
+            AuditRetention: 90 * 24 * time.Hour,
+        },
         DBConfig: postgresConfig{
             Host: "localhost",
-            Port: "5432",
-            Username: "reshma",
-            Password: "postgres",
+            Port: 5432,
             Dbname: "app_db",
+            MaxOpenConns: 25,
+            MaxIdleConns: 5,
+            ConnMaxLifetime: 30 * time.Minute,
+            ConnMaxIdleTime: 5 * time.Minute,
+            ConnectTimeout: 5 * time.Second,
+            StartupTimeout: time.Minute,
+            SSLMode: "disable",
+        },
+        Mail: mailConfig{
+            Backend: "log",
+            From: "HireEasy <no-reply@hireeasy.local>",
+            SMTPPort: 587,
+            Dir: "mail",
+        },
+        AppURL: "http://localhost:5173",
+        Storage: storageConfig{
+            Backend: "local",
+            LocalPath: "uploads",
+            MaxUploadBytes: 10 << 20,
+            URLExpiry: 15 * time.Minute,
+            UploadQuotaFiles: 20,
+            UploadQuotaBytes: 50 << 20,
+            UploadTTL: 24 * time.Hour,
         },
     }
+}
+
+// LoadConfig builds the config and validates it; the server should not start on error.
+func LoadConfig() error {
+    cfg := defaults()
+
+    if path := os.Getenv("CONFIG_FILE"); path != "" {
+        content, err := os.ReadFile(path)
+        if err != nil {
+            return fmt.Errorf("reading config file: %w", err)
+        }
+        decoder := yaml.NewDecoder(bytes.NewReader(content))
+        decoder.KnownFields(true)
+        if err := decoder.Decode(cfg); err != nil {
+            return fmt.Errorf("parsing config file %s: %w", path, err)
+        }
+    }
+
+    if err := applyEnv(reflect.ValueOf(cfg).Elem()); err != nil {
+        return err
+    }
+
+    if cfg.Storage.SigningSecret == "" {
+        cfg.Storage.SigningSecret = cfg.JWTSecret
+    }
+
+    if cfg.OIDC.RedirectURL == "" {
+        cfg.OIDC.RedirectURL = strings.TrimSuffix(cfg.AppURL, "/") + "/sso/callback"
+    }
+
+    if err := cfg.validate(); err != nil {
+        return err
+    }
+
+    globalConfig = cfg
     return nil
 }
 
 func GetConfig() *Config {
     return globalConfig
 }
+
+// validate reports every invalid field at once.
+func (c *Config) validate() error {
+    var errs []error
+    check := func(ok bool, format string, args ...interface{}) {
+        if !ok {
+            errs = append(errs, fmt.Errorf(format, args...))
+        }
+    }
+
+    check(len(c.JWTSecret) >= 16, "JWT_SECRET is required and must be at least 16 characters")
+    check(c.Auth.AccessTokenTTL > 0, "ACCESS_TOKEN_TTL must be positive")
+    check(c.Auth.RefreshTokenTTL > c.Auth.AccessTokenTTL, "REFRESH_TOKEN_TTL must be longer than ACCESS_TOKEN_TTL")
+    check(c.Auth.TOTPIssuer != "" && !strings.Contains(c.Auth.TOTPIssuer, ":"), "TOTP_ISSUER is required and cannot contain a colon")
+    check(c.Auth.TokenIssuer != "", "JWT_ISSUER is required")
+    check(c.Auth.TokenAudience != "", "JWT_AUDIENCE is required")
+    check(c.Auth.SigningKeyFile != "" || len(c.Auth.VerificationKeyFiles) == 0,
+        "JWT_VERIFICATION_KEY_FILES needs JWT_SIGNING_KEY_FILE")
+    check(c.LoginGuard.Backend == "postgres" || c.LoginGuard.Backend == "memory",
+        "LOGIN_GUARD_BACKEND must be postgres or memory, got %q", c.LoginGuard.Backend)
+    check(c.LoginGuard.AccountThreshold > 0, "LOGIN_GUARD_ACCOUNT_THRESHOLD must be positive")
+    check(c.LoginGuard.IPThreshold > 0, "LOGIN_GUARD_IP_THRESHOLD must be positive")
+    check(c.LoginGuard.BaseDelay > 0, "LOGIN_GUARD_BASE_DELAY must be positive")
+    check(c.LoginGuard.MaxDelay >= c.LoginGuard.BaseDelay, "LOGIN_GUARD_MAX_DELAY must be at least LOGIN_GUARD_BASE_DELAY")
+    check(c.LoginGuard.Window >= c.LoginGuard.MaxDelay, "LOGIN_GUARD_WINDOW must be at least LOGIN_GUARD_MAX_DELAY")
+    check(c.LoginGuard.AuditRetention > 0, "LOGIN_GUARD_AUDIT_RETENTION must be positive")
+    if c.OIDC.Issuer != "" {
+        check(strings.HasPrefix(c.OIDC.Issuer, "https://") || strings.HasPrefix(c.OIDC.Issuer, "http://"),
+            "OIDC_ISSUER must be an http(s) URL")
+        check(c.OIDC.ClientID != "", "OIDC_CLIENT_ID is required for single sign-on")
+    }
+    check(c.Server.Port > 0 && c.Server.Port < 65536, "PORT must be between 1 and 65535")
+    check(c.Server.ReadTimeout > 0, "SERVER_READ_TIMEOUT must be positive")
+    check(c.Server.WriteTimeout > 0, "SERVER_WRITE_TIMEOUT must be positive")
+    check(c.Server.IdleTimeout > 0, "SERVER_IDLE_TIMEOUT must be positive")
+    check(c.Server.ShutdownTimeout > 0, "SERVER_SHUTDOWN_TIMEOUT must be positive")
+    for _, proxy := range c.Server.TrustedProxies {
+        _, _, cidrErr := net.ParseCIDR(proxy)
+        check(cidrErr == nil || net.ParseIP(proxy) != nil, "TRUSTED_PROXIES must be IP addresses or CIDRs, got %q", proxy)
+    }
+    check((c.Server.TLSCertFile == "") == (c.Server.TLSKeyFile == ""),
+        "SERVER_TLS_CERT_FILE and SERVER_TLS_KEY_FILE must be set together")
+    if c.Server.ProbeAddr != "" {
+        _, probePort, addrErr := net.SplitHostPort(c.Server.ProbeAddr)
+        port, portErr := strconv.Atoi(probePort)
+        check(addrErr == nil && portErr == nil && port > 0 && port < 65536 && port != c.Server.Port,
+            "SERVER_PROBE_ADDR must be host:port with a port other than PORT, got %q", c.Server.ProbeAddr)
+    }
+
+    check(c.DBConfig.Host != "", "DB_HOST is required")
+    check(c.DBConfig.Port > 0 && c.DBConfig.Port < 65536, "DB_PORT must be between 1 and 65535")
+    check(c.DBConfig.Username != "", "DB_USER is required")
+    check(c.DBConfig.Dbname != "", "DB_NAME is required")
+    check(c.DBConfig.MaxOpenConns > 0, "DB_MAX_OPEN_CONNS must be positive")
+    check(c.DBConfig.MaxIdleConns >= 0 && c.DBConfig.MaxIdleConns <= c.DBConfig.MaxOpenConns,
+        "DB_MAX_IDLE_CONNS must be between 0 and DB_MAX_OPEN_CONNS")
+    check(c.DBConfig.ConnMaxLifetime >= 0, "DB_CONN_MAX_LIFETIME cannot be negative")
+    check(c.DBConfig.ConnMaxIdleTime >= 0, "DB_CONN_MAX_IDLE_TIME cannot be negative")
+    check(c.DBConfig.ConnectTimeout >= time.Second, "DB_CONNECT_TIMEOUT must be at least 1s")
+    check(c.DBConfig.StartupTimeout >= 0, "DB_STARTUP_TIMEOUT cannot be negative")
+    switch c.DBConfig.SSLMode {
+    case "disable", "require", "verify-ca", "verify-full":
+    default:
+        errs = append(errs, fmt.Errorf("DB_SSLMODE must be disable, require, verify-ca or verify-full, got %q", c.DBConfig.SSLMode))
+    }
+    check(c.DBConfig.SSLRootCert == "" || c.DBConfig.SSLMode != "disable", "DB_SSLROOTCERT needs DB_SSLMODE other than disable")
+
+    switch c.Storage.Backend {
+    case "local":
+        check(c.Storage.LocalPath != "", "STORAGE_LOCAL_PATH is required for local storage")
+    case "s3":
+        check(c.AWSRegion != "", "AWS_REGION is required for s3 storage")
+        check(c.Storage.S3Bucket != "", "S3_BUCKET is required for s3 storage")
+    default:
+        errs = append(errs, fmt.Errorf("STORAGE_BACKEND must be local or s3, got %q", c.Storage.Backend))
+    }
+    switch c.Mail.Backend {
+    case "smtp":
+        check(c.Mail.SMTPHost != "", "SMTP_HOST is required for smtp mail")
+        check(c.Mail.SMTPPort > 0 && c.Mail.SMTPPort < 65536, "SMTP_PORT must be between 1 and 65535")
+    case "file":
+        check(c.Mail.Dir != "", "MAIL_DIR is required for file mail")
+    case "log", "memory":
+    default:
+        errs = append(errs, fmt.Errorf("MAIL_BACKEND must be log, smtp, file or memory, got %q", c.Mail.Backend))
+    }
+    check(c.Mail.From != "", "MAIL_FROM is required")
+    check(c.AppURL != "", "APP_URL is required")
+
+    check(c.Storage.MaxUploadBytes > 0, "STORAGE_MAX_UPLOAD_BYTES must be positive")
+    check(c.Storage.URLExpiry > 0, "STORAGE_URL_EXPIRY must be positive")
+    check(c.Storage.UploadQuotaFiles > 0, "STORAGE_UPLOAD_QUOTA_FILES must be positive")
+    check(c.Storage.UploadQuotaBytes >= c.Storage.MaxUploadBytes,
+        "STORAGE_UPLOAD_QUOTA_BYTES must be at least STORAGE_MAX_UPLOAD_BYTES")
+    check(c.Storage.UploadTTL >= time.Hour, "STORAGE_UPLOAD_TTL must be at least 1h")
+
+    if len(errs) > 0 {
+        return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+    }
+    return nil
+}
+
+// applyEnv overrides fields of v from the environment variables named in their env tags.
+func applyEnv(v reflect.Value) error {
+    for i := 0; i < v.NumField(); i++ {
+        field := v.Field(i)
+        info := v.Type().Field(i)
+
+        if field.Kind() == reflect.Struct {
+            if err := applyEnv(field); err != nil {
+                return err
+            }
+            continue
+        }
+
+        name := info.Tag.Get("env")
+        value, ok := os.LookupEnv(name)
+        if name == "" || !ok {
+            continue
+        }
+        if err := setField(field, value); err != nil {
+            return fmt.Errorf("%s: %w", name, err)
+        }
+    }
+    return nil
+}
+
+func setField(field reflect.Value, value string) error {
+    switch field.Interface().(type) {
+    case string:
+        field.SetString(value)
+    case int, int64:
+        n, err := strconv.ParseInt(value, 10, 64)
+        if err != nil {
+            return errors.New("must be a whole number")
+        }
+        field.SetInt(n)
+    case bool:
+        b, err := strconv.ParseBool(value)
+        if err != nil {
+            return errors.New("must be true or false")
+        }
+        field.SetBool(b)
+    case time.Duration:
+        d, err := time.ParseDuration(value)
+        if err != nil {
+            return errors.New("must be a duration such as 30s or 5m")
+        }
+        field.SetInt(int64(d))
+    case []string:
+        var items []string
+        for _, item := range strings.Split(value, ",") {
+            if item = strings.TrimSpace(item); item != "" {
+                items = append(items, item)
+            }
+        }
+        field.Set(reflect.ValueOf(items))
+    default:
+        return fmt.Errorf("unsupported config type %s", field.Type())
+    }
+    return nil
+}
+
+// Redacted returns the effective config as YAML with secrets masked, for debugging.
+func (c *Config) Redacted() (string, error) {
+    redacted := *c
+    redact(reflect.ValueOf(&redacted).Elem())
+
+    out, err := yaml.Marshal(&redacted)
+    if err != nil {
+        return "", err
+    }
+    return string(out), nil
+}
+
+func redact(v reflect.Value) {
+    for i := 0; i < v.NumField(); i++ {
+        field := v.Field(i)
+        if field.Kind() == reflect.Struct {
+            redact(field)
+            continue
+        }
+        if v.Type().Field(i).Tag.Get("secret") == "true" && field.String() != "" {
+            field.SetString("********")
+        }
+    }
+}
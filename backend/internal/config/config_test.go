@@ -0,0 +1,203 @@
+package config
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+// setRequired sets the settings that have no default, so the rest can be tested
+// one at a time.
+func setRequired(t *testing.T) {
+    t.Setenv("JWT_SECRET", "0123456789abcdef")
+    t.Setenv("DB_USER", "hireeasy")
+}
+
+func writeConfigFile(t *testing.T, content string) {
+    path := filepath.Join(t.TempDir(), "config.yaml")
+    if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    t.Setenv("CONFIG_FILE", path)
+}
+
+func TestLoadConfigEnvOverridesFile(t *testing.T) {
+    setRequired(t)
+    writeConfigFile(t, `
+server:
+  port: 9000
+  read_timeout: 5s
+database:
+  host: db.internal
+  max_open_conns: 50
+mail:
+  from: jobs@example.com
+`)
+    t.Setenv("PORT", "9100")
+    t.Setenv("DB_MAX_OPEN_CONNS", "40")
+
+    if err := LoadConfig(); err != nil {
+        t.Fatal(err)
+    }
+    cfg := GetConfig()
+    // The environment wins over the file, which wins over the defaults
+    if cfg.Server.Port != 9100 || cfg.DBConfig.MaxOpenConns != 40 {
+        t.Fatalf("env did not override the file: port %d, max open conns %d", cfg.Server.Port, cfg.DBConfig.MaxOpenConns)
+    }
+    if cfg.Server.ReadTimeout != 5*time.Second || cfg.DBConfig.Host != "db.internal" || cfg.Mail.From != "jobs@example.com" {
+        t.Fatalf("file settings were not applied: %+v", cfg)
+    }
+    if cfg.Server.WriteTimeout != 30*time.Second || cfg.DBConfig.Port != 5432 {
+        t.Fatalf("defaults were not kept: %+v", cfg)
+    }
+    // Derived settings follow what was loaded
+    if cfg.Storage.SigningSecret != "0123456789abcdef" || cfg.OIDC.RedirectURL != "http://localhost:5173/sso/callback" {
+        t.Fatalf("derived settings: signing secret %q, redirect %q", cfg.Storage.SigningSecret, cfg.OIDC.RedirectURL)
+    }
+}
+
+func TestLoadConfigRejectsUnknownKeys(t *testing.T) {
+    setRequired(t)
+    writeConfigFile(t, `
+server:
+  prot: 9000
+`)
+    err := LoadConfig()
+    if err == nil || !strings.Contains(err.Error(), "prot") {
+        t.Fatalf("misspelled key: got %v", err)
+    }
+}
+
+func TestLoadConfigReportsBadEnv(t *testing.T) {
+    setRequired(t)
+    t.Setenv("CONFIG_FILE", "")
+    t.Setenv("SERVER_READ_TIMEOUT", "30")
+
+    err := LoadConfig()
+    if err == nil || !strings.Contains(err.Error(), "SERVER_READ_TIMEOUT") {
+        t.Fatalf("duration without a unit: got %v", err)
+    }
+}
+
+func TestSetField(t *testing.T) {
+    var target struct {
+        S   string
+        I   int
+        I64 int64
+        B   bool
+        D   time.Duration
+        L   []string
+    }
+    v := reflect.ValueOf(&target).Elem()
+
+    for _, tc := range []struct {
+        field, value string
+        want         interface{}
+    }{
+        {"S", " as is ", " as is "},
+        {"I", "42", 42},
+        {"I64", "-7", int64(-7)},
+        {"B", "true", true},
+        {"B", "0", false},
+        {"D", "1h30m", 90 * time.Minute},
+        {"L", " 10.0.0.0/8, ,192.0.2.1 ", []string{"10.0.0.0/8", "192.0.2.1"}},
+        {"L", "", []string(nil)},
+    } {
+        if err := setField(v.FieldByName(tc.field), tc.value); err != nil {
+            t.Fatalf("%s = %q: %v", tc.field, tc.value, err)
+        }
+        if got := v.FieldByName(tc.field).Interface(); !reflect.DeepEqual(got, tc.want) {
+            t.Fatalf("%s = %q: got %#v, want %#v", tc.field, tc.value, got, tc.want)
+        }
+    }
+
+    for _, tc := range []struct{ field, value string }{
+        {"I", "4.5"},
+        {"I", "ten"},
+        {"B", "yes please"},
+        {"D", "30"},
+    } {
+        if err := setField(v.FieldByName(tc.field), tc.value); err == nil {
+            t.Fatalf("%s = %q was accepted", tc.field, tc.value)
+        }
+    }
+
+    var unsupported struct{ F float64 }
+    if err := setField(reflect.ValueOf(&unsupported).Elem().Field(0), "1.5"); err == nil {
+        t.Fatal("float field was accepted")
+    }
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+    cfg := defaults()
+    cfg.JWTSecret = "short"
+    cfg.Server.Port = 0
+    cfg.DBConfig.SSLMode = "sometimes"
+    cfg.Storage.Backend = "ftp"
+    cfg.Mail.Backend = "pigeon"
+    cfg.Server.ProbeAddr = "9090"
+
+    err := cfg.validate()
+    if err == nil {
+        t.Fatal("invalid config accepted")
+    }
+    // DB_USER has no default, so it is reported as well
+    for _, name := range []string{"JWT_SECRET", "PORT", "DB_USER", "DB_SSLMODE", "STORAGE_BACKEND", "MAIL_BACKEND", "SERVER_PROBE_ADDR"} {
+        if !strings.Contains(err.Error(), name) {
+            t.Fatalf("%s is not reported in:\n%v", name, err)
+        }
+    }
+
+    cfg = defaults()
+    cfg.JWTSecret = "0123456789abcdef"
+    cfg.DBConfig.Username = "hireeasy"
+    cfg.Server.ProbeAddr = "127.0.0.1:9090"
+    if err := cfg.validate(); err != nil {
+        t.Fatalf("defaults with the required settings: %v", err)
+    }
+}
+
+func TestRedactedMasksSecrets(t *testing.T) {
+    cfg := defaults()
+    // Fill every field tagged secret, wherever it is, with a value of its own
+    var secrets []string
+    var fill func(v reflect.Value)
+    fill = func(v reflect.Value) {
+        for i := 0; i < v.NumField(); i++ {
+            field := v.Field(i)
+            if field.Kind() == reflect.Struct {
+                fill(field)
+                continue
+            }
+            if v.Type().Field(i).Tag.Get("secret") == "true" {
+                secret := "secret-value-" + v.Type().Field(i).Name
+                field.SetString(secret)
+                secrets = append(secrets, secret)
+            }
+        }
+    }
+    fill(reflect.ValueOf(cfg).Elem())
+    if len(secrets) < 5 {
+        t.Fatalf("only %d secret fields found", len(secrets))
+    }
+
+    out, err := cfg.Redacted()
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, secret := range secrets {
+        if strings.Contains(out, secret) {
+            t.Fatalf("%s is not masked:\n%s", secret, out)
+        }
+    }
+    if n := strings.Count(out, "********"); n != len(secrets) {
+        t.Fatalf("%d masked values, want %d", n, len(secrets))
+    }
+    // Other settings stay readable, and the config itself is untouched
+    if !strings.Contains(out, "host: localhost") || cfg.JWTSecret != "secret-value-JWTSecret" {
+        t.Fatalf("redaction changed more than the secrets:\n%s", out)
+    }
+}
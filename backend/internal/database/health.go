@@ -0,0 +1,52 @@
+package database
+
+import (
+    "context"
+    "time"
+)
+
+// Health is the state of the database and its connection pool.
+type Health struct {
+    Status  string    `json:"status"` // "ok" or "unavailable"
+    Latency string    `json:"latency"` // of the ping
+    Pool    PoolStats `json:"pool"`
+}
+
+// PoolStats are counters of the connection pool, see sql.DBStats.
+type PoolStats struct {
+    MaxOpen           int    `json:"max_open"`
+    Open              int    `json:"open"`
+    InUse             int    `json:"in_use"`
+    Idle              int    `json:"idle"`
+    WaitCount         int64  `json:"wait_count"`    // connections waited for since startup
+    WaitDuration      string `json:"wait_duration"` // total time spent waiting
+    MaxIdleClosed     int64  `json:"max_idle_closed"`
+    MaxIdleTimeClosed int64  `json:"max_idle_time_closed"`
+    MaxLifetimeClosed int64  `json:"max_lifetime_closed"`
+}
+
+// CheckHealth pings the database and reports the pool statistics, along with the
+// ping error when the database is unavailable.
+func CheckHealth(ctx context.Context) (*Health, error) {
+    start := time.Now()
+    err := db.PingContext(ctx)
+
+    health := &Health{Status: "ok", Latency: time.Since(start).String()}
+    if err != nil {
+        health.Status = "unavailable"
+    }
+
+    stats := db.Stats()
+    health.Pool = PoolStats{
+        MaxOpen:           stats.MaxOpenConnections,
+        Open:              stats.OpenConnections,
+        InUse:             stats.InUse,
+        Idle:              stats.Idle,
+        WaitCount:         stats.WaitCount,
+        WaitDuration:      stats.WaitDuration.String(),
+        MaxIdleClosed:     stats.MaxIdleClosed,
+        MaxIdleTimeClosed: stats.MaxIdleTimeClosed,
+        MaxLifetimeClosed: stats.MaxLifetimeClosed,
+    }
+    return health, err
+}
@@ -0,0 +1,347 @@
+package database
+
+import (
+    "context"
+    "crypto/sha256"
+    "embed"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io/fs"
+    "log"
+    "regexp"
+    "sort"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/jmoiron/sqlx"
+    "github.com/lib/pq"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID keys the advisory lock that keeps two servers from migrating at once.
+const migrationLockID = 72707369
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// The embedded migrations never change while the binary runs, so they are read once
+var (
+    loadOnce sync.Once
+    loaded   []*Migration
+    loadErr  error
+)
+
+// Migration is a pair of migrations/<version>_<name>.up.sql and .down.sql files.
+type Migration struct {
+    Version  int
+    Name     string
+    Up       string
+    Down     string
+    Checksum string // sha256 of Up, recorded when applied
+}
+
+// MigrationState is a migration together with whether it has been applied.
+type MigrationState struct {
+    Version   int        `json:"version"`
+    Name      string     `json:"name"`
+    AppliedAt *time.Time `json:"applied_at,omitempty"`
+    Modified  bool       `json:"modified,omitempty"` // up file changed since it was applied
+}
+
+type appliedMigration struct {
+    Version   int       `db:"version"`
+    Checksum  string    `db:"checksum"`
+    AppliedAt time.Time `db:"applied_at"`
+}
+
+// LoadMigrations returns the embedded migrations, ordered by version. They are read
+// and hashed on the first call only; callers must not modify them.
+func LoadMigrations() ([]*Migration, error) {
+    loadOnce.Do(func() {
+        var dir fs.FS
+        dir, loadErr = fs.Sub(migrationFiles, "migrations")
+        if loadErr == nil {
+            loaded, loadErr = readMigrations(dir)
+        }
+    })
+    return loaded, loadErr
+}
+
+// readMigrations reads the migrations in the top directory of dir.
+func readMigrations(dir fs.FS) ([]*Migration, error) {
+    entries, err := fs.ReadDir(dir, ".")
+    if err != nil {
+        return nil, err
+    }
+
+    byVersion := map[int]*Migration{}
+    for _, entry := range entries {
+        match := migrationName.FindStringSubmatch(entry.Name())
+        if match == nil {
+            return nil, fmt.Errorf("migration %s: name must be <version>_<name>.(up|down).sql", entry.Name())
+        }
+
+        version, _ := strconv.Atoi(match[1])
+        m, ok := byVersion[version]
+        if !ok {
+            m = &Migration{Version: version, Name: match[2]}
+            byVersion[version] = m
+        }
+        if m.Name != match[2] {
+            return nil, fmt.Errorf("migration %d has two names, %s and %s", version, m.Name, match[2])
+        }
+
+        content, err := fs.ReadFile(dir, entry.Name())
+        if err != nil {
+            return nil, err
+        }
+        if match[3] == "up" {
+            m.Up = string(content)
+            sum := sha256.Sum256(content)
+            m.Checksum = hex.EncodeToString(sum[:])
+        } else {
+            m.Down = string(content)
+        }
+    }
+
+    migrations := make([]*Migration, 0, len(byVersion))
+    for _, m := range byVersion {
+        if m.Up == "" || m.Down == "" {
+            return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+        }
+        migrations = append(migrations, m)
+    }
+    sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+    return migrations, nil
+}
+
+// withMigrationLock runs fn on a single connection holding the migration lock,
+// after making sure the schema_migrations table exists.
+func withMigrationLock(ctx context.Context, db *sqlx.DB, fn func(conn *sqlx.Conn) error) error {
+    conn, err := db.Connx(ctx)
+    if err != nil {
+        return err
+    }
+    defer conn.Close()
+
+    if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+        return err
+    }
+    defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+    _, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name VARCHAR(255) NOT NULL,
+        checksum VARCHAR(64) NOT NULL,
+        applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    )`)
+    if err != nil {
+        return err
+    }
+
+    return fn(conn)
+}
+
+func appliedMigrations(ctx context.Context, q sqlx.QueryerContext) (map[int]appliedMigration, error) {
+    var rows []appliedMigration
+    if err := sqlx.SelectContext(ctx, q, &rows, "SELECT version, checksum, applied_at FROM schema_migrations"); err != nil {
+        return nil, err
+    }
+
+    applied := make(map[int]appliedMigration, len(rows))
+    for _, row := range rows {
+        applied[row.Version] = row
+    }
+    return applied, nil
+}
+
+// verifyChecksums refuses to continue when an applied migration was edited afterwards
+// or is missing from the binary.
+func verifyChecksums(migrations []*Migration, applied map[int]appliedMigration) error {
+    known := make(map[int]bool, len(migrations))
+    for _, m := range migrations {
+        known[m.Version] = true
+        if a, ok := applied[m.Version]; ok && a.Checksum != m.Checksum {
+            return fmt.Errorf("migration %d_%s was modified after it was applied", m.Version, m.Name)
+        }
+    }
+    for version := range applied {
+        if !known[version] {
+            return fmt.Errorf("migration %d is applied but unknown to this binary", version)
+        }
+    }
+    return nil
+}
+
+// MigrateUp applies every pending migration in order, each in its own transaction.
+func MigrateUp(ctx context.Context, db *sqlx.DB) error {
+    migrations, err := LoadMigrations()
+    if err != nil {
+        return err
+    }
+
+    return withMigrationLock(ctx, db, func(conn *sqlx.Conn) error {
+        applied, err := appliedMigrations(ctx, conn)
+        if err != nil {
+            return err
+        }
+        if err := verifyChecksums(migrations, applied); err != nil {
+            return err
+        }
+
+        for _, m := range migrations {
+            if _, ok := applied[m.Version]; ok {
+                continue
+            }
+
+            err := runInTx(ctx, conn, m.Up,
+                "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+                m.Version, m.Name, m.Checksum)
+            if err != nil {
+                return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+            }
+            log.Printf("Applied migration %d_%s", m.Version, m.Name)
+        }
+        return nil
+    })
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first.
+func MigrateDown(ctx context.Context, db *sqlx.DB, steps int) error {
+    migrations, err := LoadMigrations()
+    if err != nil {
+        return err
+    }
+
+    return withMigrationLock(ctx, db, func(conn *sqlx.Conn) error {
+        applied, err := appliedMigrations(ctx, conn)
+        if err != nil {
+            return err
+        }
+        if err := verifyChecksums(migrations, applied); err != nil {
+            return err
+        }
+
+        for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+            m := migrations[i]
+            if _, ok := applied[m.Version]; !ok {
+                continue
+            }
+
+            err := runInTx(ctx, conn, m.Down, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+            if err != nil {
+                return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+            }
+            log.Printf("Reverted migration %d_%s", m.Version, m.Name)
+            steps--
+        }
+        return nil
+    })
+}
+
+// runInTx runs a migration script and its bookkeeping statement atomically.
+func runInTx(ctx context.Context, conn *sqlx.Conn, script, bookkeeping string, args ...interface{}) error {
+    return WithTx(ctx, conn, func(tx *sqlx.Tx) error {
+        if _, err := tx.ExecContext(ctx, script); err != nil {
+            return err
+        }
+        _, err := tx.ExecContext(ctx, bookkeeping, args...)
+        return err
+    })
+}
+
+// MigrationStatus lists every known migration and whether it has been applied.
+func MigrationStatus(ctx context.Context, db *sqlx.DB) ([]MigrationState, error) {
+    migrations, err := LoadMigrations()
+    if err != nil {
+        return nil, err
+    }
+
+    var states []MigrationState
+    err = withMigrationLock(ctx, db, func(conn *sqlx.Conn) error {
+        applied, err := appliedMigrations(ctx, conn)
+        if err != nil {
+            return err
+        }
+
+        for _, m := range migrations {
+            state := MigrationState{Version: m.Version, Name: m.Name}
+            if a, ok := applied[m.Version]; ok {
+                appliedAt := a.AppliedAt
+                state.AppliedAt = &appliedAt
+                state.Modified = a.Checksum != m.Checksum
+            }
+            states = append(states, state)
+        }
+        return nil
+    })
+
+    return states, err
+}
+
+// SchemaState compares the applied migrations with the ones in this binary.
+type SchemaState struct {
+    Version int `json:"version"` // highest applied migration, 0 for none
+    Latest  int `json:"latest"`  // highest migration in this binary
+    Pending int `json:"pending"`
+    // Ahead counts applied migrations this binary does not know, e.g. while a
+    // newer release rolls out next to this one
+    Ahead int `json:"ahead"`
+}
+
+// Current reports whether every migration of this binary has been applied.
+func (s *SchemaState) Current() bool {
+    return s.Pending == 0
+}
+
+// CheckSchema reads the applied migrations without taking the migration lock, so it
+// is cheap enough for readiness probes and does not wait for a running migration.
+// Known migrations that were modified after they were applied are an error; unknown
+// applied ones are only counted, a newer binary added them.
+func CheckSchema(ctx context.Context, db *sqlx.DB) (*SchemaState, error) {
+    migrations, err := LoadMigrations()
+    if err != nil {
+        return nil, err
+    }
+
+    applied, err := appliedMigrations(ctx, db)
+    var pqErr *pq.Error
+    if errors.As(err, &pqErr) && pqErr.Code == "42P01" {
+        // schema_migrations does not exist yet, nothing was applied
+        applied, err = map[int]appliedMigration{}, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return schemaState(migrations, applied)
+}
+
+// schemaState compares the applied migrations with migrations.
+func schemaState(migrations []*Migration, applied map[int]appliedMigration) (*SchemaState, error) {
+    state := &SchemaState{}
+    known := make(map[int]bool, len(migrations))
+    for _, m := range migrations {
+        known[m.Version] = true
+        state.Latest = m.Version
+        a, ok := applied[m.Version]
+        switch {
+        case !ok:
+            state.Pending++
+        case a.Checksum != m.Checksum:
+            return nil, fmt.Errorf("migration %d_%s was modified after it was applied", m.Version, m.Name)
+        }
+    }
+    for version := range applied {
+        if !known[version] {
+            state.Ahead++
+        }
+        if version > state.Version {
+            state.Version = version
+        }
+    }
+    return state, nil
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "strings"
+    "testing"
+    "testing/fstest"
+    "time"
+)
+
+func file(content string) *fstest.MapFile {
+    return &fstest.MapFile{Data: []byte(content)}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+    migrations, err := LoadMigrations()
+    if err != nil {
+        t.Fatal(err)
+    }
+    // Versions count up from 1 without gaps, so a missing file shows
+    for i, m := range migrations {
+        if m.Version != i+1 {
+            t.Fatalf("migration %d_%s at position %d", m.Version, m.Name, i+1)
+        }
+    }
+}
+
+func TestReadMigrations(t *testing.T) {
+    migrations, err := readMigrations(fstest.MapFS{
+        "0002_jobs.up.sql":    file("CREATE TABLE jobs ();"),
+        "0002_jobs.down.sql":  file("DROP TABLE jobs;"),
+        "0001_users.up.sql":   file("CREATE TABLE users ();"),
+        "0001_users.down.sql": file("DROP TABLE users;"),
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(migrations) != 2 || migrations[0].Name != "users" || migrations[1].Name != "jobs" {
+        t.Fatalf("migrations %+v, want users then jobs", migrations)
+    }
+    sum := sha256.Sum256([]byte("CREATE TABLE users ();"))
+    if m := migrations[0]; m.Up != "CREATE TABLE users ();" || m.Down != "DROP TABLE users;" || m.Checksum != hex.EncodeToString(sum[:]) {
+        t.Fatalf("migration %+v", m)
+    }
+}
+
+func TestReadMigrationsRejectsBadFiles(t *testing.T) {
+    for name, dir := range map[string]fstest.MapFS{
+        "no direction": {"0001_users.sql": file("")},
+        "no version":   {"users.up.sql": file(""), "users.down.sql": file("")},
+        "other suffix": {"0001_users.up.sql.bak": file("")},
+        "dash in name": {"0001_create-users.up.sql": file(""), "0001_create-users.down.sql": file("")},
+        "no down file": {"0001_users.up.sql": file("CREATE TABLE users ();")},
+        "no up file":   {"0001_users.down.sql": file("DROP TABLE users;")},
+        "two names": {
+            "0001_users.up.sql":     file("CREATE TABLE users ();"),
+            "0001_members.down.sql": file("DROP TABLE users;"),
+        },
+    } {
+        if migrations, err := readMigrations(dir); err == nil {
+            t.Fatalf("%s: read %+v", name, migrations)
+        }
+    }
+}
+
+func testMigrations() []*Migration {
+    return []*Migration{
+        {Version: 1, Name: "users", Checksum: "a"},
+        {Version: 2, Name: "jobs", Checksum: "b"},
+        {Version: 3, Name: "applications", Checksum: "c"},
+    }
+}
+
+func TestVerifyChecksums(t *testing.T) {
+    at := time.Unix(1700000000, 0)
+    applied := map[int]appliedMigration{1: {1, "a", at}, 2: {2, "b", at}}
+    if err := verifyChecksums(testMigrations(), applied); err != nil {
+        t.Fatalf("applied as shipped: %v", err)
+    }
+
+    applied[2] = appliedMigration{2, "edited", at}
+    if err := verifyChecksums(testMigrations(), applied); err == nil || !strings.Contains(err.Error(), "2_jobs was modified") {
+        t.Fatalf("modified migration: got %v", err)
+    }
+
+    // An older binary must not migrate a schema a newer one moved on
+    applied = map[int]appliedMigration{1: {1, "a", at}, 4: {4, "d", at}}
+    if err := verifyChecksums(testMigrations(), applied); err == nil || !strings.Contains(err.Error(), "unknown") {
+        t.Fatalf("unknown newer migration: got %v", err)
+    }
+}
+
+func TestSchemaState(t *testing.T) {
+    at := time.Unix(1700000000, 0)
+    for _, tc := range []struct {
+        name    string
+        applied map[int]appliedMigration
+        want    SchemaState
+        current bool
+    }{
+        {"nothing applied", map[int]appliedMigration{}, SchemaState{Version: 0, Latest: 3, Pending: 3}, false},
+        {"behind", map[int]appliedMigration{1: {1, "a", at}}, SchemaState{Version: 1, Latest: 3, Pending: 2}, false},
+        {"current", map[int]appliedMigration{1: {1, "a", at}, 2: {2, "b", at}, 3: {3, "c", at}},
+            SchemaState{Version: 3, Latest: 3}, true},
+        // A newer release rolling out applied migration 4 already
+        {"ahead", map[int]appliedMigration{1: {1, "a", at}, 2: {2, "b", at}, 3: {3, "c", at}, 4: {4, "d", at}},
+            SchemaState{Version: 4, Latest: 3, Ahead: 1}, true},
+    } {
+        state, err := schemaState(testMigrations(), tc.applied)
+        if err != nil {
+            t.Fatalf("%s: %v", tc.name, err)
+        }
+        if *state != tc.want || state.Current() != tc.current {
+            t.Fatalf("%s: state %+v, want %+v", tc.name, *state, tc.want)
+        }
+    }
+
+    if _, err := schemaState(testMigrations(), map[int]appliedMigration{1: {1, "edited", at}}); err == nil {
+        t.Fatal("modified migration: no error")
+    }
+}
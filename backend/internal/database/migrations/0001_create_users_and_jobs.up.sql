@@ -1,6 +1,8 @@
+-- Baseline schema. IF NOT EXISTS lets databases set up by hand from the old
+-- schema.sql adopt the migration history.
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
-CREATE TABLE users (
+CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
     email VARCHAR(255) NOT NULL UNIQUE,
     password_hash VARCHAR(255) NOT NULL,
@@ -9,7 +11,7 @@ CREATE TABLE users (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
-CREATE TABLE jobs (
+CREATE TABLE IF NOT EXISTS jobs (
     id SERIAL PRIMARY KEY, --id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     job_id VARCHAR(255) NOT NULL,
     user_id INTEGER NOT NULL REFERENCES users(id),
@@ -23,7 +25,7 @@ CREATE TABLE jobs (
     );
 
 -- Add indexes for common queries
-CREATE INDEX idx_jobs_user_id ON jobs(user_id);
-CREATE INDEX idx_jobs_id ON jobs(job_id);
-CREATE INDEX idx_jobs_status ON jobs(job_status);
-CREATE INDEX idx_jobs_title ON jobs(job_title);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_jobs_user_id ON jobs(user_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_id ON jobs(job_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(job_status);
+CREATE INDEX IF NOT EXISTS idx_jobs_title ON jobs(job_title);
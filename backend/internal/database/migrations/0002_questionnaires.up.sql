@@ -0,0 +1,8 @@
+CREATE TABLE questionnaires (
+    id SERIAL PRIMARY KEY,
+    job_ref INTEGER NOT NULL UNIQUE REFERENCES jobs(id) ON DELETE CASCADE, -- one questionnaire per job
+    form_id VARCHAR(255) NOT NULL,
+    questions JSONB NOT NULL, -- [{id, text, type, options, required}]
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,13 @@
+CREATE TABLE applications (
+    id SERIAL PRIMARY KEY,
+    job_ref INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    candidate_name VARCHAR(255) NOT NULL,
+    candidate_email VARCHAR(255) NOT NULL,
+    responses JSONB NOT NULL DEFAULT '{}', -- question id -> answer, validated against the questionnaire
+    stage VARCHAR(50) NOT NULL DEFAULT 'applied', -- applied, screening, interview, offer, hired, rejected, withdrawn
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_applications_job_ref ON applications(job_ref);
+CREATE INDEX idx_applications_responses ON applications USING GIN (responses jsonb_path_ops); -- answer filters
@@ -0,0 +1,2 @@
+DROP TABLE application_transitions;
+DROP TABLE job_pipelines;
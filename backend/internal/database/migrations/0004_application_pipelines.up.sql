@@ -0,0 +1,19 @@
+CREATE TABLE job_pipelines (
+    id SERIAL PRIMARY KEY,
+    job_ref INTEGER NOT NULL UNIQUE REFERENCES jobs(id) ON DELETE CASCADE, -- jobs without a row use the default pipeline
+    transitions JSONB NOT NULL, -- stage -> stages it may move to
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE application_transitions (
+    id SERIAL PRIMARY KEY,
+    application_id INTEGER NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    from_stage VARCHAR(50) NOT NULL,
+    to_stage VARCHAR(50) NOT NULL,
+    actor_user_id INTEGER NOT NULL REFERENCES users(id),
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_application_transitions_application_id ON application_transitions(application_id);
@@ -0,0 +1,8 @@
+DROP INDEX idx_jobs_expires_at;
+DROP INDEX idx_jobs_publish_at;
+
+ALTER TABLE jobs
+    DROP COLUMN expires_at,
+    DROP COLUMN publish_at,
+    DROP CONSTRAINT jobs_job_status_check,
+    ALTER COLUMN job_status SET DEFAULT 'active';
@@ -0,0 +1,15 @@
+-- inactive predates the lifecycle, the closest status is paused
+UPDATE jobs SET job_status = 'paused' WHERE job_status = 'inactive';
+-- anything else free-form goes back to draft for its owner to review
+UPDATE jobs SET job_status = 'draft'
+    WHERE job_status NOT IN ('draft', 'scheduled', 'active', 'paused', 'closed', 'archived');
+
+ALTER TABLE jobs
+    ALTER COLUMN job_status SET DEFAULT 'draft',
+    ADD CONSTRAINT jobs_job_status_check
+        CHECK (job_status IN ('draft', 'scheduled', 'active', 'paused', 'closed', 'archived')),
+    ADD COLUMN publish_at TIMESTAMPTZ, -- scheduled -> active, applied by the job scheduler
+    ADD COLUMN expires_at TIMESTAMPTZ; -- active/paused -> closed, applied by the job scheduler
+
+CREATE INDEX idx_jobs_publish_at ON jobs(publish_at) WHERE job_status = 'scheduled';
+CREATE INDEX idx_jobs_expires_at ON jobs(expires_at) WHERE job_status IN ('active', 'paused');
@@ -0,0 +1,3 @@
+ALTER TABLE jobs
+    DROP COLUMN share_token_created_at,
+    DROP COLUMN share_token;
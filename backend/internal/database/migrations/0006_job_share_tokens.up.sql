@@ -0,0 +1,3 @@
+ALTER TABLE jobs
+    ADD COLUMN share_token VARCHAR(64) UNIQUE, -- public link for candidates, NULL when not shared
+    ADD COLUMN share_token_created_at TIMESTAMP;
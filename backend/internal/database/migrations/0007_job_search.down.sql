@@ -0,0 +1,6 @@
+DROP INDEX idx_jobs_attributes;
+DROP INDEX idx_jobs_skills_required;
+DROP INDEX idx_jobs_search_vector;
+DROP INDEX idx_jobs_user_created;
+
+ALTER TABLE jobs DROP COLUMN search_vector;
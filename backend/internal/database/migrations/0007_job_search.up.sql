@@ -0,0 +1,9 @@
+ALTER TABLE jobs ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(job_title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(job_description, '')), 'B')
+) STORED; -- full text search over title and description
+
+CREATE INDEX idx_jobs_user_created ON jobs(user_id, created_at, id); -- default listing order
+CREATE INDEX idx_jobs_search_vector ON jobs USING GIN (search_vector);
+CREATE INDEX idx_jobs_skills_required ON jobs USING GIN (skills_required);
+CREATE INDEX idx_jobs_attributes ON jobs USING GIN (attributes jsonb_path_ops);
@@ -0,0 +1,17 @@
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ;
+
+-- Accounts created before verification existed keep publishing jobs
+UPDATE users SET email_verified_at = created_at;
+
+-- single use tokens mailed to users
+CREATE TABLE user_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(50) NOT NULL, -- password_reset, email_verification
+    token_hash CHAR(64) NOT NULL UNIQUE, -- sha256 hex, the token itself is never stored
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_user_tokens_user_id ON user_tokens(user_id, purpose);
@@ -0,0 +1,7 @@
+CREATE INDEX idx_jobs_user_created ON jobs(user_id, created_at, id);
+DROP INDEX idx_jobs_org_created;
+
+ALTER TABLE jobs DROP COLUMN org_id;
+
+DROP TABLE memberships;
+DROP TABLE organizations;
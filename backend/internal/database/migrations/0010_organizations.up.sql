@@ -0,0 +1,31 @@
+CREATE TABLE organizations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    personal_for INTEGER -- only used while backfilling below
+);
+
+CREATE TABLE memberships (
+    org_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL CHECK (role IN ('owner', 'admin', 'recruiter', 'interviewer', 'viewer')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX idx_memberships_user_id ON memberships(user_id);
+
+-- Every existing user gets an organization of their own that takes over their jobs
+INSERT INTO organizations (name, personal_for) SELECT username, id FROM users;
+INSERT INTO memberships (org_id, user_id, role) SELECT id, personal_for, 'owner' FROM organizations;
+
+-- jobs.user_id stays as the creator of the job
+ALTER TABLE jobs ADD COLUMN org_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE jobs SET org_id = o.id FROM organizations o WHERE o.personal_for = jobs.user_id;
+ALTER TABLE jobs ALTER COLUMN org_id SET NOT NULL;
+
+ALTER TABLE organizations DROP COLUMN personal_for;
+
+CREATE INDEX idx_jobs_org_created ON jobs(org_id, created_at, id);
+DROP INDEX idx_jobs_user_created;
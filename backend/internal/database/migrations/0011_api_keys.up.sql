@@ -0,0 +1,16 @@
+-- Keys for scripts, acting as their user with at most their scopes
+CREATE TABLE api_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    org_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE, -- NULL when usable in any of the user's organizations
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(32) NOT NULL, -- start of the key, shown to tell keys apart
+    key_hash CHAR(64) NOT NULL UNIQUE, -- sha256 hex, the key itself is never stored
+    scopes VARCHAR(50)[] NOT NULL, -- permissions the key may use
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ, -- NULL never expires
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
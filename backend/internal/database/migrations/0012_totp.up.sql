@@ -0,0 +1,25 @@
+ALTER TABLE users
+    ADD COLUMN totp_secret VARCHAR(64), -- base32, set while enrolling and once enabled
+    ADD COLUMN totp_enabled_at TIMESTAMPTZ, -- NULL until a code confirmed the enrollment
+    ADD COLUMN totp_last_step BIGINT; -- time step of the last accepted code, codes cannot be replayed
+
+CREATE TABLE recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash CHAR(64) NOT NULL, -- sha256 hex
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes(user_id);
+
+-- The first step of a login with a second factor, completed with a code
+CREATE TABLE mfa_challenges (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL UNIQUE, -- sha256 hex
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
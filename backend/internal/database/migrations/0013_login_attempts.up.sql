@@ -0,0 +1,18 @@
+-- Recent failed logins per account or client address, used for backoff and lockout
+CREATE TABLE login_attempts (
+    key VARCHAR(320) PRIMARY KEY, -- account:<email> or ip:<address>
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMPTZ NOT NULL
+);
+
+-- One row per failed login, kept for auditing
+CREATE TABLE login_failures (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(64) NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    reason VARCHAR(50) NOT NULL, -- unknown_email, wrong_password or locked
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_failures_email ON login_failures(email, created_at);
@@ -0,0 +1,5 @@
+DROP TABLE sso_logins;
+DROP TABLE user_identities;
+
+UPDATE users SET password_hash = '' WHERE password_hash IS NULL;
+ALTER TABLE users ALTER COLUMN password_hash SET NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE jobs DROP CONSTRAINT jobs_org_id_job_id_key;
@@ -0,0 +1,7 @@
+-- Job ids were only checked before inserting, so concurrent creates could store
+-- the same one twice. Keep the oldest and rename the others before adding the constraint.
+UPDATE jobs SET job_id = jobs.job_id || '-' || jobs.id
+FROM jobs first
+WHERE first.org_id = jobs.org_id AND first.job_id = jobs.job_id AND first.id < jobs.id;
+
+ALTER TABLE jobs ADD CONSTRAINT jobs_org_id_job_id_key UNIQUE (org_id, job_id);
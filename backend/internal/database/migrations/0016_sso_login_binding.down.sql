@@ -0,0 +1,3 @@
+DROP INDEX idx_sso_logins_expires_at;
+
+ALTER TABLE sso_logins DROP COLUMN binding_hash;
@@ -0,0 +1,5 @@
+-- Ties a single sign-on login to the browser that started it, see the sso_login cookie.
+-- Logins started before this migration have no binding and cannot complete.
+ALTER TABLE sso_logins ADD COLUMN binding_hash CHAR(64); -- sha256 hex
+
+CREATE INDEX idx_sso_logins_expires_at ON sso_logins(expires_at);
@@ -0,0 +1,14 @@
+-- Files candidates uploaded, for upload quotas and for deleting the ones no
+-- application refers to. Files uploaded before this migration are not tracked.
+-- job_ref has no foreign key: rows of deleted jobs stay until their files are swept.
+CREATE TABLE uploads (
+    id BIGSERIAL PRIMARY KEY,
+    job_ref INTEGER NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    size BIGINT NOT NULL,
+    ip_address VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_uploads_job_ref ON uploads(job_ref, ip_address, created_at);
+CREATE INDEX idx_uploads_created_at ON uploads(created_at);
@@ -0,0 +1,7 @@
+-- The application a file was submitted with. A file can only be claimed once, so
+-- candidates cannot submit the keys of files someone else uploaded and applied with.
+ALTER TABLE uploads ADD COLUMN application_id INTEGER REFERENCES applications(id) ON DELETE SET NULL;
+
+UPDATE uploads u SET application_id = a.id
+FROM applications a, jsonb_each_text(a.responses) r
+WHERE a.job_ref = u.job_ref AND r.value = u.storage_key;
@@ -1,38 +1,106 @@
 package database
 
 import (
+    "context"
     "fmt"
     "log"
+    "strings"
+    "time"
     "github.com/jmoiron/sqlx"
     _ "github.com/lib/pq" //postgres driver
     "backend/internal/config"
 )
 
+// Startup retries wait this long after the first failed attempt, doubling up to maxRetryDelay.
+const (
+    firstRetryDelay = 500 * time.Millisecond
+    maxRetryDelay   = 10 * time.Second
+)
+
 var db *sqlx.DB
 
-// Connect establishes a connection to the PostgreSQL database.
+// Connect establishes a connection to the PostgreSQL database. A database that is
+// not up yet, e.g. still starting next to the server in docker-compose, is retried
+// with backoff for DB_STARTUP_TIMEOUT before giving up.
 func Connect() {
 
     cfg := config.GetConfig().DBConfig
-    
-    psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-    cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.Dbname)
 
     var err error
-    db, err = sqlx.Connect("postgres", psqlInfo)
+    db, err = sqlx.Open("postgres", dataSourceName())
     if err != nil {
         log.Fatalf("Unable to connect to database: %v", err)
     }
 
-    err = db.Ping()
-    if err != nil {
+    db.SetMaxOpenConns(cfg.MaxOpenConns)
+    db.SetMaxIdleConns(cfg.MaxIdleConns)
+    db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+    db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+    ctx, cancel := context.WithTimeout(context.Background(), cfg.StartupTimeout)
+    defer cancel()
+
+    if err := waitForDatabase(ctx, cfg.ConnectTimeout); err != nil {
         log.Fatalf("Unable to reach the database: %v", err)
     }
 
     log.Println("Successfully connected to the database")
 }
 
+// waitForDatabase pings until the database answers or ctx is done. The first
+// attempt always runs, so an expired ctx still tries once.
+func waitForDatabase(ctx context.Context, attemptTimeout time.Duration) error {
+    delay := firstRetryDelay
+    for attempt := 1; ; attempt++ {
+        attemptCtx, cancel := context.WithTimeout(context.Background(), attemptTimeout)
+        err := db.PingContext(attemptCtx)
+        cancel()
+        if err == nil {
+            return nil
+        }
+
+        log.Printf("Database not reachable (attempt %d), retrying in %s: %v", attempt, delay, err)
+        select {
+        case <-ctx.Done():
+            return fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+        case <-time.After(delay):
+        }
+
+        delay *= 2
+        if delay > maxRetryDelay {
+            delay = maxRetryDelay
+        }
+    }
+}
+
+// dataSourceName builds a lib/pq connection string from the config, quoting every
+// value so passwords may contain spaces or quotes.
+func dataSourceName() string {
+    cfg := config.GetConfig().DBConfig
+    quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+    params := []struct {
+        key, value string
+    }{
+        {"host", cfg.Host},
+        {"port", fmt.Sprint(cfg.Port)},
+        {"user", cfg.Username},
+        {"password", cfg.Password},
+        {"dbname", cfg.Dbname},
+        {"sslmode", cfg.SSLMode},
+        {"sslrootcert", cfg.SSLRootCert},
+        {"connect_timeout", fmt.Sprint(int(cfg.ConnectTimeout.Seconds()))},
+    }
+
+    var parts []string
+    for _, param := range params {
+        if param.value != "" {
+            parts = append(parts, fmt.Sprintf("%s='%s'", param.key, quote.Replace(param.value)))
+        }
+    }
+    return strings.Join(parts, " ")
+}
+
 // GetDB returns the database connection.
 func GetDB() *sqlx.DB {
     return db
-}
\ No newline at end of file
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "strings"
+
+    "github.com/jmoiron/sqlx"
+    "github.com/lib/pq"
+)
+
+// TxBeginner starts transactions, as a *sqlx.DB does on any pooled connection and
+// a *sqlx.Conn on its own.
+type TxBeginner interface {
+    BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+}
+
+// WithTx runs fn in a transaction on db. The transaction commits when fn returns
+// nil and rolls back when it returns an error or panics, so fn never commits or
+// rolls back itself.
+func WithTx(ctx context.Context, db TxBeginner, fn func(tx *sqlx.Tx) error) (err error) {
+    tx, err := db.BeginTxx(ctx, nil)
+    if err != nil {
+        return err
+    }
+    defer func() {
+        if p := recover(); p != nil {
+            tx.Rollback()
+            panic(p)
+        }
+    }()
+
+    if err := fn(tx); err != nil {
+        if rollbackErr := tx.Rollback(); rollbackErr != nil {
+            return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+        }
+        return err
+    }
+    return tx.Commit()
+}
+
+// IsUniqueViolation reports whether err was raised by the unique constraint (or
+// unique index) named constraint.
+func IsUniqueViolation(err error, constraint string) bool {
+    var pqErr *pq.Error
+    return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ContainsPattern returns a LIKE pattern matching values that contain s literally,
+// for use with ESCAPE '\'. Without escaping, % and _ typed by a user are wildcards.
+func ContainsPattern(s string) string {
+    return "%" + likeEscaper.Replace(s) + "%"
+}
@@ -0,0 +1,160 @@
+// Package jwk converts public keys to and from JSON Web Keys (RFC 7517), as
+// published by OpenID Connect providers and by this server.
+package jwk
+
+import (
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/elliptic"
+    "crypto/rsa"
+    "crypto/sha256"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "math/big"
+)
+
+var ErrUnsupportedKey = errors.New("unsupported key type")
+
+// Key is one public key. Only the members of its kty are set.
+type Key struct {
+    Kty string `json:"kty"`
+    Kid string `json:"kid,omitempty"`
+    Use string `json:"use,omitempty"`
+    Alg string `json:"alg,omitempty"`
+
+    // RSA
+    N string `json:"n,omitempty"`
+    E string `json:"e,omitempty"`
+
+    // EC and OKP
+    Crv string `json:"crv,omitempty"`
+    X   string `json:"x,omitempty"`
+    Y   string `json:"y,omitempty"`
+}
+
+// Set is the document served at a jwks_uri.
+type Set struct {
+    Keys []Key `json:"keys"`
+}
+
+// Find returns the key with kid.
+func (s Set) Find(kid string) (Key, bool) {
+    for _, key := range s.Keys {
+        if key.Kid == kid {
+            return key, true
+        }
+    }
+    return Key{}, false
+}
+
+var encoding = base64.RawURLEncoding
+
+var curves = map[string]elliptic.Curve{
+    "P-256": elliptic.P256(),
+    "P-384": elliptic.P384(),
+    "P-521": elliptic.P521(),
+}
+
+// New describes pub as a signing key.
+func New(kid, alg string, pub crypto.PublicKey) (Key, error) {
+    key := Key{Kid: kid, Alg: alg, Use: "sig"}
+
+    switch pub := pub.(type) {
+    case *rsa.PublicKey:
+        key.Kty = "RSA"
+        key.N = encoding.EncodeToString(pub.N.Bytes())
+        key.E = encoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+    case *ecdsa.PublicKey:
+        key.Kty = "EC"
+        key.Crv = pub.Curve.Params().Name
+        if _, ok := curves[key.Crv]; !ok {
+            return Key{}, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, key.Crv)
+        }
+        // Coordinates are padded to the size of the curve, RFC 7518 section 6.2.1.2
+        size := (pub.Curve.Params().BitSize + 7) / 8
+        key.X = encoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+        key.Y = encoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+    case ed25519.PublicKey:
+        key.Kty = "OKP"
+        key.Crv = "Ed25519"
+        key.X = encoding.EncodeToString(pub)
+    default:
+        return Key{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, pub)
+    }
+    return key, nil
+}
+
+// PublicKey returns the key as *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+func (k Key) PublicKey() (crypto.PublicKey, error) {
+    switch k.Kty {
+    case "RSA":
+        n, err := decodeInt(k.N)
+        if err != nil {
+            return nil, err
+        }
+        e, err := decodeInt(k.E)
+        if err != nil {
+            return nil, err
+        }
+        if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+            return nil, fmt.Errorf("%w: rsa exponent out of range", ErrUnsupportedKey)
+        }
+        return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+    case "EC":
+        curve, ok := curves[k.Crv]
+        if !ok {
+            return nil, fmt.Errorf("%w: curve %q", ErrUnsupportedKey, k.Crv)
+        }
+        x, err := decodeInt(k.X)
+        if err != nil {
+            return nil, err
+        }
+        y, err := decodeInt(k.Y)
+        if err != nil {
+            return nil, err
+        }
+        if !curve.IsOnCurve(x, y) {
+            return nil, fmt.Errorf("%w: point is not on curve %s", ErrUnsupportedKey, k.Crv)
+        }
+        return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+    case "OKP":
+        x, err := encoding.DecodeString(k.X)
+        if err != nil {
+            return nil, fmt.Errorf("invalid jwk: %w", err)
+        }
+        if k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+            return nil, fmt.Errorf("%w: curve %q", ErrUnsupportedKey, k.Crv)
+        }
+        return ed25519.PublicKey(x), nil
+    }
+    return nil, fmt.Errorf("%w: kty %q", ErrUnsupportedKey, k.Kty)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+    b, err := encoding.DecodeString(s)
+    if err != nil || len(b) == 0 {
+        return nil, fmt.Errorf("invalid jwk: bad base64url integer %q", s)
+    }
+    return new(big.Int).SetBytes(b), nil
+}
+
+// Thumbprint returns the RFC 7638 thumbprint of the key, base64url encoded, which
+// is stable for a key and so usable as its kid.
+func (k Key) Thumbprint() (string, error) {
+    // Required members only, in lexicographic order, no whitespace
+    var canonical string
+    switch k.Kty {
+    case "RSA":
+        canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, k.E, k.N)
+    case "EC":
+        canonical = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, k.Crv, k.X, k.Y)
+    case "OKP":
+        canonical = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, k.Crv, k.X)
+    default:
+        return "", fmt.Errorf("%w: kty %q", ErrUnsupportedKey, k.Kty)
+    }
+    sum := sha256.Sum256([]byte(canonical))
+    return encoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,99 @@
+package jwk
+
+import (
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "encoding/json"
+    "errors"
+    "reflect"
+    "testing"
+)
+
+// The example RSA key of RFC 7638 section 3.1
+const rfc7638Key = `{
+    "kty": "RSA",
+    "n": "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+    "e": "AQAB",
+    "alg": "RS256",
+    "kid": "2011-04-29"
+}`
+
+func TestThumbprint(t *testing.T) {
+    for _, tc := range []struct {
+        name string
+        key  string
+        want string
+    }{
+        {"RFC 7638 section 3.1", rfc7638Key, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"},
+        {"RFC 8037 appendix A.3", `{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`,
+            "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"},
+    } {
+        var key Key
+        if err := json.Unmarshal([]byte(tc.key), &key); err != nil {
+            t.Fatal(err)
+        }
+        got, err := key.Thumbprint()
+        if err != nil || got != tc.want {
+            t.Fatalf("%s: thumbprint %q (%v), want %q", tc.name, got, err, tc.want)
+        }
+
+        // The thumbprint survives a round trip through the public key
+        public, err := key.PublicKey()
+        if err != nil {
+            t.Fatalf("%s: PublicKey: %v", tc.name, err)
+        }
+        again, err := New("", key.Alg, public)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got, _ := again.Thumbprint(); got != tc.want {
+            t.Fatalf("%s: thumbprint after round trip %q", tc.name, got)
+        }
+    }
+}
+
+func TestECCoordinatesArePadded(t *testing.T) {
+    for i := 0; i < 20; i++ {
+        private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+        if err != nil {
+            t.Fatal(err)
+        }
+        key, err := New("kid", "ES256", &private.PublicKey)
+        if err != nil {
+            t.Fatal(err)
+        }
+        // 32 bytes are 43 base64url characters, also when a coordinate has leading zeros
+        if len(key.X) != 43 || len(key.Y) != 43 {
+            t.Fatalf("coordinates %q, %q are not padded to the curve size", key.X, key.Y)
+        }
+        public, err := key.PublicKey()
+        if err != nil || !reflect.DeepEqual(public, &private.PublicKey) {
+            t.Fatalf("round trip: %v", err)
+        }
+    }
+}
+
+func TestPublicKeyRejectsInvalidKeys(t *testing.T) {
+    for name, key := range map[string]Key{
+        "unknown kty":       {Kty: "oct"},
+        "unknown curve":     {Kty: "EC", Crv: "P-192", X: "AQ", Y: "AQ"},
+        "point off curve":   {Kty: "EC", Crv: "P-256", X: "AQ", Y: "AQ"},
+        "small exponent":    {Kty: "RSA", N: "AQAB", E: "AQ"},
+        "short Ed25519 key": {Kty: "OKP", Crv: "Ed25519", X: "AQAB"},
+    } {
+        if _, err := key.PublicKey(); !errors.Is(err, ErrUnsupportedKey) {
+            t.Fatalf("%s: got %v, want ErrUnsupportedKey", name, err)
+        }
+    }
+}
+
+func TestFind(t *testing.T) {
+    set := Set{Keys: []Key{{Kid: "a"}, {Kid: "b"}}}
+    if key, ok := set.Find("b"); !ok || key.Kid != "b" {
+        t.Fatalf("Find(b) = %+v, %v", key, ok)
+    }
+    if _, ok := set.Find("c"); ok {
+        t.Fatal("found a key that is not in the set")
+    }
+}
@@ -0,0 +1,226 @@
+// Package loginguard slows down password guessing by tracking failed logins per
+// account and per client address, with exponential backoff and temporary lockout.
+package loginguard
+
+import (
+    "backend/internal/config"
+    "backend/internal/database"
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+)
+
+var ErrLocked = errors.New("too many failed login attempts")
+
+// LockedError is returned while an account or address has to wait before its next try.
+type LockedError struct {
+    RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+    return fmt.Sprintf("%s, try again in %s", ErrLocked, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockedError) Is(target error) bool {
+    return target == ErrLocked
+}
+
+// Reasons a login failed, recorded in the audit trail
+const (
+    ReasonUnknownEmail  = "unknown_email"
+    ReasonWrongPassword = "wrong_password"
+    ReasonWrongMFACode  = "wrong_mfa_code" // the password was right, the second factor was not
+    ReasonLocked        = "locked"
+)
+
+// Attempts are the recent failures of one key.
+type Attempts struct {
+    Failures    int
+    LastFailure time.Time
+}
+
+// Failure is the audit entry of one failed login.
+type Failure struct {
+    Email     string
+    IPAddress string
+    UserAgent string
+    Reason    string
+    At        time.Time
+}
+
+// Store keeps failed attempts per key and the audit trail.
+type Store interface {
+    // Fail records a failure of key at now and returns its failures since now minus
+    // window, including this one. It must be atomic, concurrent failures all count.
+    Fail(ctx context.Context, key string, now time.Time, window time.Duration) (Attempts, error)
+    // Get returns the failures of key since now minus window.
+    Get(ctx context.Context, key string, now time.Time, window time.Duration) (Attempts, error)
+    // Reset forgets the failures of key.
+    Reset(ctx context.Context, key string) error
+    // Audit records a failed login.
+    Audit(ctx context.Context, failure Failure) error
+    // Purge deletes the attempts of keys whose last failure is before attemptsBefore
+    // and audit entries from before auditBefore, returning how many were deleted.
+    Purge(ctx context.Context, attemptsBefore, auditBefore time.Time) (int64, error)
+}
+
+// Policy decides how long a key waits after its failures.
+type Policy struct {
+    Threshold int // failures allowed before backoff starts
+    BaseDelay time.Duration
+    MaxDelay  time.Duration
+}
+
+// Delay returns how long to wait after the last failure: nothing up to the
+// threshold, then BaseDelay doubling with each failure up to MaxDelay.
+func (p Policy) Delay(failures int) time.Duration {
+    if failures < p.Threshold {
+        return 0
+    }
+    delay := p.BaseDelay
+    for i := p.Threshold; i < failures && delay < p.MaxDelay; i++ {
+        delay *= 2
+    }
+    if delay > p.MaxDelay {
+        delay = p.MaxDelay
+    }
+    return delay
+}
+
+// Guard applies an account and an address policy to logins.
+type Guard struct {
+    store   Store
+    account Policy
+    ip      Policy
+    window  time.Duration
+    now     func() time.Time
+}
+
+func New(store Store, account, ip Policy, window time.Duration) *Guard {
+    return &Guard{store: store, account: account, ip: ip, window: window, now: time.Now}
+}
+
+func accountKey(email string) string {
+    return "account:" + strings.ToLower(email)
+}
+
+func ipKey(ip string) string {
+    return "ip:" + ip
+}
+
+// Check returns a *LockedError if the account or the address has to wait.
+func (g *Guard) Check(ctx context.Context, email, ip string) error {
+    now := g.now()
+
+    var wait time.Duration
+    for _, c := range []struct {
+        key    string
+        policy Policy
+    }{{accountKey(email), g.account}, {ipKey(ip), g.ip}} {
+        attempts, err := g.store.Get(ctx, c.key, now, g.window)
+        if err != nil {
+            return err
+        }
+        if until := attempts.LastFailure.Add(c.policy.Delay(attempts.Failures)); until.Sub(now) > wait {
+            wait = until.Sub(now)
+        }
+    }
+
+    if wait > 0 {
+        return &LockedError{RetryAfter: wait}
+    }
+    return nil
+}
+
+// Fail records a failed login against the account and the address. Logins refused
+// because of a lockout are audited but not counted, so they do not extend it.
+func (g *Guard) Fail(ctx context.Context, failure Failure) error {
+    failure.At = g.now()
+    if err := g.store.Audit(ctx, failure); err != nil {
+        return err
+    }
+    log.Printf("Failed login for %q from %s: %s", failure.Email, failure.IPAddress, failure.Reason)
+
+    if failure.Reason == ReasonLocked {
+        return nil
+    }
+    if _, err := g.store.Fail(ctx, accountKey(failure.Email), failure.At, g.window); err != nil {
+        return err
+    }
+    _, err := g.store.Fail(ctx, ipKey(failure.IPAddress), failure.At, g.window)
+    return err
+}
+
+// Succeed clears the failures of an account once a login completed, after the second
+// factor if there is one. The address keeps its failures, otherwise logging into one
+// account would let it keep guessing others.
+func (g *Guard) Succeed(ctx context.Context, email string) error {
+    return g.store.Reset(ctx, accountKey(email))
+}
+
+// Unlock clears the failures of an account, after a password reset or by an operator
+// with `server unlock`.
+func (g *Guard) Unlock(ctx context.Context, email string) error {
+    return g.store.Reset(ctx, accountKey(email))
+}
+
+// Purge deletes attempts past the window, which no longer count anyway, and audit
+// entries older than auditRetention.
+func (g *Guard) Purge(ctx context.Context, auditRetention time.Duration) (int64, error) {
+    now := g.now()
+    return g.store.Purge(ctx, now.Add(-g.window), now.Add(-auditRetention))
+}
+
+// RunPurger calls Purge every interval until ctx is cancelled.
+func (g *Guard) RunPurger(ctx context.Context, interval, auditRetention time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        deleted, err := g.Purge(ctx, auditRetention)
+        if err != nil {
+            log.Printf("Login guard purge: %v", err)
+        } else if deleted > 0 {
+            log.Printf("Login guard purge: deleted %d old attempts and failures", deleted)
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+var guard *Guard
+
+// Init sets up the guard with the store and policies selected in the config.
+// With the postgres store, the database must be connected first.
+func Init() {
+    cfg := config.GetConfig().LoginGuard
+
+    var store Store
+    switch cfg.Backend {
+    case "postgres":
+        store = NewPostgres(database.GetDB())
+    case "memory":
+        store = NewMemory()
+    default:
+        log.Fatalf("Unknown login guard backend %q", cfg.Backend)
+    }
+
+    guard = New(store,
+        Policy{Threshold: cfg.AccountThreshold, BaseDelay: cfg.BaseDelay, MaxDelay: cfg.MaxDelay},
+        Policy{Threshold: cfg.IPThreshold, BaseDelay: cfg.BaseDelay, MaxDelay: cfg.MaxDelay},
+        cfg.Window)
+
+    log.Printf("Using %s login guard", cfg.Backend)
+}
+
+// Get returns the guard.
+func Get() *Guard {
+    return guard
+}
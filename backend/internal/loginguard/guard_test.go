@@ -0,0 +1,133 @@
+package loginguard
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestPolicyDelay(t *testing.T) {
+    policy := Policy{Threshold: 3, BaseDelay: time.Minute, MaxDelay: 10 * time.Minute}
+    for _, tc := range []struct {
+        failures int
+        want     time.Duration
+    }{
+        {0, 0},
+        {2, 0},
+        {3, time.Minute},
+        {4, 2 * time.Minute},
+        {5, 4 * time.Minute},
+        {6, 8 * time.Minute},
+        {7, 10 * time.Minute},
+        {1000, 10 * time.Minute},
+    } {
+        if got := policy.Delay(tc.failures); got != tc.want {
+            t.Fatalf("Delay(%d) = %s, want %s", tc.failures, got, tc.want)
+        }
+    }
+
+    // A maximum below the base delay caps the first delay too
+    capped := Policy{Threshold: 1, BaseDelay: time.Hour, MaxDelay: time.Minute}
+    if got := capped.Delay(1); got != time.Minute {
+        t.Fatalf("capped Delay(1) = %s, want 1m", got)
+    }
+}
+
+func TestGuardBacksOff(t *testing.T) {
+    ctx := context.Background()
+    guard := New(NewMemory(), Policy{Threshold: 2, BaseDelay: time.Minute, MaxDelay: time.Hour},
+        Policy{Threshold: 100, BaseDelay: time.Minute, MaxDelay: time.Hour}, time.Hour)
+    now := time.Unix(1700000000, 0)
+    guard.now = func() time.Time { return now }
+    failure := Failure{Email: "ada@example.com", IPAddress: "192.0.2.1", Reason: ReasonWrongPassword}
+
+    for i := 0; i < 2; i++ {
+        if err := guard.Check(ctx, "ada@example.com", "192.0.2.1"); err != nil {
+            t.Fatalf("before failure %d: %v", i+1, err)
+        }
+        if err := guard.Fail(ctx, failure); err != nil {
+            t.Fatal(err)
+        }
+    }
+    var locked *LockedError
+    if err := guard.Check(ctx, "ADA@example.com", "192.0.2.9"); !errors.As(err, &locked) || locked.RetryAfter != time.Minute {
+        t.Fatalf("after the threshold: got %v, want to wait a minute", err)
+    }
+
+    now = now.Add(time.Minute)
+    if err := guard.Check(ctx, "ada@example.com", "192.0.2.1"); err != nil {
+        t.Fatalf("after the delay: %v", err)
+    }
+    if err := guard.Succeed(ctx, "ada@example.com"); err != nil {
+        t.Fatal(err)
+    }
+    if err := guard.Fail(ctx, failure); err != nil {
+        t.Fatal(err)
+    }
+    if err := guard.Check(ctx, "ada@example.com", "192.0.2.1"); err != nil {
+        t.Fatalf("one failure after a login: %v", err)
+    }
+}
+
+func TestMemorySweepsOldKeys(t *testing.T) {
+    ctx := context.Background()
+    store := NewMemory()
+    now := time.Unix(1700000000, 0)
+
+    for _, key := range []string{"ip:192.0.2.1", "ip:192.0.2.2", "ip:192.0.2.3"} {
+        if _, err := store.Fail(ctx, key, now, time.Hour); err != nil {
+            t.Fatal(err)
+        }
+    }
+    // Within the window nothing is dropped
+    if _, err := store.Fail(ctx, "ip:192.0.2.4", now.Add(30*time.Minute), time.Hour); err != nil {
+        t.Fatal(err)
+    }
+    if n := store.Keys(); n != 4 {
+        t.Fatalf("%d keys, want 4", n)
+    }
+
+    // The next failure a window later drops the keys that never came back
+    if _, err := store.Fail(ctx, "ip:192.0.2.5", now.Add(time.Hour+time.Second), time.Hour); err != nil {
+        t.Fatal(err)
+    }
+    if n := store.Keys(); n != 2 {
+        t.Fatalf("%d keys after the sweep, want 2", n)
+    }
+}
+
+func TestGuardPurge(t *testing.T) {
+    ctx := context.Background()
+    store := NewMemory()
+    guard := New(store, Policy{Threshold: 5, BaseDelay: time.Minute, MaxDelay: time.Hour},
+        Policy{Threshold: 20, BaseDelay: time.Minute, MaxDelay: time.Hour}, time.Hour)
+    now := time.Unix(1700000000, 0)
+    guard.now = func() time.Time { return now }
+
+    if err := guard.Fail(ctx, Failure{Email: "ada@example.com", IPAddress: "192.0.2.1", Reason: ReasonWrongPassword}); err != nil {
+        t.Fatal(err)
+    }
+    now = now.Add(2 * time.Hour)
+    if err := guard.Fail(ctx, Failure{Email: "grace@example.com", IPAddress: "192.0.2.2", Reason: ReasonUnknownEmail}); err != nil {
+        t.Fatal(err)
+    }
+
+    // The second failure swept the keys of the first, the rest is not old enough
+    deleted, err := guard.Purge(ctx, 24*time.Hour)
+    if err != nil || deleted != 0 {
+        t.Fatalf("purge: %d, %v; want nothing deleted", deleted, err)
+    }
+    if n := store.Keys(); n != 2 || len(store.Failures()) != 2 {
+        t.Fatalf("%d keys and %d audit entries, want 2 each", n, len(store.Failures()))
+    }
+
+    now = now.Add(2 * time.Hour)
+    deleted, err = guard.Purge(ctx, 3*time.Hour)
+    if err != nil || deleted != 3 {
+        t.Fatalf("purge: %d, %v; want the 2 keys and the first audit entry deleted", deleted, err)
+    }
+    if failures := store.Failures(); len(failures) != 1 || failures[0].Email != "grace@example.com" {
+        t.Fatalf("audit after the purge: %+v", failures)
+    }
+}
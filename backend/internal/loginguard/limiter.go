@@ -0,0 +1,54 @@
+package loginguard
+
+import (
+    "context"
+    "strings"
+    "time"
+)
+
+// Limiter caps how often an email address and a client address may do something
+// within a window, e.g. ask for password reset mail. It counts in the guard's
+// store under keys of its own, so it never adds to a login backoff.
+type Limiter struct {
+    store      Store
+    name       string
+    perAccount int
+    perIP      int
+    window     time.Duration
+    now        func() time.Time
+}
+
+// Limiter returns a limiter named name that shares the guard's store.
+func (g *Guard) Limiter(name string, perAccount, perIP int, window time.Duration) *Limiter {
+    return &Limiter{store: g.store, name: name, perAccount: perAccount, perIP: perIP, window: window, now: g.now}
+}
+
+// Allow counts a request and returns a *LockedError once the account or the
+// address has used up its requests. Refused requests are not counted, so they do
+// not extend the wait.
+func (l *Limiter) Allow(ctx context.Context, email, ip string) error {
+    now := l.now()
+    keys := []struct {
+        key   string
+        limit int
+    }{
+        {l.name + ":account:" + strings.ToLower(email), l.perAccount},
+        {l.name + ":ip:" + ip, l.perIP},
+    }
+
+    for _, k := range keys {
+        attempts, err := l.store.Get(ctx, k.key, now, l.window)
+        if err != nil {
+            return err
+        }
+        if attempts.Failures >= k.limit {
+            return &LockedError{RetryAfter: attempts.LastFailure.Add(l.window).Sub(now)}
+        }
+    }
+    for _, k := range keys {
+        if _, err := l.store.Fail(ctx, k.key, now, l.window); err != nil {
+            return err
+        }
+    }
+    return nil
+}
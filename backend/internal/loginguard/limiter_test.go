@@ -0,0 +1,48 @@
+package loginguard
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestLimiter(t *testing.T) {
+    ctx := context.Background()
+    store := NewMemory()
+    guard := New(store, Policy{Threshold: 1, BaseDelay: time.Minute, MaxDelay: time.Hour},
+        Policy{Threshold: 1, BaseDelay: time.Minute, MaxDelay: time.Hour}, time.Hour)
+    now := time.Unix(1700000000, 0)
+    guard.now = func() time.Time { return now }
+    limiter := guard.Limiter("reset", 2, 3, time.Hour)
+
+    for i := 0; i < 2; i++ {
+        if err := limiter.Allow(ctx, "Ada@example.com", "192.0.2.1"); err != nil {
+            t.Fatalf("request %d: %v", i+1, err)
+        }
+    }
+    // The email is used up whatever its case
+    err := limiter.Allow(ctx, "ada@example.com", "192.0.2.2")
+    var locked *LockedError
+    if !errors.As(err, &locked) || locked.RetryAfter != time.Hour {
+        t.Fatalf("third request for the email: got %v, want to wait an hour", err)
+    }
+
+    // The address is used up by requests for other emails too
+    if err := limiter.Allow(ctx, "grace@example.com", "192.0.2.1"); err != nil {
+        t.Fatal(err)
+    }
+    if err := limiter.Allow(ctx, "linus@example.com", "192.0.2.1"); !errors.Is(err, ErrLocked) {
+        t.Fatalf("fourth request from the address: got %v, want ErrLocked", err)
+    }
+
+    // Nothing was counted towards logins
+    if err := guard.Check(ctx, "ada@example.com", "192.0.2.1"); err != nil {
+        t.Fatalf("login after reset requests: %v", err)
+    }
+
+    now = now.Add(time.Hour + time.Second)
+    if err := limiter.Allow(ctx, "ada@example.com", "192.0.2.1"); err != nil {
+        t.Fatalf("after the window: %v", err)
+    }
+}
@@ -0,0 +1,120 @@
+package loginguard
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+// maxAuditEntries bounds the audit trail kept by Memory; older entries are dropped.
+const maxAuditEntries = 1000
+
+// Memory keeps attempts in the process, for a single server or tests.
+type Memory struct {
+    mu       sync.Mutex
+    attempts map[string]Attempts
+    audit    []Failure
+    swept    time.Time // when Fail last dropped the keys past their window
+}
+
+func NewMemory() *Memory {
+    return &Memory{attempts: map[string]Attempts{}}
+}
+
+func (m *Memory) Fail(ctx context.Context, key string, now time.Time, window time.Duration) (Attempts, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    // Keys that never come back would stay forever, sweep them once per window
+    if now.Sub(m.swept) >= window {
+        m.purgeAttempts(now.Add(-window))
+        m.swept = now
+    }
+
+    attempts := m.recent(key, now, window)
+    attempts.Failures++
+    attempts.LastFailure = now
+    m.attempts[key] = attempts
+    return attempts, nil
+}
+
+func (m *Memory) Get(ctx context.Context, key string, now time.Time, window time.Duration) (Attempts, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    return m.recent(key, now, window), nil
+}
+
+// recent returns the attempts of key, forgetting them once they are older than window.
+func (m *Memory) recent(key string, now time.Time, window time.Duration) Attempts {
+    attempts, ok := m.attempts[key]
+    if ok && attempts.LastFailure.Before(now.Add(-window)) {
+        delete(m.attempts, key)
+        return Attempts{}
+    }
+    return attempts
+}
+
+func (m *Memory) Reset(ctx context.Context, key string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    delete(m.attempts, key)
+    return nil
+}
+
+func (m *Memory) Purge(ctx context.Context, attemptsBefore, auditBefore time.Time) (int64, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    deleted := m.purgeAttempts(attemptsBefore)
+    kept := m.audit[:0]
+    for _, failure := range m.audit {
+        if failure.At.Before(auditBefore) {
+            deleted++
+            continue
+        }
+        kept = append(kept, failure)
+    }
+    m.audit = kept
+    return deleted, nil
+}
+
+// purgeAttempts deletes the keys whose last failure is before before.
+func (m *Memory) purgeAttempts(before time.Time) int64 {
+    var deleted int64
+    for key, attempts := range m.attempts {
+        if attempts.LastFailure.Before(before) {
+            delete(m.attempts, key)
+            deleted++
+        }
+    }
+    return deleted
+}
+
+// Keys returns how many keys have attempts stored.
+func (m *Memory) Keys() int {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    return len(m.attempts)
+}
+
+func (m *Memory) Audit(ctx context.Context, failure Failure) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    m.audit = append(m.audit, failure)
+    if len(m.audit) > maxAuditEntries {
+        m.audit = m.audit[len(m.audit)-maxAuditEntries:]
+    }
+    return nil
+}
+
+// Failures returns the audit trail, oldest first.
+func (m *Memory) Failures() []Failure {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    return append([]Failure(nil), m.audit...)
+}
@@ -0,0 +1,76 @@
+package loginguard
+
+import (
+    "context"
+    "database/sql"
+    "time"
+
+    "github.com/jmoiron/sqlx"
+)
+
+// Postgres keeps attempts in the database, shared by every server.
+type Postgres struct {
+    db *sqlx.DB
+}
+
+func NewPostgres(db *sqlx.DB) *Postgres {
+    return &Postgres{db: db}
+}
+
+func (p *Postgres) Fail(ctx context.Context, key string, now time.Time, window time.Duration) (Attempts, error) {
+    var attempts Attempts
+    err := p.db.QueryRowContext(ctx,
+        `INSERT INTO login_attempts (key, failures, last_failure_at) VALUES ($1, 1, $2)
+         ON CONFLICT (key) DO UPDATE SET
+             failures = CASE WHEN login_attempts.last_failure_at < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+             last_failure_at = $2
+         RETURNING failures, last_failure_at`,
+        key, now, now.Add(-window)).Scan(&attempts.Failures, &attempts.LastFailure)
+    return attempts, err
+}
+
+func (p *Postgres) Get(ctx context.Context, key string, now time.Time, window time.Duration) (Attempts, error) {
+    var attempts Attempts
+    err := p.db.QueryRowContext(ctx,
+        "SELECT failures, last_failure_at FROM login_attempts WHERE key = $1 AND last_failure_at >= $2",
+        key, now.Add(-window)).Scan(&attempts.Failures, &attempts.LastFailure)
+    if err == sql.ErrNoRows {
+        return Attempts{}, nil
+    }
+    return attempts, err
+}
+
+func (p *Postgres) Reset(ctx context.Context, key string) error {
+    _, err := p.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE key = $1", key)
+    return err
+}
+
+func (p *Postgres) Purge(ctx context.Context, attemptsBefore, auditBefore time.Time) (int64, error) {
+    var deleted int64
+    for _, purge := range []struct {
+        query  string
+        before time.Time
+    }{
+        {"DELETE FROM login_attempts WHERE last_failure_at < $1", attemptsBefore},
+        {"DELETE FROM login_failures WHERE created_at < $1", auditBefore},
+    } {
+        result, err := p.db.ExecContext(ctx, purge.query, purge.before)
+        if err != nil {
+            return deleted, err
+        }
+        affected, err := result.RowsAffected()
+        if err != nil {
+            return deleted, err
+        }
+        deleted += affected
+    }
+    return deleted, nil
+}
+
+func (p *Postgres) Audit(ctx context.Context, failure Failure) error {
+    _, err := p.db.ExecContext(ctx,
+        `INSERT INTO login_failures (email, ip_address, user_agent, reason, created_at)
+         VALUES ($1, $2, $3, $4, $5)`,
+        failure.Email, failure.IPAddress, failure.UserAgent, failure.Reason, failure.At)
+    return err
+}
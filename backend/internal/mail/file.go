@@ -0,0 +1,63 @@
+package mail
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+)
+
+// Log only logs who a message was for, the default until a real backend is set
+// up. The body is left out, it carries links that work for whoever reads them.
+type Log struct{}
+
+func (Log) Send(ctx context.Context, msg Message) error {
+    log.Printf("Not sending mail %q to %s, no MAIL_BACKEND is set up", msg.Subject, msg.To)
+    return nil
+}
+
+// File writes each message to a .eml file instead of sending it, for development.
+// The files hold working reset and verification links, keep them private.
+type File struct {
+    dir  string
+    from string
+}
+
+func NewFile(dir, from string) (*File, error) {
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, err
+    }
+    return &File{dir: dir, from: from}, nil
+}
+
+func (f *File) Send(ctx context.Context, msg Message) error {
+    name := fmt.Sprintf("%d.eml", time.Now().UnixNano())
+    return os.WriteFile(filepath.Join(f.dir, name), format(f.from, msg), 0o600)
+}
+
+// Memory keeps sent messages so tests can read them back.
+type Memory struct {
+    mu       sync.Mutex
+    messages []Message
+}
+
+func NewMemory() *Memory {
+    return &Memory{}
+}
+
+func (m *Memory) Send(ctx context.Context, msg Message) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.messages = append(m.messages, msg)
+    return nil
+}
+
+// Messages returns every message sent so far, oldest first.
+func (m *Memory) Messages() []Message {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return append([]Message(nil), m.messages...)
+}
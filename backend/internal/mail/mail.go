@@ -0,0 +1,56 @@
+package mail
+
+import (
+    "backend/internal/config"
+    "context"
+    "fmt"
+    "log"
+)
+
+// Message is a plain text email.
+type Message struct {
+    To      string
+    Subject string
+    Body    string
+}
+
+// Mailer sends outbound email, such as password reset links.
+type Mailer interface {
+    Send(ctx context.Context, msg Message) error
+}
+
+var mailer Mailer
+
+// Init sets up the mail backend selected in the config.
+func Init() {
+    cfg := config.GetConfig()
+
+    var err error
+    switch cfg.Mail.Backend {
+    case "smtp":
+        mailer = NewSMTP(cfg.Mail.SMTPHost, cfg.Mail.SMTPPort, cfg.Mail.SMTPUsername, cfg.Mail.SMTPPassword, cfg.Mail.From)
+    case "file":
+        mailer, err = NewFile(cfg.Mail.Dir, cfg.Mail.From)
+    case "memory":
+        mailer = NewMemory()
+    case "log":
+        mailer = Log{}
+    default:
+        err = fmt.Errorf("unknown mail backend %q", cfg.Mail.Backend)
+    }
+    if err != nil {
+        log.Fatalf("Unable to set up mail: %v", err)
+    }
+
+    log.Printf("Using %s mail", cfg.Mail.Backend)
+}
+
+// Get returns the mail backend.
+func Get() Mailer {
+    return mailer
+}
+
+// Set replaces the mail backend, e.g. with a Memory in tests.
+func Set(m Mailer) {
+    mailer = m
+}
@@ -0,0 +1,71 @@
+package mail
+
+import (
+    "context"
+    "fmt"
+    "net"
+    "net/mail"
+    "net/smtp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// SMTP sends mail through a relay, authenticating when a username is set.
+// net/smtp upgrades to TLS with STARTTLS when the server offers it.
+type SMTP struct {
+    addr string
+    host string
+    auth smtp.Auth
+    from string
+}
+
+func NewSMTP(host string, port int, username, password, from string) *SMTP {
+    s := &SMTP{
+        addr: net.JoinHostPort(host, strconv.Itoa(port)),
+        host: host,
+        from: from,
+    }
+    if username != "" {
+        s.auth = smtp.PlainAuth("", username, password, host)
+    }
+    return s
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+    from, err := mail.ParseAddress(s.from)
+    if err != nil {
+        return fmt.Errorf("invalid from address: %w", err)
+    }
+    to, err := mail.ParseAddress(msg.To)
+    if err != nil {
+        return fmt.Errorf("invalid recipient: %w", err)
+    }
+
+    // smtp.SendMail has no context, so run it aside and give up when ctx ends
+    done := make(chan error, 1)
+    go func() {
+        done <- smtp.SendMail(s.addr, s.auth, from.Address, []string{to.Address}, format(s.from, msg))
+    }()
+
+    select {
+    case err := <-done:
+        return err
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// format renders msg as an RFC 5322 message.
+func format(from string, msg Message) []byte {
+    var b strings.Builder
+    fmt.Fprintf(&b, "From: %s\r\n", from)
+    fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+    fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+    fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+    b.WriteString("MIME-Version: 1.0\r\n")
+    b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+    b.WriteString("\r\n")
+    b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+    return []byte(b.String())
+}
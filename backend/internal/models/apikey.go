@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type APIKey struct {
+    ID         int        `json:"id"`
+    UserID     int        `json:"-"`
+    OrgID      *int       `json:"org_id,omitempty"` // only usable in this organization when set
+    Name       string     `json:"name" binding:"required,max=255"`
+    Prefix     string     `json:"prefix"`
+    Scopes     []string   `json:"scopes" binding:"required,min=1"`
+    CreatedAt  time.Time  `json:"created_at"`
+    ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+    LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// NewAPIKey is returned once, when the key is created; afterwards only its prefix is known.
+type NewAPIKey struct {
+    APIKey
+    Key string `json:"key"`
+}
@@ -0,0 +1,12 @@
+package models
+
+type Application struct {
+    ID             int                    `json:"id,omitempty" db:"id"`
+    JobID          string                 `json:"job_id,omitempty"`
+    CandidateName  string                 `json:"candidate_name" binding:"required" db:"candidate_name"`
+    CandidateEmail string                 `json:"candidate_email" binding:"required,email" db:"candidate_email"`
+    Responses      map[string]interface{} `json:"responses" db:"responses"` // question id -> answer
+    Stage          string                 `json:"stage,omitempty" db:"stage"`
+    CreatedAt      string                 `json:"created_at,omitempty" db:"created_at"`
+    UpdatedAt      string                 `json:"updated_at,omitempty" db:"updated_at"`
+}
@@ -1,14 +1,28 @@
 package models
 
+import "time"
+
+// Job lifecycle statuses.
+const (
+    JobStatusDraft     = "draft"
+    JobStatusScheduled = "scheduled" // waiting for publish_at
+    JobStatusActive    = "active"    // visible and accepting applications
+    JobStatusPaused    = "paused"
+    JobStatusClosed    = "closed"
+    JobStatusArchived  = "archived"
+)
+
 type Job struct {
     ID              int               `json:"id,omitempty" db:"id"`
     JobID           string            `json:"job_id,omitempty" binding:"required" db:"job_id"`
     UserID          int               `json:"user_id,omitempty" db:"user_id"`
     JobTitle        string            `json:"job_title,omitempty" binding:"required" db:"job_title"`
     JobDescription  string            `json:"job_description,omitempty" binding:"required" db:"job_description"`
-    JobStatus       string            `json:"job_status,omitempty" binding:"required" db:"job_status"`
+    JobStatus       string            `json:"job_status,omitempty" binding:"required,oneof=draft scheduled active paused closed archived" db:"job_status"`
     SkillsRequired  []string          `json:"skills_required,omitempty" binding:"required" db:"skills_required"`
+    PublishAt       *time.Time        `json:"publish_at,omitempty" db:"publish_at"` // when a scheduled job becomes active
+    ExpiresAt       *time.Time        `json:"expires_at,omitempty" db:"expires_at"` // when an active or paused job closes
     CreatedAt       string            `json:"created_at,omitempty" db:"created_at"`
     UpdatedAt       string            `json:"updated_at,omitempty" db:"updated_at"`
     Attributes      map[string]interface{} `json:"attributes,omitempty" db:"attributes"`
-};
\ No newline at end of file
+};
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Roles of organization members, from most to least privileged
+const (
+    RoleOwner       = "owner"       // everything, including deleting the organization and managing owners
+    RoleAdmin       = "admin"       // manages members and deletes jobs
+    RoleRecruiter   = "recruiter"   // manages jobs and moves applicants through the pipeline
+    RoleInterviewer = "interviewer" // reads jobs and applications
+    RoleViewer      = "viewer"      // reads jobs
+)
+
+type Organization struct {
+    ID        int       `json:"id" db:"id"`
+    Name      string    `json:"name" db:"name" binding:"required,max=255"`
+    Role      string    `json:"role,omitempty" db:"role"` // the caller's role
+    CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type Member struct {
+    UserID    int       `json:"user_id" db:"user_id"`
+    Username  string    `json:"username" db:"username"`
+    Email     string    `json:"email" db:"email"`
+    Role      string    `json:"role" db:"role"`
+    CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// Permissions declared by routes; roles grant them through services.RolePermissions.
+const (
+    PermJobsRead           = "jobs:read"           // jobs, questionnaires, pipelines and share links
+    PermJobsWrite          = "jobs:write"          // create and change them
+    PermJobsDelete         = "jobs:delete"
+    PermApplicationsRead   = "applications:read"   // applications, their files and stage history
+    PermApplicationsWrite  = "applications:write"  // move applications through the pipeline
+    PermMembersRead        = "members:read"
+    PermMembersWrite       = "members:write"       // add, remove and change roles of members
+    PermOrganizationDelete = "organization:delete"
+)
@@ -0,0 +1,29 @@
+package models
+
+// Stages an application moves through.
+const (
+    StageApplied   = "applied"
+    StageScreening = "screening"
+    StageInterview = "interview"
+    StageOffer     = "offer"
+    StageHired     = "hired"
+    StageRejected  = "rejected"
+    StageWithdrawn = "withdrawn"
+)
+
+// Pipeline lists, per stage, the stages an application may move to next.
+// Stages missing from Transitions are not used by the job.
+type Pipeline struct {
+    JobID       string              `json:"job_id,omitempty"`
+    Transitions map[string][]string `json:"transitions" binding:"required"`
+}
+
+type StageTransition struct {
+    ID            int    `json:"id" db:"id"`
+    ApplicationID int    `json:"application_id" db:"application_id"`
+    FromStage     string `json:"from_stage" db:"from_stage"`
+    ToStage       string `json:"to_stage" binding:"required" db:"to_stage"`
+    ActorUserID   int    `json:"actor_user_id" db:"actor_user_id"`
+    Note          string `json:"note,omitempty" db:"note"`
+    CreatedAt     string `json:"created_at,omitempty" db:"created_at"`
+}
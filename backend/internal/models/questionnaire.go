@@ -0,0 +1,26 @@
+package models
+
+// Question types supported by the questionnaire builder in the frontend.
+const (
+    QuestionTypeRadio    = "radio"
+    QuestionTypeCheckbox = "checkbox"
+    QuestionTypeText     = "text"
+    QuestionTypeFile     = "file"
+)
+
+type Question struct {
+    ID       string   `json:"id" binding:"required"`
+    Text     string   `json:"text" binding:"required"`
+    Type     string   `json:"type" binding:"required,oneof=radio checkbox text file"`
+    Options  []string `json:"options"`
+    Required bool     `json:"required"`
+}
+
+type Questionnaire struct {
+    ID        int        `json:"id,omitempty" db:"id"`
+    FormID    string     `json:"form_id" binding:"required" db:"form_id"`
+    JobID     string     `json:"job_id,omitempty"`
+    Questions []Question `json:"questions" binding:"required,min=1,dive" db:"questions"`
+    CreatedAt string     `json:"created_at,omitempty" db:"created_at"`
+    UpdatedAt string     `json:"updated_at,omitempty" db:"updated_at"`
+}
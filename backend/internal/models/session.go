@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type Session struct {
+    ID         string    `json:"id" db:"id"`
+    UserAgent  string    `json:"user_agent" db:"user_agent"`
+    IPAddress  string    `json:"ip_address" db:"ip_address"`
+    CreatedAt  time.Time `json:"created_at" db:"created_at"`
+    LastUsedAt time.Time `json:"last_used_at" db:"last_used_at"`
+    ExpiresAt  time.Time `json:"expires_at" db:"expires_at"`
+    Current    bool      `json:"current"` // the session making the request
+}
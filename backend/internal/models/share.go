@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type ShareLink struct {
+    JobID      string `json:"job_id"`
+    ShareToken string `json:"share_token"`
+    CreatedAt  string `json:"created_at,omitempty"`
+}
+
+// PublicJob is the view of an active job shown to candidates through a share link.
+// It leaves out internal ids, the owner, status and attributes.
+type PublicJob struct {
+    JobTitle       string     `json:"job_title"`
+    JobDescription string     `json:"job_description"`
+    SkillsRequired []string   `json:"skills_required"`
+    ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+    Questions      []Question `json:"questions"`
+}
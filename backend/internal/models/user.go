@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type User struct {
     ID                int  `json:"id,omitempty" db:"id"`
     Username          string `json:"username,omitempty" db:"username"` 
@@ -7,4 +9,6 @@ type User struct {
     Email             string `json:"email,omitempty" db:"email"`
     CreatedAt         string `json:"created_at,omitempty" db:"created_at"`
     UpdatedAt         string `json:"updated_at,omitempty" db:"updated_at"`
+    EmailVerifiedAt   *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+    TOTPEnabledAt     *time.Time `json:"-" db:"totp_enabled_at"`
 }
\ No newline at end of file
@@ -0,0 +1,304 @@
+// Package oidc logs users in through an OpenID Connect provider: the
+// authorization code flow with PKCE, and verification of the ID token it returns.
+package oidc
+
+import (
+    "backend/internal/config"
+    "backend/internal/jwk"
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/golang-jwt/jwt/v4"
+)
+
+var (
+    ErrInvalidIDToken = errors.New("invalid id token")
+    ErrExchange       = errors.New("authorization code exchange failed")
+)
+
+// signingMethods are the ID token algorithms accepted, never "none" or HMAC.
+var signingMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+const (
+    maxResponseBytes = 1 << 20
+    keyRefetchDelay  = time.Minute // unknown key ids refetch the key set at most this often
+)
+
+type Config struct {
+    Issuer       string
+    ClientID     string
+    ClientSecret string // empty for a public client
+    RedirectURL  string
+    Scopes       []string // "openid" is always requested
+}
+
+// Claims are the ID token claims used to find or create the user.
+type Claims struct {
+    jwt.RegisteredClaims
+    Nonce             string `json:"nonce"`
+    AuthorizedParty   string `json:"azp"`
+    Email             string `json:"email"`
+    EmailVerified     bool   `json:"email_verified"`
+    Name              string `json:"name"`
+    PreferredUsername string `json:"preferred_username"`
+}
+
+// metadata is the part of the discovery document the flow needs.
+type metadata struct {
+    Issuer                string `json:"issuer"`
+    AuthorizationEndpoint string `json:"authorization_endpoint"`
+    TokenEndpoint         string `json:"token_endpoint"`
+    JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider talks to one OpenID Connect provider. Its discovery document and keys
+// are fetched on first use, so the server starts even while the provider is down.
+type Provider struct {
+    cfg    Config
+    client *http.Client
+
+    mu          sync.Mutex
+    meta        *metadata
+    keys        jwk.Set
+    keysFetched time.Time
+}
+
+func New(cfg Config) *Provider {
+    return &Provider{
+        cfg:    cfg,
+        client: &http.Client{Timeout: 10 * time.Second},
+    }
+}
+
+var provider *Provider
+
+// Init sets up the provider from the config; single sign-on stays off without an issuer.
+func Init() {
+    cfg := config.GetConfig().OIDC
+    if cfg.Issuer == "" {
+        return
+    }
+
+    provider = New(Config{
+        Issuer:       cfg.Issuer,
+        ClientID:     cfg.ClientID,
+        ClientSecret: cfg.ClientSecret,
+        RedirectURL:  cfg.RedirectURL,
+        Scopes:       []string{"email", "profile"},
+    })
+    log.Printf("Using single sign-on with %s", cfg.Issuer)
+}
+
+// Get returns the provider, nil when single sign-on is off.
+func Get() *Provider {
+    return provider
+}
+
+// NewVerifier returns a random PKCE code verifier, also usable as state or nonce.
+func NewVerifier() (string, error) {
+    random := make([]byte, 32)
+    if _, err := rand.Read(random); err != nil {
+        return "", err
+    }
+    return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// Challenge returns the S256 PKCE code challenge of verifier.
+func Challenge(verifier string) string {
+    sum := sha256.Sum256([]byte(verifier))
+    return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns where to send the user to log in. The provider redirects
+// back to the redirect URL with a code and the same state.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+    meta, err := p.discover(ctx)
+    if err != nil {
+        return "", err
+    }
+
+    query := url.Values{
+        "response_type":         {"code"},
+        "client_id":             {p.cfg.ClientID},
+        "redirect_uri":          {p.cfg.RedirectURL},
+        "scope":                 {strings.Join(append([]string{"openid"}, p.cfg.Scopes...), " ")},
+        "state":                 {state},
+        "nonce":                 {nonce},
+        "code_challenge":        {Challenge(verifier)},
+        "code_challenge_method": {"S256"},
+    }
+
+    separator := "?"
+    if strings.Contains(meta.AuthorizationEndpoint, "?") {
+        separator = "&"
+    }
+    return meta.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades an authorization code for the verified claims of its ID token.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+    meta, err := p.discover(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    form := url.Values{
+        "grant_type":    {"authorization_code"},
+        "code":          {code},
+        "redirect_uri":  {p.cfg.RedirectURL},
+        "code_verifier": {verifier},
+        "client_id":     {p.cfg.ClientID},
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.Header.Set("Accept", "application/json")
+    if p.cfg.ClientSecret != "" {
+        // client_secret_basic, both parts form encoded first (RFC 6749 section 2.3.1)
+        req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+    }
+
+    var token struct {
+        IDToken          string `json:"id_token"`
+        Error            string `json:"error"`
+        ErrorDescription string `json:"error_description"`
+    }
+    status, err := p.fetchJSON(req, &token)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+    }
+    if status != http.StatusOK || token.Error != "" {
+        return nil, fmt.Errorf("%w: %d %s %s", ErrExchange, status, token.Error, token.ErrorDescription)
+    }
+    if token.IDToken == "" {
+        return nil, fmt.Errorf("%w: no id_token in response", ErrExchange)
+    }
+
+    return p.Verify(ctx, token.IDToken, nonce)
+}
+
+// Verify checks the signature, issuer, audience, expiry and nonce of an ID token.
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+    meta, err := p.discover(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    var claims Claims
+    parser := jwt.NewParser(jwt.WithValidMethods(signingMethods))
+    _, err = parser.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (interface{}, error) {
+        kid, _ := token.Header["kid"].(string)
+        return p.key(ctx, kid)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+    }
+
+    switch {
+    case !claims.VerifyIssuer(meta.Issuer, true):
+        return nil, fmt.Errorf("%w: issuer %q", ErrInvalidIDToken, claims.Issuer)
+    case !claims.VerifyAudience(p.cfg.ClientID, true):
+        return nil, fmt.Errorf("%w: not issued to this client", ErrInvalidIDToken)
+    case len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID:
+        return nil, fmt.Errorf("%w: authorized party %q", ErrInvalidIDToken, claims.AuthorizedParty)
+    case claims.ExpiresAt == nil:
+        return nil, fmt.Errorf("%w: no expiry", ErrInvalidIDToken)
+    case claims.Subject == "":
+        return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+    case subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+        return nil, fmt.Errorf("%w: nonce does not match", ErrInvalidIDToken)
+    }
+    return &claims, nil
+}
+
+// discover fetches the discovery document once it is first needed.
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if p.meta != nil {
+        return p.meta, nil
+    }
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+        strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", nil)
+    if err != nil {
+        return nil, err
+    }
+    var meta metadata
+    status, err := p.fetchJSON(req, &meta)
+    if err != nil {
+        return nil, fmt.Errorf("oidc discovery: %w", err)
+    }
+    if status != http.StatusOK {
+        return nil, fmt.Errorf("oidc discovery: status %d", status)
+    }
+    // The document must be about the configured issuer, OpenID Connect Discovery section 4.3
+    if meta.Issuer != p.cfg.Issuer {
+        return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", meta.Issuer, p.cfg.Issuer)
+    }
+    if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+        return nil, errors.New("oidc discovery: document is missing endpoints")
+    }
+
+    p.meta = &meta
+    return p.meta, nil
+}
+
+// key returns the provider's signing key with kid. An unknown kid refetches the
+// key set, as providers rotate keys, but not more often than keyRefetchDelay.
+func (p *Provider) key(ctx context.Context, kid string) (interface{}, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    key, ok := p.keys.Find(kid)
+    if !ok && time.Since(p.keysFetched) > keyRefetchDelay {
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.meta.JWKSURI, nil)
+        if err != nil {
+            return nil, err
+        }
+        var keys jwk.Set
+        status, err := p.fetchJSON(req, &keys)
+        if err != nil {
+            return nil, fmt.Errorf("fetching oidc keys: %w", err)
+        }
+        if status != http.StatusOK {
+            return nil, fmt.Errorf("fetching oidc keys: status %d", status)
+        }
+        p.keys = keys
+        p.keysFetched = time.Now()
+        key, ok = p.keys.Find(kid)
+    }
+    if !ok {
+        return nil, fmt.Errorf("unknown signing key %q", kid)
+    }
+    return key.PublicKey()
+}
+
+// fetchJSON sends req and decodes the JSON response into v, whatever its status.
+func (p *Provider) fetchJSON(req *http.Request, v interface{}) (int, error) {
+    resp, err := p.client.Do(req)
+    if err != nil {
+        return 0, err
+    }
+    defer resp.Body.Close()
+
+    if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(v); err != nil {
+        return resp.StatusCode, fmt.Errorf("decoding response: %w", err)
+    }
+    return resp.StatusCode, nil
+}
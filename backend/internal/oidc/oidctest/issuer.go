@@ -0,0 +1,263 @@
+// Package oidctest runs a local OpenID Connect provider, so single sign-on can
+// be exercised in tests and during development without a real identity provider.
+// It logs in a fixed user without asking and keeps everything in memory.
+package oidctest
+
+import (
+    "backend/internal/jwk"
+    "backend/internal/oidc"
+    "crypto/rand"
+    "crypto/rsa"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "sync"
+    "time"
+
+    "github.com/golang-jwt/jwt/v4"
+)
+
+// User is who the issuer logs in.
+type User struct {
+    Subject           string
+    Email             string
+    EmailVerified     bool
+    Name              string
+    PreferredUsername string
+}
+
+// grant is an authorization code waiting to be exchanged.
+type grant struct {
+    redirectURI string
+    challenge   string
+    nonce       string
+    user        User
+    expiresAt   time.Time
+}
+
+// Issuer is a running provider. Set User before a login to change who logs in.
+type Issuer struct {
+    URL          string
+    ClientID     string
+    ClientSecret string
+
+    mu     sync.Mutex
+    User   User
+    codes  map[string]grant
+    key    *rsa.PrivateKey
+    kid    string
+    server *httptest.Server
+}
+
+// NewIssuer starts a provider for one client on a local port; Close stops it.
+func NewIssuer(clientID, clientSecret string, user User) (*Issuer, error) {
+    key, err := rsa.GenerateKey(rand.Reader, 2048)
+    if err != nil {
+        return nil, err
+    }
+
+    issuer := &Issuer{
+        ClientID:     clientID,
+        ClientSecret: clientSecret,
+        User:         user,
+        codes:        map[string]grant{},
+        key:          key,
+        kid:          "oidctest-1",
+    }
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("/.well-known/openid-configuration", issuer.discovery)
+    mux.HandleFunc("/authorize", issuer.authorize)
+    mux.HandleFunc("/token", issuer.token)
+    mux.HandleFunc("/jwks", issuer.jwks)
+    issuer.server = httptest.NewServer(mux)
+    issuer.URL = issuer.server.URL
+
+    return issuer, nil
+}
+
+func (i *Issuer) Close() {
+    i.server.Close()
+}
+
+// Provider returns a relying party for the issuer's client.
+func (i *Issuer) Provider(redirectURL string) *oidc.Provider {
+    return oidc.New(oidc.Config{
+        Issuer:       i.URL,
+        ClientID:     i.ClientID,
+        ClientSecret: i.ClientSecret,
+        RedirectURL:  redirectURL,
+        Scopes:       []string{"email", "profile"},
+    })
+}
+
+// Authorize follows an authorization URL like a browser would and returns the
+// code and state the provider redirected back with.
+func (i *Issuer) Authorize(authURL string) (code, state string, err error) {
+    client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+        return http.ErrUseLastResponse
+    }}
+    resp, err := client.Get(authURL)
+    if err != nil {
+        return "", "", err
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusFound {
+        return "", "", fmt.Errorf("authorize: status %d", resp.StatusCode)
+    }
+
+    location, err := url.Parse(resp.Header.Get("Location"))
+    if err != nil {
+        return "", "", err
+    }
+    query := location.Query()
+    if query.Get("error") != "" {
+        return "", "", errors.New("authorize: " + query.Get("error"))
+    }
+    return query.Get("code"), query.Get("state"), nil
+}
+
+// Sign signs claims with the issuer's key, e.g. to hand a relying party a
+// forged or expired ID token.
+func (i *Issuer) Sign(claims jwt.Claims) (string, error) {
+    token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+    token.Header["kid"] = i.kid
+    return token.SignedString(i.key)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+func tokenError(w http.ResponseWriter, status int, code string) {
+    writeJSON(w, status, map[string]string{"error": code})
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "issuer":                                i.URL,
+        "authorization_endpoint":                i.URL + "/authorize",
+        "token_endpoint":                        i.URL + "/token",
+        "jwks_uri":                              i.URL + "/jwks",
+        "response_types_supported":              []string{"code"},
+        "subject_types_supported":               []string{"public"},
+        "id_token_signing_alg_values_supported": []string{"RS256"},
+        "code_challenge_methods_supported":      []string{"S256"},
+    })
+}
+
+func (i *Issuer) jwks(w http.ResponseWriter, r *http.Request) {
+    key, err := jwk.New(i.kid, "RS256", &i.key.PublicKey)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    writeJSON(w, http.StatusOK, jwk.Set{Keys: []jwk.Key{key}})
+}
+
+// authorize logs in User right away and redirects back with a code.
+func (i *Issuer) authorize(w http.ResponseWriter, r *http.Request) {
+    query := r.URL.Query()
+    redirectURI := query.Get("redirect_uri")
+    if query.Get("client_id") != i.ClientID || redirectURI == "" {
+        http.Error(w, "unknown client or redirect_uri", http.StatusBadRequest)
+        return
+    }
+    back, err := url.Parse(redirectURI)
+    if err != nil {
+        http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+        return
+    }
+
+    values := back.Query()
+    values.Set("state", query.Get("state"))
+    if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+        values.Set("error", "invalid_request")
+    } else {
+        random := make([]byte, 16)
+        rand.Read(random)
+        code := hex.EncodeToString(random)
+
+        i.mu.Lock()
+        i.codes[code] = grant{
+            redirectURI: redirectURI,
+            challenge:   query.Get("code_challenge"),
+            nonce:       query.Get("nonce"),
+            user:        i.User,
+            expiresAt:   time.Now().Add(time.Minute),
+        }
+        i.mu.Unlock()
+        values.Set("code", code)
+    }
+    back.RawQuery = values.Encode()
+    http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+// token exchanges a code once, checking the client, redirect URI and PKCE verifier.
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        tokenError(w, http.StatusMethodNotAllowed, "invalid_request")
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        tokenError(w, http.StatusBadRequest, "invalid_request")
+        return
+    }
+
+    clientID, secret, ok := r.BasicAuth()
+    if ok {
+        clientID, _ = url.QueryUnescape(clientID)
+        secret, _ = url.QueryUnescape(secret)
+    } else {
+        clientID = r.PostForm.Get("client_id")
+    }
+    if clientID != i.ClientID || secret != i.ClientSecret {
+        tokenError(w, http.StatusUnauthorized, "invalid_client")
+        return
+    }
+
+    i.mu.Lock()
+    code := r.PostForm.Get("code")
+    g, found := i.codes[code]
+    delete(i.codes, code)
+    i.mu.Unlock()
+
+    if r.PostForm.Get("grant_type") != "authorization_code" || !found || time.Now().After(g.expiresAt) ||
+        r.PostForm.Get("redirect_uri") != g.redirectURI || oidc.Challenge(r.PostForm.Get("code_verifier")) != g.challenge {
+        tokenError(w, http.StatusBadRequest, "invalid_grant")
+        return
+    }
+
+    now := time.Now()
+    idToken, err := i.Sign(&oidc.Claims{
+        RegisteredClaims: jwt.RegisteredClaims{
+            Issuer:    i.URL,
+            Subject:   g.user.Subject,
+            Audience:  jwt.ClaimStrings{i.ClientID},
+            IssuedAt:  jwt.NewNumericDate(now),
+            ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+        },
+        Nonce:             g.nonce,
+        Email:             g.user.Email,
+        EmailVerified:     g.user.EmailVerified,
+        Name:              g.user.Name,
+        PreferredUsername: g.user.PreferredUsername,
+    })
+    if err != nil {
+        tokenError(w, http.StatusInternalServerError, "server_error")
+        return
+    }
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "access_token": "oidctest-" + code,
+        "token_type":   "Bearer",
+        "expires_in":   300,
+        "id_token":     idToken,
+    })
+}
@@ -0,0 +1,708 @@
+package repository
+
+import (
+    "backend/internal/models"
+    "context"
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// memoryTimeFormat has a fixed width, so timestamps sort as strings the way
+// Postgres sorts them as times.
+const memoryTimeFormat = "2006-01-02T15:04:05.000000Z"
+
+func memoryNow() string {
+    return time.Now().UTC().Format(memoryTimeFormat)
+}
+
+// memoryJob is a stored job with the columns models.Job does not expose.
+type memoryJob struct {
+    id     int
+    orgID  int
+    userID int
+    job    models.Job
+}
+
+// MemoryJobs keeps jobs in memory, for tests. Text search only approximates
+// Postgres: every word has to appear in the title or description.
+type MemoryJobs struct {
+    mu     sync.Mutex
+    nextID int
+    jobs   []*memoryJob
+}
+
+func NewMemoryJobs() *MemoryJobs {
+    return &MemoryJobs{}
+}
+
+// copyJob returns a deep copy, so callers cannot change stored jobs.
+func copyJob(job *models.Job) *models.Job {
+    clone := *job
+    clone.SkillsRequired = append([]string(nil), job.SkillsRequired...)
+    if job.Attributes != nil {
+        raw, _ := json.Marshal(job.Attributes)
+        clone.Attributes = nil
+        json.Unmarshal(raw, &clone.Attributes)
+    }
+    return &clone
+}
+
+func (r *MemoryJobs) find(orgID int, jobID string) *memoryJob {
+    for _, stored := range r.jobs {
+        if stored.orgID == orgID && stored.job.JobID == jobID {
+            return stored
+        }
+    }
+    return nil
+}
+
+func (r *MemoryJobs) Create(ctx context.Context, orgID, userID int, job *models.Job) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if r.find(orgID, job.JobID) != nil {
+        return ErrDuplicate
+    }
+
+    r.nextID++
+    stored := &memoryJob{id: r.nextID, orgID: orgID, userID: userID, job: *copyJob(job)}
+    stored.job.CreatedAt = memoryNow()
+    stored.job.UpdatedAt = stored.job.CreatedAt
+    r.jobs = append(r.jobs, stored)
+    return nil
+}
+
+func (r *MemoryJobs) Get(ctx context.Context, orgID int, jobID string) (*models.Job, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    stored := r.find(orgID, jobID)
+    if stored == nil {
+        return nil, ErrNotFound
+    }
+    return copyJob(&stored.job), nil
+}
+
+func (r *MemoryJobs) Ref(ctx context.Context, orgID int, jobID string) (int, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    stored := r.find(orgID, jobID)
+    if stored == nil {
+        return 0, ErrNotFound
+    }
+    return stored.id, nil
+}
+
+func (r *MemoryJobs) Update(ctx context.Context, orgID int, job *models.Job, check func(current *models.Job) error) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    stored := r.find(orgID, job.JobID)
+    if stored == nil {
+        return ErrNotFound
+    }
+    if err := check(copyJob(&stored.job)); err != nil {
+        return err
+    }
+
+    updated := copyJob(job)
+    updated.CreatedAt = stored.job.CreatedAt
+    updated.UpdatedAt = memoryNow()
+    stored.job = *updated
+    return nil
+}
+
+func (r *MemoryJobs) Delete(ctx context.Context, orgID int, jobID string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for i, stored := range r.jobs {
+        if stored.orgID == orgID && stored.job.JobID == jobID {
+            r.jobs = append(r.jobs[:i], r.jobs[i+1:]...)
+            return nil
+        }
+    }
+    return ErrNotFound
+}
+
+func (r *MemoryJobs) List(ctx context.Context, orgID int, filter JobFilter, page Page) (*JobPage, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    var matches []*memoryJob
+    for _, stored := range r.jobs {
+        if stored.orgID == orgID && filter.matches(&stored.job) {
+            matches = append(matches, stored)
+        }
+    }
+
+    // before reports whether a sorts before b in the requested order, by sort value then id
+    before := func(aValue string, aID int, bValue string, bID int) bool {
+        if aValue == bValue {
+            return aID != bID && aID < bID != page.Desc
+        }
+        return aValue < bValue != page.Desc
+    }
+    sort.Slice(matches, func(i, j int) bool {
+        a, b := matches[i], matches[j]
+        return before(sortValue(&a.job, page.SortBy), a.id, sortValue(&b.job, page.SortBy), b.id)
+    })
+
+    list := &JobPage{Jobs: []*models.Job{}, Total: len(matches)}
+    var last *memoryJob
+    for _, stored := range matches {
+        if page.After != nil && !before(page.After.Value, page.After.ID, sortValue(&stored.job, page.SortBy), stored.id) {
+            continue
+        }
+        if len(list.Jobs) == page.Limit {
+            list.Next = &Cursor{Value: sortValue(&last.job, page.SortBy), ID: last.id}
+            break
+        }
+        list.Jobs = append(list.Jobs, copyJob(&stored.job))
+        last = stored
+    }
+    return list, nil
+}
+
+// matches applies the filter to one job.
+func (f JobFilter) matches(job *models.Job) bool {
+    if f.Title != "" && !strings.Contains(strings.ToLower(job.JobTitle), strings.ToLower(f.Title)) {
+        return false
+    }
+    if f.Text != "" {
+        text := strings.ToLower(job.JobTitle + " " + job.JobDescription)
+        for _, word := range strings.Fields(strings.ToLower(f.Text)) {
+            if !strings.Contains(text, strings.Trim(word, `"`)) {
+                return false
+            }
+        }
+    }
+    if len(f.Statuses) > 0 && !containsAny(f.Statuses, job.JobStatus) {
+        return false
+    }
+    if len(f.SkillsAny) > 0 && !containsAny(job.SkillsRequired, f.SkillsAny...) {
+        return false
+    }
+    for _, skill := range f.SkillsAll {
+        if !containsAny(job.SkillsRequired, skill) {
+            return false
+        }
+    }
+    if f.CreatedAfter != nil && job.CreatedAt < f.CreatedAfter.UTC().Format(memoryTimeFormat) {
+        return false
+    }
+    if f.CreatedBefore != nil && job.CreatedAt >= f.CreatedBefore.UTC().Format(memoryTimeFormat) {
+        return false
+    }
+    if len(f.Attributes) > 0 && !jsonContains(job.Attributes, f.Attributes) {
+        return false
+    }
+    return true
+}
+
+func containsAny(list []string, values ...string) bool {
+    for _, item := range list {
+        for _, value := range values {
+            if item == value {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// jsonContains mirrors Postgres jsonb containment (@>) after a JSON round trip,
+// so numbers compare the same whatever Go type they started as.
+func jsonContains(have, want interface{}) bool {
+    normalize := func(v interface{}) interface{} {
+        raw, _ := json.Marshal(v)
+        var out interface{}
+        json.Unmarshal(raw, &out)
+        return out
+    }
+    return contains(normalize(have), normalize(want))
+}
+
+func contains(have, want interface{}) bool {
+    switch want := want.(type) {
+    case map[string]interface{}:
+        object, ok := have.(map[string]interface{})
+        if !ok {
+            return false
+        }
+        for key, value := range want {
+            item, ok := object[key]
+            if !ok || !contains(item, value) {
+                return false
+            }
+        }
+        return true
+    case []interface{}:
+        array, ok := have.([]interface{})
+        if !ok {
+            return false
+        }
+        for _, value := range want {
+            found := false
+            for _, item := range array {
+                if contains(item, value) {
+                    found = true
+                    break
+                }
+            }
+            if !found {
+                return false
+            }
+        }
+        return true
+    }
+    return reflect.DeepEqual(have, want)
+}
+
+func (r *MemoryJobs) PublishDue(ctx context.Context, now time.Time) (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    var published int64
+    for _, stored := range r.jobs {
+        job := &stored.job
+        if job.JobStatus == models.JobStatusScheduled && job.PublishAt != nil && !job.PublishAt.After(now) {
+            job.JobStatus = models.JobStatusActive
+            job.UpdatedAt = memoryNow()
+            published++
+        }
+    }
+    return published, nil
+}
+
+func (r *MemoryJobs) CloseExpired(ctx context.Context, now time.Time) (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    var closed int64
+    for _, stored := range r.jobs {
+        job := &stored.job
+        open := job.JobStatus == models.JobStatusActive || job.JobStatus == models.JobStatusPaused
+        if open && job.ExpiresAt != nil && !job.ExpiresAt.After(now) {
+            job.JobStatus = models.JobStatusClosed
+            job.UpdatedAt = memoryNow()
+            closed++
+        }
+    }
+    return closed, nil
+}
+
+// MemoryUsers keeps users and their personal organizations in memory, for tests.
+type MemoryUsers struct {
+    mu            sync.Mutex
+    nextID        int
+    users         map[int]*models.User
+    organizations []memoryOrganization
+}
+
+// memoryOrganization is an organization with its owner, the only member it gets here.
+type memoryOrganization struct {
+    models.Organization
+    ownerID int
+}
+
+func NewMemoryUsers() *MemoryUsers {
+    return &MemoryUsers{users: map[int]*models.User{}}
+}
+
+func (r *MemoryUsers) Create(ctx context.Context, user *models.User) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for _, existing := range r.users {
+        if existing.Email == user.Email {
+            return ErrDuplicate
+        }
+    }
+
+    r.insert(user)
+    return nil
+}
+
+// insert stores a copy of user under the next id, which it sets, and creates the
+// personal organization they own. The caller holds mu.
+func (r *MemoryUsers) insert(user *models.User) *models.User {
+    r.nextID++
+    user.ID = r.nextID
+    stored := *user
+    stored.CreatedAt = memoryNow()
+    stored.UpdatedAt = stored.CreatedAt
+    r.users[user.ID] = &stored
+
+    org := models.Organization{ID: len(r.organizations) + 1, Name: user.Username, Role: models.RoleOwner, CreatedAt: time.Now()}
+    r.organizations = append(r.organizations, memoryOrganization{Organization: org, ownerID: user.ID})
+    return &stored
+}
+
+// Organizations returns the organizations a user owns, oldest first.
+func (r *MemoryUsers) Organizations(userID int) []models.Organization {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    var owned []models.Organization
+    for _, org := range r.organizations {
+        if org.ownerID == userID {
+            owned = append(owned, org.Organization)
+        }
+    }
+    return owned
+}
+
+// freeUsername returns the first username for base no user has. The caller holds mu.
+func (r *MemoryUsers) freeUsername(base string) (string, error) {
+    taken := map[string]bool{}
+    for _, user := range r.users {
+        taken[user.Username] = true
+    }
+    for attempt := 1; attempt <= maxUsernameAttempts; attempt++ {
+        username, err := usernameCandidate(base, attempt)
+        if err != nil {
+            return "", err
+        }
+        if !taken[username] {
+            return username, nil
+        }
+    }
+    return "", fmt.Errorf("no free username for %q", base)
+}
+
+func (r *MemoryUsers) GetByID(ctx context.Context, id int) (*models.User, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    user, ok := r.users[id]
+    if !ok {
+        return nil, ErrNotFound
+    }
+    clone := *user
+    return &clone, nil
+}
+
+func (r *MemoryUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for _, user := range r.users {
+        if user.Email == email {
+            clone := *user
+            return &clone, nil
+        }
+    }
+    return nil, ErrNotFound
+}
+
+// Put stores a user as is, e.g. to verify their email or enable two-factor
+// authentication in a test. No organization is created for them.
+func (r *MemoryUsers) Put(user *models.User) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    clone := *user
+    r.users[user.ID] = &clone
+    if user.ID > r.nextID {
+        r.nextID = user.ID
+    }
+}
+
+// MemorySSO keeps logins and linked accounts in memory, for tests. It creates
+// and takes over users in users.
+type MemorySSO struct {
+    mu         sync.Mutex
+    users      *MemoryUsers
+    logins     map[string]*memorySSOLogin // by state hash
+    identities map[[2]string]int          // issuer and subject to user id
+}
+
+type memorySSOLogin struct {
+    SSOLogin
+    used bool
+}
+
+func NewMemorySSO(users *MemoryUsers) *MemorySSO {
+    return &MemorySSO{users: users, logins: map[string]*memorySSOLogin{}, identities: map[[2]string]int{}}
+}
+
+func (r *MemorySSO) SaveLogin(ctx context.Context, login *SSOLogin) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    now := time.Now()
+    for hash, stored := range r.logins {
+        if !stored.ExpiresAt.After(now) {
+            delete(r.logins, hash)
+        }
+    }
+    r.logins[login.StateHash] = &memorySSOLogin{SSOLogin: *login}
+    return nil
+}
+
+func (r *MemorySSO) ConsumeLogin(ctx context.Context, stateHash, bindingHash string) (*SSOLogin, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    stored, ok := r.logins[stateHash]
+    if !ok || stored.used || stored.BindingHash != bindingHash || !stored.ExpiresAt.After(time.Now()) {
+        return nil, ErrNotFound
+    }
+    stored.used = true
+    login := stored.SSOLogin
+    return &login, nil
+}
+
+// Logins returns how many logins are stored, used ones included.
+func (r *MemorySSO) Logins() int {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    return len(r.logins)
+}
+
+func (r *MemorySSO) LinkedUser(ctx context.Context, issuer, subject string) (int, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    userID, ok := r.identities[[2]string{issuer, subject}]
+    if !ok {
+        return 0, ErrNotFound
+    }
+    return userID, nil
+}
+
+func (r *MemorySSO) Link(ctx context.Context, identity Identity) (int, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.users.mu.Lock()
+    defer r.users.mu.Unlock()
+
+    key := [2]string{identity.Issuer, identity.Subject}
+    if _, ok := r.identities[key]; ok {
+        return 0, ErrDuplicate
+    }
+
+    var user *models.User
+    for _, existing := range r.users.users {
+        if strings.EqualFold(existing.Email, identity.Email) {
+            user = existing
+        }
+    }
+
+    now := time.Now()
+    switch {
+    case user == nil:
+        username, err := r.users.freeUsername(identity.Username)
+        if err != nil {
+            return 0, err
+        }
+        user = r.users.insert(&models.User{Username: username, Email: identity.Email, EmailVerifiedAt: &now})
+    case user.EmailVerifiedAt == nil:
+        // Sessions and API keys are not kept here, the password and two-factor setup are
+        user.PasswordHash = ""
+        user.TOTPEnabledAt = nil
+        user.EmailVerifiedAt = &now
+        user.UpdatedAt = memoryNow()
+    }
+
+    r.identities[key] = user.ID
+    return user.ID, nil
+}
+
+// MemoryMFA keeps challenges and second factors in memory, for tests. Whether
+// two-factor authentication is enabled is kept on the users in users, so logins
+// see it.
+type MemoryMFA struct {
+    mu         sync.Mutex
+    users      *MemoryUsers
+    challenges map[string]*memoryMFAChallenge // by token hash
+    factors    map[int]*memorySecondFactor    // by user id
+}
+
+type memoryMFAChallenge struct {
+    userID    int
+    attempts  int
+    expiresAt time.Time
+    used      bool
+}
+
+type memorySecondFactor struct {
+    secret   string
+    lastStep *int64
+    recovery map[string]bool // code hash to whether it was used
+}
+
+func NewMemoryMFA(users *MemoryUsers) *MemoryMFA {
+    return &MemoryMFA{users: users, challenges: map[string]*memoryMFAChallenge{}, factors: map[int]*memorySecondFactor{}}
+}
+
+func (r *MemoryMFA) CreateChallenge(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    r.challenges[tokenHash] = &memoryMFAChallenge{userID: userID, expiresAt: expiresAt}
+    return nil
+}
+
+// challenge returns the challenge if it still takes codes. The caller holds mu.
+func (r *MemoryMFA) challenge(tokenHash string, maxAttempts int) *memoryMFAChallenge {
+    challenge, ok := r.challenges[tokenHash]
+    if !ok || challenge.used || !challenge.expiresAt.After(time.Now()) || challenge.attempts >= maxAttempts {
+        return nil
+    }
+    return challenge
+}
+
+func (r *MemoryMFA) Challenge(ctx context.Context, tokenHash string, maxAttempts int) (*MFAChallenge, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    challenge := r.challenge(tokenHash, maxAttempts)
+    if challenge == nil {
+        return nil, ErrNotFound
+    }
+    user, err := r.users.GetByID(ctx, challenge.userID)
+    if err != nil {
+        return nil, err
+    }
+    return &MFAChallenge{UserID: user.ID, Email: user.Email}, nil
+}
+
+func (r *MemoryMFA) AttemptChallenge(ctx context.Context, tokenHash string, maxAttempts int) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    challenge := r.challenge(tokenHash, maxAttempts)
+    if challenge == nil {
+        return ErrNotFound
+    }
+    challenge.attempts++
+    return nil
+}
+
+func (r *MemoryMFA) FinishChallenge(ctx context.Context, tokenHash string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    challenge, ok := r.challenges[tokenHash]
+    if !ok || challenge.used {
+        return ErrNotFound
+    }
+    challenge.used = true
+    return nil
+}
+
+func (r *MemoryMFA) SecondFactor(ctx context.Context, userID int) (*SecondFactor, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    user, err := r.users.GetByID(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    factor := &SecondFactor{Email: user.Email, EnabledAt: user.TOTPEnabledAt}
+    if stored, ok := r.factors[userID]; ok {
+        factor.Secret = stored.secret
+    }
+    return factor, nil
+}
+
+func (r *MemoryMFA) StartEnrollment(ctx context.Context, userID int, secret string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    user, err := r.users.GetByID(ctx, userID)
+    if err != nil {
+        return err
+    }
+    if user.TOTPEnabledAt != nil {
+        return ErrConflict
+    }
+    r.factors[userID] = &memorySecondFactor{secret: secret}
+    return nil
+}
+
+func (r *MemoryMFA) Enable(ctx context.Context, userID int, secret string, step int64, recoveryHashes []string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.users.mu.Lock()
+    defer r.users.mu.Unlock()
+
+    user, ok := r.users.users[userID]
+    factor, enrolling := r.factors[userID]
+    if !ok || !enrolling || user.TOTPEnabledAt != nil || factor.secret != secret {
+        return ErrConflict
+    }
+    now := time.Now()
+    user.TOTPEnabledAt = &now
+    factor.lastStep = &step
+    factor.recovery = recoveryCodeSet(recoveryHashes)
+    return nil
+}
+
+func recoveryCodeSet(hashes []string) map[string]bool {
+    set := map[string]bool{}
+    for _, hash := range hashes {
+        set[hash] = false
+    }
+    return set
+}
+
+func (r *MemoryMFA) Disable(ctx context.Context, userID int) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.users.mu.Lock()
+    defer r.users.mu.Unlock()
+
+    if user, ok := r.users.users[userID]; ok {
+        user.TOTPEnabledAt = nil
+    }
+    delete(r.factors, userID)
+    return nil
+}
+
+func (r *MemoryMFA) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    factor, ok := r.factors[userID]
+    if !ok || (factor.lastStep != nil && *factor.lastStep >= step) {
+        return ErrConflict
+    }
+    factor.lastStep = &step
+    return nil
+}
+
+func (r *MemoryMFA) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    factor, ok := r.factors[userID]
+    if !ok {
+        return ErrNotFound
+    }
+    if used, ok := factor.recovery[codeHash]; !ok || used {
+        return ErrNotFound
+    }
+    factor.recovery[codeHash] = true
+    return nil
+}
+
+func (r *MemoryMFA) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    factor, ok := r.factors[userID]
+    if !ok {
+        return ErrNotFound
+    }
+    factor.recovery = recoveryCodeSet(codeHashes)
+    return nil
+}
@@ -0,0 +1,670 @@
+package repository
+
+import (
+    "backend/internal/database"
+    "backend/internal/models"
+    "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/jmoiron/sqlx"
+    "github.com/lib/pq"
+)
+
+// PostgresJobs stores jobs in the jobs table.
+type PostgresJobs struct {
+    db *sqlx.DB
+}
+
+func NewPostgresJobs(db *sqlx.DB) *PostgresJobs {
+    return &PostgresJobs{db: db}
+}
+
+// jobColumns are selected by every job query and read back by scanJob.
+const jobColumns = `id, job_id, job_title, job_description, job_status, skills_required, attributes,
+    publish_at, expires_at, created_at, updated_at`
+
+// jobSortTypes maps sort keys to the type their cursor value is cast to.
+var jobSortTypes = map[string]string{
+    "created_at": "timestamp",
+    "updated_at": "timestamp",
+    "job_title":  "text",
+}
+
+// scanJob reads a row of jobColumns. The internal id is returned separately as
+// listings use it for their cursor but do not expose it.
+func scanJob(row interface{ Scan(dest ...interface{}) error }) (*models.Job, int, error) {
+    var job models.Job
+    var id int
+    var skillsRequired pq.StringArray
+    var attributesJSON []byte
+
+    err := row.Scan(
+        &id,
+        &job.JobID,
+        &job.JobTitle,
+        &job.JobDescription,
+        &job.JobStatus,
+        &skillsRequired,
+        &attributesJSON,
+        &job.PublishAt,
+        &job.ExpiresAt,
+        &job.CreatedAt,
+        &job.UpdatedAt,
+    )
+    if err != nil {
+        return nil, 0, err
+    }
+
+    job.SkillsRequired = []string(skillsRequired)
+
+    // Unmarshal attributes JSON
+    if err := json.Unmarshal(attributesJSON, &job.Attributes); err != nil {
+        return nil, 0, err
+    }
+
+    return &job, id, nil
+}
+
+func (r *PostgresJobs) Create(ctx context.Context, orgID, userID int, job *models.Job) error {
+    // Convert map to JSON for attributes
+    attributesJSON, err := json.Marshal(job.Attributes)
+    if err != nil {
+        return err
+    }
+
+    // The organization owns the job, user_id records who created it
+    query := `INSERT INTO jobs (
+        job_id,
+        org_id,
+        user_id,
+        job_title,
+        job_description,
+        job_status,
+        skills_required,
+        attributes,
+        publish_at,
+        expires_at
+    ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+    _, err = r.db.ExecContext(ctx, query,
+        job.JobID,
+        orgID,
+        userID,
+        job.JobTitle,
+        job.JobDescription,
+        job.JobStatus,
+        pq.Array(job.SkillsRequired),
+        attributesJSON,
+        job.PublishAt,
+        job.ExpiresAt)
+    if database.IsUniqueViolation(err, "jobs_org_id_job_id_key") {
+        return ErrDuplicate
+    }
+    return err
+}
+
+func (r *PostgresJobs) Get(ctx context.Context, orgID int, jobID string) (*models.Job, error) {
+    query := `SELECT ` + jobColumns + ` FROM jobs WHERE job_id = $1 AND org_id = $2`
+
+    job, _, err := scanJob(r.db.QueryRowContext(ctx, query, jobID, orgID))
+    if err == sql.ErrNoRows {
+        return nil, ErrNotFound
+    }
+    return job, err
+}
+
+func (r *PostgresJobs) Ref(ctx context.Context, orgID int, jobID string) (int, error) {
+    var ref int
+    err := r.db.GetContext(ctx, &ref, "SELECT id FROM jobs WHERE job_id = $1 AND org_id = $2", jobID, orgID)
+    if err == sql.ErrNoRows {
+        return 0, ErrNotFound
+    }
+    return ref, err
+}
+
+func (r *PostgresJobs) Update(ctx context.Context, orgID int, job *models.Job, check func(current *models.Job) error) error {
+    // Convert map to JSON for attributes
+    attributesJSON, err := json.Marshal(job.Attributes)
+    if err != nil {
+        return err
+    }
+
+    return database.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+        // Lock the job, so the scheduler cannot publish or close it between check and update
+        query := `SELECT ` + jobColumns + ` FROM jobs WHERE job_id = $1 AND org_id = $2 FOR UPDATE`
+        current, id, err := scanJob(tx.QueryRowContext(ctx, query, job.JobID, orgID))
+        if err == sql.ErrNoRows {
+            return ErrNotFound
+        }
+        if err != nil {
+            return err
+        }
+        if err := check(current); err != nil {
+            return err
+        }
+
+        query = `UPDATE jobs SET
+            job_title = $1,
+            job_description = $2,
+            job_status = $3,
+            skills_required = $4,
+            attributes = $5,
+            publish_at = $6,
+            expires_at = $7,
+            updated_at = CURRENT_TIMESTAMP
+            WHERE id = $8`
+        _, err = tx.ExecContext(ctx, query,
+            job.JobTitle,
+            job.JobDescription,
+            job.JobStatus,
+            pq.Array(job.SkillsRequired),
+            attributesJSON,
+            job.PublishAt,
+            job.ExpiresAt,
+            id)
+        return err
+    })
+}
+
+func (r *PostgresJobs) Delete(ctx context.Context, orgID int, jobID string) error {
+    result, err := r.db.ExecContext(ctx, "DELETE FROM jobs WHERE job_id = $1 AND org_id = $2", jobID, orgID)
+    if err != nil {
+        return err
+    }
+
+    affected, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if affected == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+// where builds the WHERE clause of a filter, numbering placeholders from $1.
+func (f JobFilter) where(orgID int) (string, []interface{}, error) {
+    args := []interface{}{orgID}
+    conds := []string{"org_id = $1"}
+    arg := func(v interface{}) string {
+        args = append(args, v)
+        return fmt.Sprintf("$%d", len(args))
+    }
+
+    if f.Title != "" {
+        conds = append(conds, "job_title ILIKE "+arg(database.ContainsPattern(f.Title))+` ESCAPE '\'`)
+    }
+    if f.Text != "" {
+        conds = append(conds, "search_vector @@ websearch_to_tsquery('english', "+arg(f.Text)+")")
+    }
+    if len(f.Statuses) > 0 {
+        conds = append(conds, "job_status = ANY("+arg(pq.Array(f.Statuses))+")")
+    }
+    if len(f.SkillsAny) > 0 {
+        conds = append(conds, "skills_required && "+arg(pq.Array(f.SkillsAny))+"::varchar[]")
+    }
+    if len(f.SkillsAll) > 0 {
+        conds = append(conds, "skills_required @> "+arg(pq.Array(f.SkillsAll))+"::varchar[]")
+    }
+    if f.CreatedAfter != nil {
+        conds = append(conds, "created_at >= "+arg(*f.CreatedAfter))
+    }
+    if f.CreatedBefore != nil {
+        conds = append(conds, "created_at < "+arg(*f.CreatedBefore))
+    }
+    if len(f.Attributes) > 0 {
+        // Containment can use the GIN index on attributes
+        attributesJSON, err := json.Marshal(f.Attributes)
+        if err != nil {
+            return "", nil, err
+        }
+        conds = append(conds, "attributes @> "+arg(string(attributesJSON))+"::jsonb")
+    }
+
+    return strings.Join(conds, " AND "), args, nil
+}
+
+// keyset returns the cursor condition, ORDER BY and LIMIT to append to a WHERE
+// clause, numbering its placeholders after args. One row more than the limit is
+// requested so the caller can tell whether there is a next page.
+func (p Page) keyset(args []interface{}) (string, []interface{}, error) {
+    castType, ok := jobSortTypes[p.SortBy]
+    if !ok {
+        return "", nil, fmt.Errorf("cannot sort jobs by %q", p.SortBy)
+    }
+
+    dir, cmp := "ASC", ">"
+    if p.Desc {
+        dir, cmp = "DESC", "<"
+    }
+
+    var clause string
+    if p.After != nil {
+        args = append(args, p.After.Value, p.After.ID)
+        clause = fmt.Sprintf(" AND (%s, id) %s ($%d::%s, $%d)", p.SortBy, cmp, len(args)-1, castType, len(args))
+    }
+
+    args = append(args, p.Limit+1)
+    clause += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT $%d", p.SortBy, dir, dir, len(args))
+
+    return clause, args, nil
+}
+
+func (r *PostgresJobs) List(ctx context.Context, orgID int, filter JobFilter, page Page) (*JobPage, error) {
+    where, args, err := filter.where(orgID)
+    if err != nil {
+        return nil, err
+    }
+    where = "(" + where + ")"
+
+    list := &JobPage{Jobs: []*models.Job{}}
+    err = r.db.GetContext(ctx, &list.Total, "SELECT COUNT(*) FROM jobs WHERE "+where, args...)
+    if err != nil {
+        return nil, err
+    }
+
+    clause, args, err := page.keyset(args)
+    if err != nil {
+        return nil, err
+    }
+
+    rows, err := r.db.QueryContext(ctx, "SELECT "+jobColumns+" FROM jobs WHERE "+where+clause, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var ids []int
+    for rows.Next() {
+        job, id, err := scanJob(rows)
+        if err != nil {
+            return nil, err
+        }
+        ids = append(ids, id)
+        list.Jobs = append(list.Jobs, job)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    // One row past the limit was fetched to learn whether another page exists
+    if len(list.Jobs) > page.Limit {
+        list.Jobs = list.Jobs[:page.Limit]
+        last := list.Jobs[page.Limit-1]
+        list.Next = &Cursor{Value: sortValue(last, page.SortBy), ID: ids[page.Limit-1]}
+    }
+
+    return list, nil
+}
+
+// sortValue returns the value of job's sort column, as kept in a cursor.
+func sortValue(job *models.Job, sortBy string) string {
+    switch sortBy {
+    case "updated_at":
+        return job.UpdatedAt
+    case "job_title":
+        return job.JobTitle
+    }
+    return job.CreatedAt
+}
+
+func (r *PostgresJobs) PublishDue(ctx context.Context, now time.Time) (int64, error) {
+    result, err := r.db.ExecContext(ctx, `UPDATE jobs SET job_status = $1, updated_at = CURRENT_TIMESTAMP
+        WHERE job_status = $2 AND publish_at <= $3`,
+        models.JobStatusActive, models.JobStatusScheduled, now)
+    if err != nil {
+        return 0, err
+    }
+    return result.RowsAffected()
+}
+
+func (r *PostgresJobs) CloseExpired(ctx context.Context, now time.Time) (int64, error) {
+    result, err := r.db.ExecContext(ctx, `UPDATE jobs SET job_status = $1, updated_at = CURRENT_TIMESTAMP
+        WHERE job_status IN ($2, $3) AND expires_at <= $4`,
+        models.JobStatusClosed, models.JobStatusActive, models.JobStatusPaused, now)
+    if err != nil {
+        return 0, err
+    }
+    return result.RowsAffected()
+}
+
+// PostgresUsers stores users in the users table.
+type PostgresUsers struct {
+    db *sqlx.DB
+}
+
+func NewPostgresUsers(db *sqlx.DB) *PostgresUsers {
+    return &PostgresUsers{db: db}
+}
+
+// userColumns are selected by every user query; users without a password, e.g.
+// created by single sign-on, get an empty hash that never matches.
+const userColumns = `id, email, COALESCE(password_hash, '') AS password_hash, username,
+    created_at, updated_at, email_verified_at, totp_enabled_at`
+
+func (r *PostgresUsers) Create(ctx context.Context, user *models.User) error {
+    return database.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+        err := tx.GetContext(ctx, &user.ID,
+            `INSERT INTO users (email, password_hash, username)
+             VALUES ($1, $2, $3)
+             RETURNING id`, user.Email, user.PasswordHash, user.Username)
+        if database.IsUniqueViolation(err, "users_email_key") {
+            return ErrDuplicate
+        }
+        if err != nil {
+            return err
+        }
+
+        // Every user starts in an organization of their own, colleagues can be added to it later
+        _, err = CreateOrganization(ctx, tx, user.Username, user.ID)
+        return err
+    })
+}
+
+// CreateOrganization creates an organization owned by ownerID inside tx.
+func CreateOrganization(ctx context.Context, tx *sqlx.Tx, name string, ownerID int) (*models.Organization, error) {
+    org := models.Organization{Name: name, Role: models.RoleOwner}
+    err := tx.QueryRowContext(ctx,
+        "INSERT INTO organizations (name) VALUES ($1) RETURNING id, created_at", name).Scan(&org.ID, &org.CreatedAt)
+    if err != nil {
+        return nil, err
+    }
+
+    _, err = tx.ExecContext(ctx,
+        "INSERT INTO memberships (org_id, user_id, role) VALUES ($1, $2, $3)", org.ID, ownerID, models.RoleOwner)
+    if err != nil {
+        return nil, err
+    }
+    return &org, nil
+}
+
+func (r *PostgresUsers) get(ctx context.Context, where string, arg interface{}) (*models.User, error) {
+    var user models.User
+    err := r.db.GetContext(ctx, &user, "SELECT "+userColumns+" FROM users WHERE "+where, arg)
+    if err == sql.ErrNoRows {
+        return nil, ErrNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &user, nil
+}
+
+func (r *PostgresUsers) GetByID(ctx context.Context, id int) (*models.User, error) {
+    return r.get(ctx, "id = $1", id)
+}
+
+func (r *PostgresUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+    return r.get(ctx, "email = $1", email)
+}
+
+// PostgresSSO stores logins in sso_logins and linked accounts in user_identities.
+type PostgresSSO struct {
+    db *sqlx.DB
+}
+
+func NewPostgresSSO(db *sqlx.DB) *PostgresSSO {
+    return &PostgresSSO{db: db}
+}
+
+func (r *PostgresSSO) SaveLogin(ctx context.Context, login *SSOLogin) error {
+    // Logins are only started here, so this keeps abandoned ones from piling up
+    if _, err := r.db.ExecContext(ctx, "DELETE FROM sso_logins WHERE expires_at <= NOW()"); err != nil {
+        return err
+    }
+
+    _, err := r.db.ExecContext(ctx,
+        `INSERT INTO sso_logins (state_hash, binding_hash, nonce, code_verifier, expires_at)
+         VALUES ($1, $2, $3, $4, $5)`,
+        login.StateHash, login.BindingHash, login.Nonce, login.CodeVerifier, login.ExpiresAt)
+    return err
+}
+
+func (r *PostgresSSO) ConsumeLogin(ctx context.Context, stateHash, bindingHash string) (*SSOLogin, error) {
+    login := SSOLogin{StateHash: stateHash, BindingHash: bindingHash}
+    err := r.db.QueryRowContext(ctx,
+        `UPDATE sso_logins SET used_at = NOW()
+         WHERE state_hash = $1 AND binding_hash = $2 AND used_at IS NULL AND expires_at > NOW()
+         RETURNING nonce, code_verifier, expires_at`, stateHash, bindingHash).Scan(
+        &login.Nonce, &login.CodeVerifier, &login.ExpiresAt)
+    if err == sql.ErrNoRows {
+        return nil, ErrNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &login, nil
+}
+
+func (r *PostgresSSO) LinkedUser(ctx context.Context, issuer, subject string) (int, error) {
+    var userID int
+    err := r.db.GetContext(ctx, &userID,
+        "SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2", issuer, subject)
+    if err == sql.ErrNoRows {
+        return 0, ErrNotFound
+    }
+    return userID, err
+}
+
+func (r *PostgresSSO) Link(ctx context.Context, identity Identity) (int, error) {
+    var userID int
+    err := database.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+        var existing struct {
+            ID              int        `db:"id"`
+            EmailVerifiedAt *time.Time `db:"email_verified_at"`
+        }
+        err := tx.GetContext(ctx, &existing,
+            "SELECT id, email_verified_at FROM users WHERE LOWER(email) = LOWER($1) FOR UPDATE", identity.Email)
+        switch {
+        case err == sql.ErrNoRows:
+            userID, err = provisionUser(ctx, tx, identity)
+        case err == nil && existing.EmailVerifiedAt == nil:
+            userID = existing.ID
+            err = takeOverUser(ctx, tx, userID)
+        case err == nil:
+            userID = existing.ID
+        }
+        if err != nil {
+            return err
+        }
+
+        _, err = tx.ExecContext(ctx,
+            "INSERT INTO user_identities (user_id, issuer, subject) VALUES ($1, $2, $3)",
+            userID, identity.Issuer, identity.Subject)
+        return err
+    })
+    if database.IsUniqueViolation(err, "user_identities_issuer_subject_key") || database.IsUniqueViolation(err, "users_email_key") {
+        return 0, ErrDuplicate
+    }
+    return userID, err
+}
+
+// takeOverUser removes every way into an account that someone else than the owner
+// of its email could have set up, and marks the email verified.
+func takeOverUser(ctx context.Context, tx *sqlx.Tx, userID int) error {
+    statements := []string{
+        `UPDATE users SET password_hash = NULL, email_verified_at = NOW(), totp_secret = NULL,
+         totp_enabled_at = NULL, totp_last_step = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1`,
+        "DELETE FROM recovery_codes WHERE user_id = $1",
+        "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL",
+        "UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL",
+    }
+    for _, statement := range statements {
+        if _, err := tx.ExecContext(ctx, statement, userID); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// provisionUser creates a user without a password, and their personal
+// organization. Usernames are unique, taken ones get a suffix.
+func provisionUser(ctx context.Context, tx *sqlx.Tx, identity Identity) (int, error) {
+    var userID int
+    for attempt := 1; attempt <= maxUsernameAttempts; attempt++ {
+        username, err := usernameCandidate(identity.Username, attempt)
+        if err != nil {
+            return 0, err
+        }
+
+        err = tx.GetContext(ctx, &userID,
+            `INSERT INTO users (email, username, email_verified_at) VALUES ($1, $2, NOW())
+             ON CONFLICT (username) DO NOTHING
+             RETURNING id`, identity.Email, username)
+        if err == nil {
+            if _, err := CreateOrganization(ctx, tx, username, userID); err != nil {
+                return 0, err
+            }
+            return userID, nil
+        }
+        if err != sql.ErrNoRows {
+            return 0, err
+        }
+    }
+    return 0, fmt.Errorf("no free username for %q", identity.Username)
+}
+
+// PostgresMFA stores challenges in mfa_challenges, secrets in users and recovery
+// codes in recovery_codes.
+type PostgresMFA struct {
+    db *sqlx.DB
+}
+
+func NewPostgresMFA(db *sqlx.DB) *PostgresMFA {
+    return &PostgresMFA{db: db}
+}
+
+// execOne runs a statement meant to change one row and returns errNone when it
+// changed none, because its conditions no longer held.
+func execOne(ctx context.Context, q sqlx.ExecerContext, errNone error, query string, args ...interface{}) error {
+    result, err := q.ExecContext(ctx, query, args...)
+    if err != nil {
+        return err
+    }
+    n, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if n == 0 {
+        return errNone
+    }
+    return nil
+}
+
+func (r *PostgresMFA) CreateChallenge(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+    _, err := r.db.ExecContext(ctx,
+        "INSERT INTO mfa_challenges (user_id, token_hash, expires_at) VALUES ($1, $2, $3)",
+        userID, tokenHash, expiresAt)
+    return err
+}
+
+func (r *PostgresMFA) Challenge(ctx context.Context, tokenHash string, maxAttempts int) (*MFAChallenge, error) {
+    var challenge MFAChallenge
+    err := r.db.QueryRowContext(ctx,
+        `SELECT c.user_id, u.email
+         FROM mfa_challenges c JOIN users u ON u.id = c.user_id
+         WHERE c.token_hash = $1 AND c.used_at IS NULL AND c.expires_at > NOW() AND c.attempts < $2`,
+        tokenHash, maxAttempts).Scan(&challenge.UserID, &challenge.Email)
+    if err == sql.ErrNoRows {
+        return nil, ErrNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &challenge, nil
+}
+
+func (r *PostgresMFA) AttemptChallenge(ctx context.Context, tokenHash string, maxAttempts int) error {
+    return execOne(ctx, r.db, ErrNotFound,
+        `UPDATE mfa_challenges SET attempts = attempts + 1
+         WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW() AND attempts < $2`,
+        tokenHash, maxAttempts)
+}
+
+func (r *PostgresMFA) FinishChallenge(ctx context.Context, tokenHash string) error {
+    return execOne(ctx, r.db, ErrNotFound,
+        "UPDATE mfa_challenges SET used_at = NOW() WHERE token_hash = $1 AND used_at IS NULL", tokenHash)
+}
+
+func (r *PostgresMFA) SecondFactor(ctx context.Context, userID int) (*SecondFactor, error) {
+    var factor struct {
+        Email     string         `db:"email"`
+        Secret    sql.NullString `db:"totp_secret"`
+        EnabledAt *time.Time     `db:"totp_enabled_at"`
+    }
+    err := r.db.GetContext(ctx, &factor,
+        "SELECT email, totp_secret, totp_enabled_at FROM users WHERE id = $1", userID)
+    if err == sql.ErrNoRows {
+        return nil, ErrNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &SecondFactor{Email: factor.Email, Secret: factor.Secret.String, EnabledAt: factor.EnabledAt}, nil
+}
+
+func (r *PostgresMFA) StartEnrollment(ctx context.Context, userID int, secret string) error {
+    return execOne(ctx, r.db, ErrConflict,
+        `UPDATE users SET totp_secret = $1, totp_last_step = NULL
+         WHERE id = $2 AND totp_enabled_at IS NULL`, secret, userID)
+}
+
+func (r *PostgresMFA) Enable(ctx context.Context, userID int, secret string, step int64, recoveryHashes []string) error {
+    return database.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+        err := execOne(ctx, tx, ErrConflict,
+            `UPDATE users SET totp_enabled_at = NOW(), totp_last_step = $1
+             WHERE id = $2 AND totp_secret = $3 AND totp_enabled_at IS NULL`, step, userID, secret)
+        if err != nil {
+            return err
+        }
+        return replaceRecoveryCodes(ctx, tx, userID, recoveryHashes)
+    })
+}
+
+func (r *PostgresMFA) Disable(ctx context.Context, userID int) error {
+    return database.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+        _, err := tx.ExecContext(ctx,
+            "UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL WHERE id = $1", userID)
+        if err != nil {
+            return err
+        }
+        _, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID)
+        return err
+    })
+}
+
+func (r *PostgresMFA) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+    // One statement, so two requests with the same code cannot both see the old step
+    return execOne(ctx, r.db, ErrConflict,
+        `UPDATE users SET totp_last_step = $1
+         WHERE id = $2 AND totp_enabled_at IS NOT NULL AND (totp_last_step IS NULL OR totp_last_step < $1)`,
+        step, userID)
+}
+
+func (r *PostgresMFA) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+    return execOne(ctx, r.db, ErrNotFound,
+        `UPDATE recovery_codes SET used_at = NOW()
+         WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, codeHash)
+}
+
+func (r *PostgresMFA) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+    return database.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+        return replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+    })
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, userID int, codeHashes []string) error {
+    if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+        return err
+    }
+    for _, hash := range codeHashes {
+        _, err := tx.ExecContext(ctx, "INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
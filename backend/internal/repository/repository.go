@@ -0,0 +1,195 @@
+// Package repository stores jobs, users, their single sign-on accounts and second
+// factors behind interfaces, so services can run against Postgres in production
+// and against memory in tests.
+package repository
+
+import (
+    "backend/internal/models"
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "time"
+)
+
+var (
+    ErrNotFound  = errors.New("not found")
+    ErrDuplicate = errors.New("already exists")
+    ErrConflict  = errors.New("changed concurrently")
+)
+
+// JobSortKeys are the columns a job listing can be sorted by.
+var JobSortKeys = []string{"created_at", "updated_at", "job_title"}
+
+// JobFilter selects jobs of an organization; empty fields are ignored.
+type JobFilter struct {
+    Title         string                 // title contains this, case insensitive
+    Text          string                 // full text over title and description, websearch syntax
+    Statuses      []string               // any of these statuses
+    SkillsAny     []string               // requires at least one of these skills
+    SkillsAll     []string               // requires all of these skills
+    CreatedAfter  *time.Time
+    CreatedBefore *time.Time
+    Attributes    map[string]interface{} // attributes contain these key/value pairs
+}
+
+// Cursor is the position of a job in a sorted listing: its sort value and id.
+type Cursor struct {
+    Value string
+    ID    int
+}
+
+// Page selects one page of a listing. Pages are keyed on the sort column plus id,
+// so they stay stable while jobs are added or removed.
+type Page struct {
+    SortBy string // one of JobSortKeys
+    Desc   bool
+    After  *Cursor // nil for the first page
+    Limit  int
+}
+
+// JobPage is one page of jobs with the total number of matches.
+type JobPage struct {
+    Jobs  []*models.Job
+    Next  *Cursor // position of the last job, nil on the last page
+    Total int
+}
+
+// JobRepository stores the jobs of organizations, identified by their job_id
+// within an organization.
+type JobRepository interface {
+    // Create adds a job, ErrDuplicate when its job_id is taken in the organization.
+    Create(ctx context.Context, orgID, userID int, job *models.Job) error
+    // Get returns a job, ErrNotFound when the organization has none with jobID.
+    Get(ctx context.Context, orgID int, jobID string) (*models.Job, error)
+    // Ref returns the internal id of a job that other tables refer to it by,
+    // ErrNotFound when the organization has none with jobID.
+    Ref(ctx context.Context, orgID int, jobID string) (int, error)
+    // Update replaces a job in one transaction, once check approved the stored
+    // version it was read as. ErrNotFound when there is none, check's error as is.
+    Update(ctx context.Context, orgID int, job *models.Job, check func(current *models.Job) error) error
+    // Delete removes a job, ErrNotFound when there is none.
+    Delete(ctx context.Context, orgID int, jobID string) error
+    // List returns one page of the jobs matching filter.
+    List(ctx context.Context, orgID int, filter JobFilter, page Page) (*JobPage, error)
+    // PublishDue activates scheduled jobs whose publish_at is not after now.
+    PublishDue(ctx context.Context, now time.Time) (int64, error)
+    // CloseExpired closes active and paused jobs whose expires_at is not after now.
+    CloseExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// UserRepository stores users. Returned users include their password hash.
+type UserRepository interface {
+    // Create adds a user along with a personal organization they own, named after
+    // them, and sets user.ID. ErrDuplicate when the email is taken.
+    Create(ctx context.Context, user *models.User) error
+    // GetByID returns a user, ErrNotFound when there is none.
+    GetByID(ctx context.Context, id int) (*models.User, error)
+    // GetByEmail returns a user, ErrNotFound when there is none.
+    GetByEmail(ctx context.Context, email string) (*models.User, error)
+}
+
+// SSOLogin is a single sign-on login waiting for the provider to redirect back.
+// Its state and browser binding are only kept as hashes.
+type SSOLogin struct {
+    StateHash    string
+    BindingHash  string
+    Nonce        string
+    CodeVerifier string
+    ExpiresAt    time.Time
+}
+
+// Identity is an account at an OpenID Connect provider, with the email the
+// provider confirmed and the username a new user would get.
+type Identity struct {
+    Issuer   string
+    Subject  string
+    Email    string
+    Username string
+}
+
+// SSORepository stores single sign-on logins in flight and the provider accounts
+// linked to users.
+type SSORepository interface {
+    // SaveLogin stores a started login, deleting the expired ones.
+    SaveLogin(ctx context.Context, login *SSOLogin) error
+    // ConsumeLogin marks the unused, unexpired login with both hashes used and
+    // returns it, ErrNotFound when there is none.
+    ConsumeLogin(ctx context.Context, stateHash, bindingHash string) (*SSOLogin, error)
+    // LinkedUser returns the user linked to a provider account, ErrNotFound when none is.
+    LinkedUser(ctx context.Context, issuer, subject string) (int, error)
+    // Link links a provider account to the user with its email, or to a new user
+    // with a personal organization. An existing user whose email was never verified
+    // loses their password, two-factor setup, sessions and API keys, which whoever
+    // registered the address may have set up. ErrDuplicate when the account or a
+    // user with its email was created concurrently.
+    Link(ctx context.Context, identity Identity) (int, error)
+}
+
+// MFAChallenge is a login waiting for its second factor.
+type MFAChallenge struct {
+    UserID int
+    Email  string
+}
+
+// SecondFactor is a user's authenticator setup.
+type SecondFactor struct {
+    Email     string
+    Secret    string     // empty until enrollment starts
+    EnabledAt *time.Time // nil until a code from Secret confirmed the enrollment
+}
+
+// MFARepository stores login challenges and the second factors of users. Codes
+// are checked by the caller; the repository makes sure each is accepted once.
+type MFARepository interface {
+    // CreateChallenge stores a login challenge.
+    CreateChallenge(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error
+    // Challenge returns the unused, unexpired challenge with fewer than
+    // maxAttempts codes tried, ErrNotFound when there is none.
+    Challenge(ctx context.Context, tokenHash string, maxAttempts int) (*MFAChallenge, error)
+    // AttemptChallenge counts a code tried against the challenge, ErrNotFound when
+    // it was used or ran out of attempts in the meantime.
+    AttemptChallenge(ctx context.Context, tokenHash string, maxAttempts int) error
+    // FinishChallenge marks the challenge used, ErrNotFound when it already is.
+    FinishChallenge(ctx context.Context, tokenHash string) error
+
+    // SecondFactor returns a user's setup, ErrNotFound when there is no user.
+    SecondFactor(ctx context.Context, userID int) (*SecondFactor, error)
+    // StartEnrollment gives a user a new secret, ErrConflict when two-factor
+    // authentication is enabled already.
+    StartEnrollment(ctx context.Context, userID int, secret string) error
+    // Enable enables two-factor authentication with secret, whose code of step was
+    // just used, and stores the recovery codes. ErrConflict when it is enabled
+    // already or the secret changed meanwhile.
+    Enable(ctx context.Context, userID int, secret string, step int64, recoveryHashes []string) error
+    // Disable removes a user's secret and recovery codes.
+    Disable(ctx context.Context, userID int) error
+    // UseTOTPStep records that a code of step was accepted, ErrConflict when a
+    // code of that step or a later one was, which makes this one a replay.
+    UseTOTPStep(ctx context.Context, userID int, step int64) error
+    // UseRecoveryCode marks an unused recovery code used, ErrNotFound when the
+    // user has none with the hash.
+    UseRecoveryCode(ctx context.Context, userID int, codeHash string) error
+    // ReplaceRecoveryCodes replaces all of a user's recovery codes.
+    ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+}
+
+// maxUsernameAttempts bounds the usernames tried for a user created by single sign-on.
+const maxUsernameAttempts = 10
+
+// usernameCandidate is the username to try for base on the given attempt: base
+// itself, then numbered, then with a random suffix.
+func usernameCandidate(base string, attempt int) (string, error) {
+    switch {
+    case attempt > 5:
+        random := make([]byte, 3)
+        if _, err := rand.Read(random); err != nil {
+            return "", err
+        }
+        return base + "-" + hex.EncodeToString(random), nil
+    case attempt > 1:
+        return fmt.Sprintf("%s-%d", base, attempt), nil
+    }
+    return base, nil
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+    "backend/internal/config"
+    "backend/internal/database"
+    "backend/internal/loginguard"
+    "backend/internal/mail"
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "log"
+    "net/url"
+    "strings"
+    "time"
+
+    "github.com/jmoiron/sqlx"
+    "golang.org/x/crypto/bcrypt"
+)
+
+// Purposes of the single use tokens mailed to users
+const (
+    TokenPurposePasswordReset     = "password_reset"
+    TokenPurposeEmailVerification = "email_verification"
+)
+
+const (
+    passwordResetTTL     = time.Hour
+    emailVerificationTTL = 48 * time.Hour
+
+    passwordResetsPerEmail   = 3 // reset mails an address can ask for within passwordResetWindow
+    passwordResetsPerIP      = 10
+    passwordResetWindow      = time.Hour
+    passwordResetSendTimeout = time.Minute
+)
+
+var (
+    ErrInvalidAccountToken  = errors.New("invalid or expired token")
+    ErrEmailNotVerified     = errors.New("email address is not verified")
+    ErrEmailAlreadyVerified = errors.New("email address is already verified")
+)
+
+type ForgotPasswordRequest struct {
+    Email     string `json:"email" binding:"required,email"`
+    IPAddress string `json:"-"`
+}
+
+type ResetPasswordRequest struct {
+    Token    string `json:"token" binding:"required"`
+    Password string `json:"password" binding:"required,min=6"`
+}
+
+type VerifyEmailRequest struct {
+    Token string `json:"token" binding:"required"`
+}
+
+// newAccountToken stores a token for purpose and returns it. Earlier unused
+// tokens for the same purpose stop working, so only the latest email counts.
+func newAccountToken(ctx context.Context, q sqlExecer, userID int, purpose string, ttl time.Duration) (string, error) {
+    token, hash, err := newOpaqueToken()
+    if err != nil {
+        return "", err
+    }
+
+    _, err = q.ExecContext(ctx,
+        "UPDATE user_tokens SET used_at = NOW() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL",
+        userID, purpose)
+    if err != nil {
+        return "", err
+    }
+
+    _, err = q.ExecContext(ctx,
+        "INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4)",
+        userID, purpose, hash, time.Now().Add(ttl))
+    if err != nil {
+        return "", err
+    }
+    return token, nil
+}
+
+// consumeAccountToken marks a token used and returns its user. The check and the
+// update are one statement, so a token cannot be used twice concurrently.
+func consumeAccountToken(ctx context.Context, q sqlQueryer, purpose, token string) (int, error) {
+    var userID int
+    err := q.QueryRowContext(ctx,
+        `UPDATE user_tokens SET used_at = NOW()
+         WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+         RETURNING user_id`, hashToken(token), purpose).Scan(&userID)
+    if err == sql.ErrNoRows {
+        return 0, ErrInvalidAccountToken
+    }
+    return userID, err
+}
+
+// accountLink builds a frontend link carrying token, e.g. /reset-password?token=...
+func accountLink(path, token string) string {
+    return strings.TrimRight(config.GetConfig().AppURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// passwordResetLimiter caps the reset mails an email address and a client address
+// can ask for, Init sets it up on the login guard's store.
+var passwordResetLimiter *loginguard.Limiter
+
+// RequestPasswordReset mails a reset link in the background and returns right
+// away, so neither the answer nor how long it takes tells whether the email has an
+// account. Failures to send are logged. The only errors are about the request
+// itself, a *loginguard.LockedError once the email or the address asked too often.
+func RequestPasswordReset(ctx context.Context, req *ForgotPasswordRequest) error {
+    if err := passwordResetLimiter.Allow(ctx, req.Email, req.IPAddress); err != nil {
+        return err
+    }
+    go sendPasswordReset(req.Email)
+    return nil
+}
+
+// sendPasswordReset mails a reset link if email has an account. It gets a context
+// of its own, the request's ends with the response.
+func sendPasswordReset(email string) {
+    ctx, cancel := context.WithTimeout(context.Background(), passwordResetSendTimeout)
+    defer cancel()
+
+    db := database.GetDB()
+    var userID int
+    err := db.GetContext(ctx, &userID, "SELECT id FROM users WHERE email = $1", email)
+    if err == sql.ErrNoRows {
+        return
+    }
+    if err != nil {
+        log.Printf("Password reset for %q: %v", email, err)
+        return
+    }
+
+    token, err := newAccountToken(ctx, db, userID, TokenPurposePasswordReset, passwordResetTTL)
+    if err != nil {
+        log.Printf("Password reset for user %d: %v", userID, err)
+        return
+    }
+
+    err = mail.Get().Send(ctx, mail.Message{
+        To:      email,
+        Subject: "Reset your password",
+        Body: fmt.Sprintf("Someone asked to reset the password of your account.\n\n"+
+            "Choose a new password here, the link works once and expires in %s:\n%s\n\n"+
+            "If it was not you, ignore this email.\n",
+            passwordResetTTL, accountLink("/reset-password", token)),
+    })
+    if err != nil {
+        log.Printf("Could not send password reset email to user %d: %v", userID, err)
+    }
+}
+
+// ResetPassword sets a new password with a reset token and ends every session,
+// in case the old password was known to someone else.
+func ResetPassword(ctx context.Context, req *ResetPasswordRequest) error {
+    db := database.GetDB()
+
+    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+    if err != nil {
+        return err
+    }
+
+    var email string
+    err = database.WithTx(ctx, db, func(tx *sqlx.Tx) error {
+        userID, err := consumeAccountToken(ctx, tx, TokenPurposePasswordReset, req.Token)
+        if err != nil {
+            return err
+        }
+
+        // The reset link was mailed to the address, which proves the user owns it
+        err = tx.GetContext(ctx, &email,
+            `UPDATE users SET password_hash = $1, email_verified_at = COALESCE(email_verified_at, NOW()),
+             updated_at = CURRENT_TIMESTAMP WHERE id = $2
+             RETURNING email`, string(hashedPassword), userID)
+        if err != nil {
+            return err
+        }
+
+        _, err = tx.ExecContext(ctx,
+            "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+        return err
+    })
+    if err != nil {
+        return err
+    }
+
+    // Owning the address is also what lifts a lockout, nobody else can clear it
+    return loginguard.Get().Unlock(ctx, email)
+}
+
+// sendVerificationEmail mails a link that confirms the user owns email.
+func sendVerificationEmail(ctx context.Context, userID int, email string) error {
+    token, err := newAccountToken(ctx, database.GetDB(), userID, TokenPurposeEmailVerification, emailVerificationTTL)
+    if err != nil {
+        return err
+    }
+
+    return mail.Get().Send(ctx, mail.Message{
+        To:      email,
+        Subject: "Verify your email address",
+        Body: fmt.Sprintf("Confirm your email address to start publishing jobs.\n\n"+
+            "The link expires in %s:\n%s\n",
+            emailVerificationTTL, accountLink("/verify-email", token)),
+    })
+}
+
+// ResendVerificationEmail mails the caller a new verification link.
+func ResendVerificationEmail(ctx context.Context) error {
+    db := database.GetDB()
+    userID, _ := ctx.Value("userID").(int)
+
+    var user struct {
+        Email      string       `db:"email"`
+        VerifiedAt sql.NullTime `db:"email_verified_at"`
+    }
+    if err := db.GetContext(ctx, &user, "SELECT email, email_verified_at FROM users WHERE id = $1", userID); err != nil {
+        return err
+    }
+    if user.VerifiedAt.Valid {
+        return ErrEmailAlreadyVerified
+    }
+
+    return sendVerificationEmail(ctx, userID, user.Email)
+}
+
+// VerifyEmail marks the address of the token's user as verified.
+func VerifyEmail(ctx context.Context, req *VerifyEmailRequest) error {
+    db := database.GetDB()
+
+    return database.WithTx(ctx, db, func(tx *sqlx.Tx) error {
+        userID, err := consumeAccountToken(ctx, tx, TokenPurposeEmailVerification, req.Token)
+        if err != nil {
+            return err
+        }
+
+        _, err = tx.ExecContext(ctx,
+            "UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()) WHERE id = $1", userID)
+        return err
+    })
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+    "backend/internal/database"
+    "backend/internal/models"
+    "context"
+    "crypto/rand"
+    "database/sql"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/lib/pq"
+)
+
+// APIKeyPrefix starts every key, so leaked keys are easy to recognise and scan for.
+const APIKeyPrefix = "hek_"
+
+var (
+    ErrInvalidAPIKey        = errors.New("invalid, expired or revoked api key")
+    ErrInvalidAPIKeyRequest = errors.New("invalid api key request")
+    ErrAPIKeyDoesNotExist   = errors.New("api key does not exist for this user")
+    ErrAPIKeyScope          = errors.New("api key does not have the required scope")
+    ErrAPIKeyNotAllowed     = errors.New("api keys cannot be used here, log in instead")
+)
+
+// IsPermission reports whether any role grants permission.
+func IsPermission(permission string) bool {
+    for _, permissions := range RolePermissions {
+        if containsString(permissions, permission) {
+            return true
+        }
+    }
+    return false
+}
+
+// ScopesAllow reports whether an API key with scopes may use permission.
+func ScopesAllow(scopes []string, permission string) bool {
+    return containsString(scopes, permission)
+}
+
+// newAPIKey returns a key of the form hek_<public id>_<secret> and its displayed prefix.
+func newAPIKey() (key string, prefix string, err error) {
+    publicID := make([]byte, 6)
+    if _, err := rand.Read(publicID); err != nil {
+        return "", "", err
+    }
+    secret, _, err := newOpaqueToken()
+    if err != nil {
+        return "", "", err
+    }
+
+    prefix = APIKeyPrefix + hex.EncodeToString(publicID)
+    return prefix + "_" + secret, prefix, nil
+}
+
+// CreateAPIKey creates a key for the caller. A key limited to an organization may
+// only have scopes the caller's role there grants; a key without one acts in any of
+// the caller's organizations, limited by both its scopes and the caller's role.
+func CreateAPIKey(ctx context.Context, req *models.APIKey) (*models.NewAPIKey, error) {
+    db := database.GetDB()
+    userID, _ := ctx.Value("userID").(int)
+
+    for _, scope := range req.Scopes {
+        if !IsPermission(scope) {
+            return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKeyRequest, scope)
+        }
+    }
+    if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+        return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKeyRequest)
+    }
+    if req.OrgID != nil {
+        role, err := MemberRole(ctx, userID, *req.OrgID)
+        if err != nil {
+            return nil, err
+        }
+        for _, scope := range req.Scopes {
+            if !HasPermission(role, scope) {
+                return nil, fmt.Errorf("%w: your role does not grant %q", ErrInsufficientRole, scope)
+            }
+        }
+    }
+
+    key, prefix, err := newAPIKey()
+    if err != nil {
+        return nil, err
+    }
+
+    created := models.NewAPIKey{APIKey: *req, Key: key}
+    created.UserID = userID
+    created.Prefix = prefix
+    err = db.QueryRowContext(ctx,
+        `INSERT INTO api_keys (user_id, org_id, name, prefix, key_hash, scopes, expires_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7)
+         RETURNING id, created_at`,
+        userID, req.OrgID, req.Name, prefix, hashToken(key), pq.Array(req.Scopes), req.ExpiresAt).
+        Scan(&created.ID, &created.CreatedAt)
+    if err != nil {
+        return nil, err
+    }
+
+    return &created, nil
+}
+
+// apiKeyColumns are selected by every api key query and read back by scanAPIKey.
+const apiKeyColumns = `id, user_id, org_id, name, prefix, scopes, created_at, expires_at, last_used_at`
+
+func scanAPIKey(row interface{ Scan(dest ...interface{}) error }) (*models.APIKey, error) {
+    var key models.APIKey
+    var orgID sql.NullInt64
+    err := row.Scan(&key.ID, &key.UserID, &orgID, &key.Name, &key.Prefix, pq.Array(&key.Scopes),
+        &key.CreatedAt, &key.ExpiresAt, &key.LastUsedAt)
+    if err != nil {
+        return nil, err
+    }
+    if orgID.Valid {
+        id := int(orgID.Int64)
+        key.OrgID = &id
+    }
+    return &key, nil
+}
+
+// ListAPIKeys returns the caller's keys that have not been revoked, newest first.
+func ListAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+    db := database.GetDB()
+    userID := ctx.Value("userID")
+
+    rows, err := db.QueryContext(ctx,
+        `SELECT `+apiKeyColumns+` FROM api_keys
+         WHERE user_id = $1 AND revoked_at IS NULL
+         ORDER BY created_at DESC, id DESC`, userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    keys := []*models.APIKey{}
+    for rows.Next() {
+        key, err := scanAPIKey(rows)
+        if err != nil {
+            return nil, err
+        }
+        keys = append(keys, key)
+    }
+    return keys, rows.Err()
+}
+
+// RevokeAPIKey stops one of the caller's keys from working.
+func RevokeAPIKey(ctx context.Context, keyID string) error {
+    db := database.GetDB()
+    userID := ctx.Value("userID")
+
+    result, err := db.ExecContext(ctx,
+        `UPDATE api_keys SET revoked_at = NOW()
+         WHERE id::text = $1 AND user_id = $2 AND revoked_at IS NULL`, keyID, userID)
+    if err != nil {
+        return err
+    }
+
+    affected, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if affected == 0 {
+        return ErrAPIKeyDoesNotExist
+    }
+    return nil
+}
+
+// AuthenticateAPIKey returns the usable key matching key and records that it was used.
+func AuthenticateAPIKey(ctx context.Context, key string) (*models.APIKey, error) {
+    db := database.GetDB()
+
+    if !strings.HasPrefix(key, APIKeyPrefix) {
+        return nil, ErrInvalidAPIKey
+    }
+
+    apiKey, err := scanAPIKey(db.QueryRowContext(ctx,
+        `UPDATE api_keys SET last_used_at = NOW()
+         WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+         RETURNING `+apiKeyColumns, hashToken(key)))
+    if err == sql.ErrNoRows {
+        return nil, ErrInvalidAPIKey
+    }
+    return apiKey, err
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+    "backend/internal/database"
+    "backend/internal/models"
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/jmoiron/sqlx"
+    "github.com/lib/pq"
+)
+
+var (
+    ErrJobNotAcceptingApplications = errors.New("job is not accepting applications")
+    ErrInvalidApplication          = errors.New("invalid application")
+    ErrInvalidApplicationFilter    = errors.New("invalid application filter")
+    ErrApplicationDoesNotExist     = errors.New("application does not exist for this job")
+)
+
+// ApplicationFilter narrows a job's applications by answer values, keyed by question id.
+type ApplicationFilter struct {
+    Min      map[string]float64  // first number in the answer is >= value, e.g. Q_Experience "5 years"
+    Max      map[string]float64  // first number in the answer is <= value
+    Equals   map[string]string   // answer is exactly value, e.g. a radio choice
+    Contains map[string][]string // checkbox answer contains every value, e.g. Q_Skills
+    Match    map[string]string   // case-insensitive substring match on a text answer, e.g. Q_Education
+}
+
+// getPublicJobRef resolves a job for candidates, who are not logged in, by its
+// share token. job_ids stay internal, so revoking or rotating the link cuts access.
+func getPublicJobRef(ctx context.Context, shareToken string) (int, string, error) {
+    db := database.GetDB()
+
+    var job struct {
+        ID        int    `db:"id"`
+        JobStatus string `db:"job_status"`
+    }
+    err := db.GetContext(ctx, &job, "SELECT id, job_status FROM jobs WHERE share_token = $1", shareToken)
+    if err == sql.ErrNoRows {
+        return 0, "", ErrJobDoesNotExist
+    }
+    if err != nil {
+        return 0, "", err
+    }
+    return job.ID, job.JobStatus, nil
+}
+
+// validateResponses checks a candidate's answers against the job's questionnaire and
+// returns the keys given as file answers, each once. They must be keys of files
+// uploaded for this job, i.e. start with filePrefix; claimUploads checks they were.
+func validateResponses(questions []models.Question, responses map[string]interface{}, filePrefix string) ([]string, error) {
+    var fileKeys []string
+    byID := make(map[string]models.Question, len(questions))
+    for _, q := range questions {
+        byID[q.ID] = q
+    }
+
+    for id := range responses {
+        if _, ok := byID[id]; !ok {
+            return nil, fmt.Errorf("%w: unknown question %q", ErrInvalidApplication, id)
+        }
+    }
+
+    for _, q := range questions {
+        answer, ok := responses[q.ID]
+        if !ok || answer == nil {
+            if q.Required {
+                return nil, fmt.Errorf("%w: question %q is required", ErrInvalidApplication, q.ID)
+            }
+            continue
+        }
+
+        switch q.Type {
+        case models.QuestionTypeRadio:
+            choice, ok := answer.(string)
+            if !ok || !containsString(q.Options, choice) {
+                return nil, fmt.Errorf("%w: question %q expects one of %v", ErrInvalidApplication, q.ID, q.Options)
+            }
+        case models.QuestionTypeCheckbox:
+            choices, ok := answer.([]interface{})
+            if !ok {
+                return nil, fmt.Errorf("%w: question %q expects a list of options", ErrInvalidApplication, q.ID)
+            }
+            if q.Required && len(choices) == 0 {
+                return nil, fmt.Errorf("%w: question %q is required", ErrInvalidApplication, q.ID)
+            }
+            for _, c := range choices {
+                choice, ok := c.(string)
+                if !ok || !containsString(q.Options, choice) {
+                    return nil, fmt.Errorf("%w: question %q expects options from %v", ErrInvalidApplication, q.ID, q.Options)
+                }
+            }
+        case models.QuestionTypeText, models.QuestionTypeFile:
+            text, ok := answer.(string)
+            if !ok {
+                return nil, fmt.Errorf("%w: question %q expects a string", ErrInvalidApplication, q.ID)
+            }
+            if q.Required && text == "" {
+                return nil, fmt.Errorf("%w: question %q is required", ErrInvalidApplication, q.ID)
+            }
+            if q.Type == models.QuestionTypeFile && text != "" {
+                if !strings.HasPrefix(text, filePrefix) {
+                    return nil, fmt.Errorf("%w: question %q expects an uploaded file", ErrInvalidApplication, q.ID)
+                }
+                if !containsString(fileKeys, text) {
+                    fileKeys = append(fileKeys, text)
+                }
+            }
+        }
+    }
+    return fileKeys, nil
+}
+
+func containsString(values []string, s string) bool {
+    for _, v := range values {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
+
+// SubmitApplication stores a candidate's application for an active job.
+func SubmitApplication(ctx context.Context, shareToken string, req *models.Application) error {
+    db := database.GetDB()
+
+    jobRef, status, err := getPublicJobRef(ctx, shareToken)
+    if err != nil {
+        return err
+    }
+    if status != models.JobStatusActive {
+        return ErrJobNotAcceptingApplications
+    }
+
+    var questions []models.Question
+    questionnaire, err := getQuestionnaireByJobRef(ctx, jobRef)
+    if err == nil {
+        questions = questionnaire.Questions
+    } else if err != ErrQuestionnaireDoesNotExist {
+        return err
+    }
+
+    if req.Responses == nil {
+        req.Responses = map[string]interface{}{}
+    }
+    fileKeys, err := validateResponses(questions, req.Responses, uploadPrefix(jobRef))
+    if err != nil {
+        return err
+    }
+
+    responsesJSON, err := json.Marshal(req.Responses)
+    if err != nil {
+        return err
+    }
+
+    // Sharing the job lock of SweepUploads keeps the uploaded files this application
+    // refers to from being deleted before it is stored
+    return database.WithTx(ctx, db, func(tx *sqlx.Tx) error {
+        if _, err := tx.ExecContext(ctx, "SELECT id FROM jobs WHERE id = $1 FOR SHARE", jobRef); err != nil {
+            return err
+        }
+
+        query := `INSERT INTO applications (job_ref, candidate_name, candidate_email, responses)
+            VALUES ($1, $2, $3, $4)
+            RETURNING id, stage, created_at, updated_at`
+        // job_id is not echoed, candidates only know the share token
+        err := tx.QueryRowContext(ctx, query, jobRef, req.CandidateName, req.CandidateEmail, responsesJSON).Scan(
+            &req.ID,
+            &req.Stage,
+            &req.CreatedAt,
+            &req.UpdatedAt)
+        if err != nil {
+            return err
+        }
+        return claimUploads(ctx, tx, jobRef, req.ID, fileKeys)
+    })
+}
+
+// claimUploads ties the uploaded files an application refers to to it, failing with
+// ErrInvalidApplication unless every key is a file uploaded for the job that no other
+// application claimed. Candidates are not logged in, so the unguessable key only the
+// uploader was given, used by one application, is what ties a file to its uploader.
+func claimUploads(ctx context.Context, tx *sqlx.Tx, jobRef, applicationID int, keys []string) error {
+    if len(keys) == 0 {
+        return nil
+    }
+
+    result, err := tx.ExecContext(ctx,
+        `UPDATE uploads SET application_id = $1
+         WHERE job_ref = $2 AND storage_key = ANY($3) AND application_id IS NULL`,
+        applicationID, jobRef, pq.Array(keys))
+    if err != nil {
+        return err
+    }
+    claimed, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if claimed != int64(len(keys)) {
+        return fmt.Errorf("%w: a file answer is not a file uploaded for this job", ErrInvalidApplication)
+    }
+    return nil
+}
+
+// sortedKeys returns map keys in a stable order so generated SQL is deterministic.
+func sortedKeys[V any](m map[string]V) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// buildApplicationFilter turns a filter into SQL conditions over applications.responses,
+// numbering placeholders from len(args)+1.
+func buildApplicationFilter(filter ApplicationFilter, args []interface{}) ([]string, []interface{}) {
+    var conds []string
+    arg := func(v interface{}) string {
+        args = append(args, v)
+        return fmt.Sprintf("$%d", len(args))
+    }
+
+    // Answers like "5 years" are compared by their first number
+    number := func(key string) string {
+        return fmt.Sprintf(`substring(responses->>%s::text from '[0-9]+(?:\.[0-9]+)?')::numeric`, arg(key))
+    }
+
+    for _, key := range sortedKeys(filter.Min) {
+        conds = append(conds, fmt.Sprintf("%s >= %s", number(key), arg(filter.Min[key])))
+    }
+    for _, key := range sortedKeys(filter.Max) {
+        conds = append(conds, fmt.Sprintf("%s <= %s", number(key), arg(filter.Max[key])))
+    }
+    // Containment predicates can use the GIN index on responses
+    for _, key := range sortedKeys(filter.Equals) {
+        conds = append(conds, fmt.Sprintf("responses @> jsonb_build_object(%s::text, %s::text)",
+            arg(key), arg(filter.Equals[key])))
+    }
+    for _, key := range sortedKeys(filter.Contains) {
+        for _, value := range filter.Contains[key] {
+            conds = append(conds, fmt.Sprintf("responses @> jsonb_build_object(%s::text, jsonb_build_array(%s::text))",
+                arg(key), arg(value)))
+        }
+    }
+    for _, key := range sortedKeys(filter.Match) {
+        conds = append(conds, fmt.Sprintf(`responses->>%s::text ILIKE %s ESCAPE '\'`, arg(key), arg(database.ContainsPattern(filter.Match[key]))))
+    }
+
+    return conds, args
+}
+
+// ListApplications returns the applications of a job owned by the caller, newest first.
+func ListApplications(ctx context.Context, jobID string, filter ApplicationFilter) ([]*models.Application, error) {
+    db := database.GetDB()
+
+    jobRef, err := getJobRef(ctx, jobID)
+    if err != nil {
+        return nil, err
+    }
+
+    conds, args := buildApplicationFilter(filter, []interface{}{jobRef})
+    query := `SELECT id, candidate_name, candidate_email, responses, stage, created_at, updated_at
+             FROM applications WHERE job_ref = $1`
+    if len(conds) > 0 {
+        query += " AND " + strings.Join(conds, " AND ")
+    }
+    query += " ORDER BY created_at DESC, id DESC"
+
+    rows, err := db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    applications := []*models.Application{}
+    for rows.Next() {
+        var application models.Application
+        var responsesJSON []byte
+
+        err := rows.Scan(
+            &application.ID,
+            &application.CandidateName,
+            &application.CandidateEmail,
+            &responsesJSON,
+            &application.Stage,
+            &application.CreatedAt,
+            &application.UpdatedAt,
+        )
+        if err != nil {
+            return nil, err
+        }
+
+        if err := json.Unmarshal(responsesJSON, &application.Responses); err != nil {
+            return nil, err
+        }
+        application.JobID = jobID
+
+        applications = append(applications, &application)
+    }
+
+    return applications, rows.Err()
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+    "backend/internal/models"
+    "errors"
+    "reflect"
+    "testing"
+)
+
+func TestValidateResponsesReturnsFileKeys(t *testing.T) {
+    questions := []models.Question{
+        {ID: "Q_Resume", Type: models.QuestionTypeFile, Required: true},
+        {ID: "Q_Cover", Type: models.QuestionTypeFile},
+        {ID: "Q_Portfolio", Type: models.QuestionTypeFile},
+        {ID: "Q_Name", Type: models.QuestionTypeText},
+    }
+    prefix := uploadPrefix(7)
+
+    keys, err := validateResponses(questions, map[string]interface{}{
+        "Q_Resume":    prefix + "a/cv.pdf",
+        "Q_Cover":     prefix + "a/cv.pdf",
+        "Q_Portfolio": "",
+        "Q_Name":      "applications/7/not-a-file",
+    }, prefix)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // Each file once, text answers that look like keys are not files
+    if want := []string{prefix + "a/cv.pdf"}; !reflect.DeepEqual(keys, want) {
+        t.Fatalf("file keys %v, want %v", keys, want)
+    }
+
+    for name, responses := range map[string]map[string]interface{}{
+        "file of another job": {"Q_Resume": uploadPrefix(8) + "a/cv.pdf"},
+        "missing file":        {"Q_Resume": ""},
+        "not a string":        {"Q_Resume": 42.0},
+        "unknown question":    {"Q_Resume": prefix + "a/cv.pdf", "Q_Other": "x"},
+    } {
+        if _, err := validateResponses(questions, responses, prefix); !errors.Is(err, ErrInvalidApplication) {
+            t.Fatalf("%s: got %v, want ErrInvalidApplication", name, err)
+        }
+    }
+}
+
+func TestBuildApplicationFilterEscapesMatch(t *testing.T) {
+    conds, args := buildApplicationFilter(ApplicationFilter{
+        Match: map[string]string{"Q_Education": `100% C_S\`},
+    }, []interface{}{7})
+
+    want := []string{`responses->>$2::text ILIKE $3 ESCAPE '\'`}
+    if !reflect.DeepEqual(conds, want) {
+        t.Fatalf("conditions %q, want %q", conds, want)
+    }
+    // Wildcards typed in the filter match themselves
+    if wantArgs := []interface{}{7, "Q_Education", `%100\% C\_S\\%`}; !reflect.DeepEqual(args, wantArgs) {
+        t.Fatalf("arguments %q, want %q", args, wantArgs)
+    }
+}
@@ -3,12 +3,13 @@ package services
 import (
     "context"
     "errors"
-    "time"
-    "github.com/golang-jwt/jwt/v4"
+    "log"
     "golang.org/x/crypto/bcrypt"
-    "backend/internal/database"
+    "backend/internal/authtoken"
     "backend/internal/config"
+    "backend/internal/loginguard"
     "backend/internal/models"
+    "backend/internal/repository"
 )
 
 var (
@@ -20,56 +21,93 @@ type RegisterRequest struct {
     Username string `json:"username" binding:"required"`
     Email    string `json:"email" binding:"required,email"`
     Password string `json:"password" binding:"required,min=6"`
+    Meta     SessionMeta `json:"-"`
 }
 
 type LoginRequest struct {
     Email    string `json:"email" binding:"required,email"`
     Password string `json:"password" binding:"required"`
+    Meta     SessionMeta `json:"-"`
 }
 
 type AuthResponse struct {
-    Token string `json:"token"`
+    TokenPair
     User  models.User `json:"user"`
 }
 
-func Register(ctx context.Context, req *RegisterRequest) (*AuthResponse, error) {
-    db := database.GetDB()
+// LoginSessions hands out what a successful login gets: a session with its token
+// pair, or a challenge for the second factor.
+type LoginSessions interface {
+    Start(ctx context.Context, userID int, meta SessionMeta) (*TokenPair, error)
+    Challenge(ctx context.Context, userID int) (*MFAChallenge, error)
+}
 
-    // Check if email exists
-    var exists bool
-    err := db.GetContext(ctx, &exists, 
-        "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", req.Email)
-    if err != nil {
-        return nil, err
-    }
-    if exists {
-        return nil, ErrEmailExists
-    }
+// EmailVerifier mails a new user the link that confirms they own their address.
+type EmailVerifier interface {
+    SendVerification(ctx context.Context, userID int, email string) error
+}
+
+// postgresSessions keeps sessions and challenges in the database.
+type postgresSessions struct{}
+
+func (postgresSessions) Start(ctx context.Context, userID int, meta SessionMeta) (*TokenPair, error) {
+    return startSession(ctx, userID, meta)
+}
 
+func (postgresSessions) Challenge(ctx context.Context, userID int) (*MFAChallenge, error) {
+    return mfaService.Challenge(ctx, userID)
+}
+
+// mailVerifier stores a verification token and mails it with the configured mailer.
+type mailVerifier struct{}
+
+func (mailVerifier) SendVerification(ctx context.Context, userID int, email string) error {
+    return sendVerificationEmail(ctx, userID, email)
+}
+
+// AuthService registers users and logs them in.
+type AuthService struct {
+    users    repository.UserRepository
+    sessions LoginSessions
+    verifier EmailVerifier
+    guard    *loginguard.Guard
+}
+
+func NewAuthService(users repository.UserRepository, sessions LoginSessions, verifier EmailVerifier, guard *loginguard.Guard) *AuthService {
+    return &AuthService{users: users, sessions: sessions, verifier: verifier, guard: guard}
+}
+
+func (s *AuthService) Register(ctx context.Context, req *RegisterRequest) (*AuthResponse, error) {
     // Hash password
     hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
     if err != nil {
         return nil, err
     }
 
-    // Create user
-    var userId int
-    err = db.GetContext(ctx, &userId,
-        `INSERT INTO users (email, password_hash, username) 
-         VALUES ($1, $2, $3) 
-         RETURNING id`, req.Email, string(hashedPassword), req.Username)
+    // Every user starts in an organization of their own, colleagues can be added to it later
+    user := models.User{Username: req.Username, Email: req.Email, PasswordHash: string(hashedPassword)}
+    err = s.users.Create(ctx, &user)
+    if errors.Is(err, repository.ErrDuplicate) {
+        return nil, ErrEmailExists
+    }
     if err != nil {
         return nil, err
     }
 
-    // Generate token
-    token, err := generateToken(userId)
+    // The account works right away, publishing jobs waits for verification.
+    // A failed email is not fatal, the user can ask for another one
+    if err := s.verifier.SendVerification(ctx, user.ID, req.Email); err != nil {
+        log.Printf("Sending verification email to user %d: %v", user.ID, err)
+    }
+
+    // Start a session with its token pair
+    tokens, err := s.sessions.Start(ctx, user.ID, req.Meta)
     if err != nil {
         return nil, err
     }
 
     return &AuthResponse{
-        Token: token,
+        TokenPair: *tokens,
         User: models.User {
             Username: req.Username,
             Email: req.Email,
@@ -77,48 +115,88 @@ func Register(ctx context.Context, req *RegisterRequest) (*AuthResponse, error)
     }, nil
 }
 
-func Login(ctx context.Context, req *LoginRequest) (*AuthResponse, error) {
-
-    var user models.User
-
-    db := database.GetDB()
+// Login checks the password. With two-factor authentication enabled no tokens are
+// issued yet, a challenge is returned for CompleteMFALogin instead. Repeated
+// failures for an account or from an address make it wait, see loginguard.
+func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*AuthResponse, *MFAChallenge, error) {
+    guard := s.guard
+    failure := loginguard.Failure{Email: req.Email, IPAddress: req.Meta.IPAddress, UserAgent: req.Meta.UserAgent}
+
+    if err := guard.Check(ctx, req.Email, req.Meta.IPAddress); err != nil {
+        if errors.Is(err, loginguard.ErrLocked) {
+            failure.Reason = loginguard.ReasonLocked
+            if err := guard.Fail(ctx, failure); err != nil {
+                return nil, nil, err
+            }
+        }
+        return nil, nil, err
+    }
 
-    err := db.GetContext(ctx, &user,
-        `SELECT id, email, password_hash, username 
-         FROM users 
-         WHERE email = $1`, req.Email)
+    user, err := s.users.GetByEmail(ctx, req.Email)
+    if errors.Is(err, repository.ErrNotFound) {
+        failure.Reason = loginguard.ReasonUnknownEmail
+        if err := guard.Fail(ctx, failure); err != nil {
+            return nil, nil, err
+        }
+        return nil, nil, ErrInvalidCredentials
+    }
     if err != nil {
-        return nil, ErrInvalidCredentials
+        return nil, nil, err
     }
 
     // Verify password
     err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
     if err != nil {
-        return nil, ErrInvalidCredentials
+        failure.Reason = loginguard.ReasonWrongPassword
+        if err := guard.Fail(ctx, failure); err != nil {
+            return nil, nil, err
+        }
+        return nil, nil, ErrInvalidCredentials
     }
 
-    // Generate token
-    token, err := generateToken(user.ID)
+    // The failures are only cleared once the second factor is through as well,
+    // otherwise knowing the password would allow guessing codes without backoff
+    if user.TOTPEnabledAt != nil {
+        challenge, err := s.sessions.Challenge(ctx, user.ID)
+        return nil, challenge, err
+    }
+    if err := guard.Succeed(ctx, req.Email); err != nil {
+        return nil, nil, err
+    }
+
+    // Start a session with its token pair
+    tokens, err := s.sessions.Start(ctx, user.ID, req.Meta)
     if err != nil {
-        return nil, err
+        return nil, nil, err
     }
 
     return &AuthResponse{
-        Token: token,
+        TokenPair: *tokens,
         User: models.User {
             Username: user.Username,
             Email: req.Email,
+            EmailVerifiedAt: user.EmailVerifiedAt,
         },
-    }, nil
+    }, nil, nil
 }
 
-func generateToken(userID int) (string, error) {
-    
-    claims := jwt.MapClaims{
-        "user_id": userID,
-        "exp":     time.Now().Add(24 * time.Hour).Unix(),
-    }
+var authService *AuthService
+
+// SetAuthService replaces the service behind Register and Login, e.g. with one
+// on memory repositories in tests. Init sets the Postgres one.
+func SetAuthService(s *AuthService) {
+    authService = s
+}
+
+func Register(ctx context.Context, req *RegisterRequest) (*AuthResponse, error) {
+    return authService.Register(ctx, req)
+}
 
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    return token.SignedString([]byte(config.GetConfig().JWTSecret))
-}
\ No newline at end of file
+func Login(ctx context.Context, req *LoginRequest) (*AuthResponse, *MFAChallenge, error) {
+    return authService.Login(ctx, req)
+}
+
+// generateToken signs a short lived access token bound to a session
+func generateToken(userID int, sessionID string) (string, error) {
+    return authtoken.Get().Sign(userID, sessionID, config.GetConfig().Auth.AccessTokenTTL)
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+    "backend/internal/loginguard"
+    "backend/internal/models"
+    "backend/internal/repository"
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "golang.org/x/crypto/bcrypt"
+)
+
+// fakeSessions hands out numbered tokens instead of storing sessions.
+type fakeSessions struct {
+    started    []int
+    challenged []int
+}
+
+func (f *fakeSessions) Start(ctx context.Context, userID int, meta SessionMeta) (*TokenPair, error) {
+    f.started = append(f.started, userID)
+    return &TokenPair{Token: "token", RefreshToken: "refresh", ExpiresIn: 900}, nil
+}
+
+func (f *fakeSessions) Challenge(ctx context.Context, userID int) (*MFAChallenge, error) {
+    f.challenged = append(f.challenged, userID)
+    return &MFAChallenge{ChallengeToken: "challenge", ExpiresIn: 300}, nil
+}
+
+// fakeVerifier records who a verification link would have been mailed to.
+type fakeVerifier struct {
+    sent []string
+}
+
+func (f *fakeVerifier) SendVerification(ctx context.Context, userID int, email string) error {
+    f.sent = append(f.sent, email)
+    return nil
+}
+
+type testAuth struct {
+    service  *AuthService
+    users    *repository.MemoryUsers
+    sessions *fakeSessions
+    verifier *fakeVerifier
+}
+
+// newTestAuthService returns an auth service on memory repositories whose guard
+// backs off after 3 failures of an account.
+func newTestAuthService() *testAuth {
+    a := &testAuth{users: repository.NewMemoryUsers(), sessions: &fakeSessions{}, verifier: &fakeVerifier{}}
+    guard := loginguard.New(loginguard.NewMemory(),
+        loginguard.Policy{Threshold: 3, BaseDelay: time.Minute, MaxDelay: time.Hour},
+        loginguard.Policy{Threshold: 100, BaseDelay: time.Minute, MaxDelay: time.Hour},
+        time.Hour)
+    a.service = NewAuthService(a.users, a.sessions, a.verifier, guard)
+    return a
+}
+
+func (a *testAuth) login(email, password string) (*AuthResponse, *MFAChallenge, error) {
+    return a.service.Login(context.Background(), &LoginRequest{
+        Email: email, Password: password, Meta: SessionMeta{IPAddress: "192.0.2.1"},
+    })
+}
+
+func TestRegister(t *testing.T) {
+    a := newTestAuthService()
+    req := &RegisterRequest{Username: "ada", Email: "ada@example.com", Password: "secret123"}
+
+    resp, err := a.service.Register(context.Background(), req)
+    if err != nil {
+        t.Fatalf("Register: %v", err)
+    }
+    if resp.Token == "" || resp.User.Email != "ada@example.com" {
+        t.Fatalf("response: %+v", resp)
+    }
+    if len(a.verifier.sent) != 1 || len(a.sessions.started) != 1 {
+        t.Fatalf("verification mails %v, sessions %v", a.verifier.sent, a.sessions.started)
+    }
+
+    stored, err := a.users.GetByEmail(context.Background(), "ada@example.com")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if bcrypt.CompareHashAndPassword([]byte(stored.PasswordHash), []byte("secret123")) != nil {
+        t.Fatal("password is not stored as its bcrypt hash")
+    }
+    if orgs := a.users.Organizations(stored.ID); len(orgs) != 1 || orgs[0].Name != "ada" || orgs[0].Role != models.RoleOwner {
+        t.Fatalf("personal organization: %+v", orgs)
+    }
+
+    if _, err := a.service.Register(context.Background(), req); !errors.Is(err, ErrEmailExists) {
+        t.Fatalf("register twice: got %v, want ErrEmailExists", err)
+    }
+}
+
+func TestLogin(t *testing.T) {
+    a := newTestAuthService()
+    req := &RegisterRequest{Username: "ada", Email: "ada@example.com", Password: "secret123"}
+    if _, err := a.service.Register(context.Background(), req); err != nil {
+        t.Fatal(err)
+    }
+
+    resp, challenge, err := a.login("ada@example.com", "secret123")
+    if err != nil || challenge != nil || resp.Token == "" {
+        t.Fatalf("login: %+v, %+v, %v", resp, challenge, err)
+    }
+    if _, _, err := a.login("ada@example.com", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+        t.Fatalf("wrong password: got %v, want ErrInvalidCredentials", err)
+    }
+    if _, _, err := a.login("nobody@example.com", "secret123"); !errors.Is(err, ErrInvalidCredentials) {
+        t.Fatalf("unknown email: got %v, want ErrInvalidCredentials", err)
+    }
+}
+
+func TestLoginLocksOutAfterFailures(t *testing.T) {
+    a := newTestAuthService()
+    req := &RegisterRequest{Username: "ada", Email: "ada@example.com", Password: "secret123"}
+    if _, err := a.service.Register(context.Background(), req); err != nil {
+        t.Fatal(err)
+    }
+
+    for i := 0; i < 3; i++ {
+        if _, _, err := a.login("ada@example.com", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+            t.Fatalf("failure %d: %v", i+1, err)
+        }
+    }
+
+    // Even the right password has to wait now
+    _, _, err := a.login("ada@example.com", "secret123")
+    var locked *loginguard.LockedError
+    if !errors.As(err, &locked) || locked.RetryAfter <= 0 {
+        t.Fatalf("after 3 failures: got %v, want a LockedError", err)
+    }
+}
+
+func TestLoginWithTOTPReturnsChallenge(t *testing.T) {
+    a := newTestAuthService()
+    hash, _ := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+    enabledAt := time.Now()
+    a.users.Put(&models.User{ID: 7, Email: "ada@example.com", PasswordHash: string(hash), TOTPEnabledAt: &enabledAt})
+
+    for i := 0; i < 2; i++ {
+        a.login("ada@example.com", "wrong")
+    }
+    resp, challenge, err := a.login("ada@example.com", "secret123")
+    if err != nil || resp != nil || challenge == nil {
+        t.Fatalf("login: %+v, %+v, %v", resp, challenge, err)
+    }
+    if len(a.sessions.started) != 0 || len(a.sessions.challenged) != 1 {
+        t.Fatalf("sessions %v, challenges %v", a.sessions.started, a.sessions.challenged)
+    }
+
+    // The password alone does not clear the failures, the second factor has to pass too
+    a.login("ada@example.com", "wrong")
+    _, _, err = a.login("ada@example.com", "secret123")
+    if !errors.Is(err, loginguard.ErrLocked) {
+        t.Fatalf("failures were cleared before the second factor: %v", err)
+    }
+}
@@ -1,278 +1,301 @@
 package services
 
 import (
-    "backend/internal/database"
     "backend/internal/models"
+    "backend/internal/repository"
     "context"
-    "encoding/json"
     "errors"
-    "github.com/lib/pq"
+    "fmt"
+    "log"
+    "time"
 )
 
 var (
-    ErrJobExists = errors.New("job already exists for this user")
-    ErrJobDoesNotExist = errors.New("job does not exist for this user")
+    ErrJobExists = errors.New("job already exists in this organization")
+    ErrJobDoesNotExist = errors.New("job does not exist in this organization")
+    ErrInvalidJobStatus = errors.New("invalid job status")
+    ErrIllegalJobStatusTransition = errors.New("illegal job status transition")
+    ErrInvalidJobSchedule = errors.New("invalid job schedule")
 )
 
-func CreateJob(ctx context.Context, req *models.Job) error {
+// jobStatusTransitions lists, per status, the statuses a job may move to.
+// scheduled -> active and active/paused -> closed also happen automatically,
+// see ProcessJobSchedules.
+var jobStatusTransitions = map[string][]string{
+    models.JobStatusDraft:     {models.JobStatusScheduled, models.JobStatusActive, models.JobStatusArchived},
+    models.JobStatusScheduled: {models.JobStatusDraft, models.JobStatusActive, models.JobStatusArchived},
+    models.JobStatusActive:    {models.JobStatusPaused, models.JobStatusClosed},
+    models.JobStatusPaused:    {models.JobStatusActive, models.JobStatusClosed},
+    models.JobStatusClosed:    {models.JobStatusActive, models.JobStatusArchived},
+    models.JobStatusArchived:  {},
+}
 
-    db := database.GetDB()
-    userID := ctx.Value("userID")
+// validateJobStatus rejects statuses outside of the job lifecycle.
+func validateJobStatus(status string) error {
+    if _, ok := jobStatusTransitions[status]; !ok {
+        return fmt.Errorf("%w: %q", ErrInvalidJobStatus, status)
+    }
+    return nil
+}
 
-    // Check if job already exists for this user
-    var count int
-    err := db.GetContext(ctx, &count, "SELECT COUNT(*) FROM jobs WHERE job_id = $1 AND user_id = $2", req.JobID, userID)
-    if err != nil {
-        return err
+// validateJobSchedule checks publish_at and expires_at against the requested status.
+func validateJobSchedule(req *models.Job, now time.Time) error {
+    if req.JobStatus == models.JobStatusScheduled && (req.PublishAt == nil || !req.PublishAt.After(now)) {
+        return fmt.Errorf("%w: a scheduled job needs a publish_at in the future", ErrInvalidJobSchedule)
     }
-    if count > 0 {
-        return ErrJobExists
+    if req.ExpiresAt != nil {
+        if req.PublishAt != nil && !req.ExpiresAt.After(*req.PublishAt) {
+            return fmt.Errorf("%w: expires_at must be after publish_at", ErrInvalidJobSchedule)
+        }
+        if req.JobStatus == models.JobStatusActive && !req.ExpiresAt.After(now) {
+            return fmt.Errorf("%w: expires_at must be in the future", ErrInvalidJobSchedule)
+        }
     }
+    return nil
+}
 
-    // Insert new job
+// isPublishing reports whether moving a job to status makes it visible to candidates,
+// now or on its publish date.
+func isPublishing(status string) bool {
+    return status == models.JobStatusActive || status == models.JobStatusScheduled
+}
+
+// JobService manages the jobs of organizations. The organization and user a call
+// acts for come from the context, as set by the middleware.
+type JobService struct {
+    jobs  repository.JobRepository
+    users repository.UserRepository
+    now   func() time.Time
+}
 
-    // Convert map to JSON for attributes
-    attributesJSON, err := json.Marshal(req.Attributes)
+func NewJobService(jobs repository.JobRepository, users repository.UserRepository) *JobService {
+    return &JobService{jobs: jobs, users: users, now: time.Now}
+}
+
+// requireVerifiedEmail stops the caller from publishing jobs until their email is verified.
+func (s *JobService) requireVerifiedEmail(ctx context.Context) error {
+    userID, _ := ctx.Value("userID").(int)
+
+    user, err := s.users.GetByID(ctx, userID)
     if err != nil {
         return err
     }
+    if user.EmailVerifiedAt == nil {
+        return fmt.Errorf("%w: verify it before publishing jobs", ErrEmailNotVerified)
+    }
+    return nil
+}
+
+func (s *JobService) CreateJob(ctx context.Context, req *models.Job) error {
+    orgID, _ := ctx.Value("orgID").(int)
+    userID, _ := ctx.Value("userID").(int)
 
-    query := `INSERT INTO jobs (
-        job_id, 
-        user_id, 
-        job_title, 
-        job_description, 
-        job_status, 
-        skills_required, 
-        attributes
-    ) VALUES ($1, $2, $3, $4, $5, $6, $7)`
-    _, err = db.ExecContext(ctx, query, 
-        req.JobID, 
-        userID, 
-        req.JobTitle, 
-        req.JobDescription, 
-        req.JobStatus, 
-        pq.Array(req.SkillsRequired), 
-        attributesJSON)
+    // New jobs start at the beginning of the lifecycle
+    switch req.JobStatus {
+    case models.JobStatusDraft, models.JobStatusScheduled, models.JobStatusActive:
+    default:
+        return fmt.Errorf("%w: cannot create a job as %q", ErrIllegalJobStatusTransition, req.JobStatus)
+    }
+    if err := validateJobSchedule(req, s.now()); err != nil {
+        return err
+    }
+    if isPublishing(req.JobStatus) {
+        if err := s.requireVerifiedEmail(ctx); err != nil {
+            return err
+        }
+    }
 
+    err := s.jobs.Create(ctx, orgID, userID, req)
+    if errors.Is(err, repository.ErrDuplicate) {
+        return ErrJobExists
+    }
     return err
 }
 
-func UpdateJob(ctx context.Context, req *models.Job) error {
-    db := database.GetDB()
-    userID := ctx.Value("userID")
+func (s *JobService) UpdateJob(ctx context.Context, req *models.Job) error {
+    orgID, _ := ctx.Value("orgID").(int)
 
-    // Check if job exists for this user
-    var count int
-    err := db.GetContext(ctx, &count, "SELECT COUNT(*) FROM jobs WHERE job_id = $1 AND user_id = $2", req.JobID, userID)
-    if err != nil {
+    if err := validateJobSchedule(req, s.now()); err != nil {
         return err
     }
-    if count == 0 {
+
+    // Checked against the job as locked for the update, so the scheduler cannot
+    // publish or close it in between
+    err := s.jobs.Update(ctx, orgID, req, func(current *models.Job) error {
+        // Enforce the job lifecycle, keeping the status unchanged is always allowed
+        if req.JobStatus == current.JobStatus {
+            return nil
+        }
+        if !containsString(jobStatusTransitions[current.JobStatus], req.JobStatus) {
+            return fmt.Errorf("%w: cannot move from %q to %q", ErrIllegalJobStatusTransition, current.JobStatus, req.JobStatus)
+        }
+        if isPublishing(req.JobStatus) {
+            return s.requireVerifiedEmail(ctx)
+        }
+        return nil
+    })
+    if errors.Is(err, repository.ErrNotFound) {
         return ErrJobDoesNotExist
     }
+    return err
+}
 
-    // Convert map to JSON for attributes
-    attributesJSON, err := json.Marshal(req.Attributes)
-    if err != nil {
-        return err
+func (s *JobService) GetJobById(ctx context.Context, jobID string) (*models.Job, error) {
+    orgID, _ := ctx.Value("orgID").(int)
+
+    job, err := s.jobs.Get(ctx, orgID, jobID)
+    if errors.Is(err, repository.ErrNotFound) {
+        return nil, ErrJobDoesNotExist
     }
+    return job, err
+}
 
-    query := `UPDATE jobs SET 
-        job_title = $1,
-        job_description = $2,
-        job_status = $3,
-        skills_required = $4,
-        attributes = $5
-        WHERE job_id = $6 AND user_id = $7`
-
-    _, err = db.ExecContext(ctx, query,
-        req.JobTitle,
-        req.JobDescription,
-        req.JobStatus,
-        pq.Array(req.SkillsRequired),
-        attributesJSON,
-        req.JobID,
-        userID)
+// jobRef resolves the organization's job_id to the internal jobs.id that
+// questionnaires, applications and pipelines refer to.
+func (s *JobService) jobRef(ctx context.Context, jobID string) (int, error) {
+    orgID, _ := ctx.Value("orgID").(int)
 
-    return err
+    ref, err := s.jobs.Ref(ctx, orgID, jobID)
+    if errors.Is(err, repository.ErrNotFound) {
+        return 0, ErrJobDoesNotExist
+    }
+    return ref, err
 }
 
-func GetJobById(ctx context.Context, jobID string) (*models.Job, error) {
-    db := database.GetDB()
-    userID := ctx.Value("userID")
-
-    var job models.Job
-    var skillsRequired pq.StringArray
-    var attributesJSON []byte
-
-    query := `SELECT job_id, job_title, job_description, job_status, skills_required, attributes 
-             FROM jobs WHERE job_id = $1 AND user_id = $2`
-    
-    err := db.QueryRowContext(ctx, query, jobID, userID).Scan(
-        &job.JobID,
-        &job.JobTitle,
-        &job.JobDescription,
-        &job.JobStatus,
-        &skillsRequired,
-        &attributesJSON,
-    )
-    if err != nil {
+func (s *JobService) GetJobsByTitle(ctx context.Context, jobTitle string, opts ListOptions) (*JobList, error) {
+    return s.listJobs(ctx, repository.JobFilter{Title: jobTitle}, opts)
+}
+
+func (s *JobService) GetJobsByStatus(ctx context.Context, status string, opts ListOptions) (*JobList, error) {
+    if err := validateJobStatus(status); err != nil {
         return nil, err
     }
 
-    job.SkillsRequired = []string(skillsRequired)
+    return s.listJobs(ctx, repository.JobFilter{Statuses: []string{status}}, opts)
+}
+
+func (s *JobService) GetJobsByUserId(ctx context.Context, opts ListOptions) (*JobList, error) {
+    return s.listJobs(ctx, repository.JobFilter{}, opts)
+}
 
-    // Unmarshal attributes JSON
-    if err := json.Unmarshal(attributesJSON, &job.Attributes); err != nil {
+// SearchJobs returns one page of the organization's jobs matching every filter of search.
+func (s *JobService) SearchJobs(ctx context.Context, search JobSearch, opts ListOptions) (*JobList, error) {
+    filter, err := search.filter()
+    if err != nil {
         return nil, err
     }
 
-    return &job, nil
+    return s.listJobs(ctx, filter, opts)
 }
 
-func GetJobsByTitle(ctx context.Context, jobTitle string) ([]*models.Job, error) {
-    db := database.GetDB()
-    userID := ctx.Value("userID")
+// listJobs returns one page of the organization's jobs matching filter, along
+// with the total number of matches.
+func (s *JobService) listJobs(ctx context.Context, filter repository.JobFilter, opts ListOptions) (*JobList, error) {
+    orgID, _ := ctx.Value("orgID").(int)
 
-    var jobs []*models.Job
-    query := `SELECT job_id, job_title, job_description, job_status, skills_required, attributes 
-             FROM jobs WHERE job_title ILIKE $1 AND user_id = $2`
-    
-    rows, err := db.QueryContext(ctx, query, "%"+jobTitle+"%", userID)
+    if err := opts.normalize(); err != nil {
+        return nil, err
+    }
+    page, err := opts.page()
     if err != nil {
         return nil, err
     }
-    defer rows.Close()
-
-    for rows.Next() {
-        var job models.Job
-        var skillsRequired pq.StringArray
-        var attributesJSON []byte
-
-        err := rows.Scan(
-            &job.JobID,
-            &job.JobTitle,
-            &job.JobDescription,
-            &job.JobStatus,
-            &skillsRequired,
-            &attributesJSON,
-        )
-        if err != nil {
-            return nil, err
-        }
-
-        job.SkillsRequired = []string(skillsRequired)
-        if err := json.Unmarshal(attributesJSON, &job.Attributes); err != nil {
-            return nil, err
-        }
 
-        jobs = append(jobs, &job)
+    result, err := s.jobs.List(ctx, orgID, filter, page)
+    if err != nil {
+        return nil, err
     }
 
-    return jobs, nil
+    list := &JobList{Jobs: result.Jobs, Total: result.Total}
+    if result.Next != nil {
+        list.NextCursor, err = opts.nextCursor(result.Next)
+    }
+    return list, err
 }
 
-func GetJobsByStatus(ctx context.Context, status string) ([]*models.Job, error) {
-    db := database.GetDB()
-    userID := ctx.Value("userID")
+func (s *JobService) DeleteJob(ctx context.Context, jobID string) error {
+    orgID, _ := ctx.Value("orgID").(int)
 
-    var jobs []*models.Job
-    query := `SELECT job_id, job_title, job_description, job_status, skills_required, attributes 
-             FROM jobs WHERE job_status = $1 AND user_id = $2`
-    
-    rows, err := db.QueryContext(ctx, query, status, userID)
-    if err != nil {
-        return nil, err
+    err := s.jobs.Delete(ctx, orgID, jobID)
+    if errors.Is(err, repository.ErrNotFound) {
+        return ErrJobDoesNotExist
     }
-    defer rows.Close()
-
-    for rows.Next() {
-        var job models.Job
-        var skillsRequired pq.StringArray
-        var attributesJSON []byte
-
-        err := rows.Scan(
-            &job.JobID,
-            &job.JobTitle,
-            &job.JobDescription,
-            &job.JobStatus,
-            &skillsRequired,
-            &attributesJSON,
-        )
-        if err != nil {
-            return nil, err
-        }
+    return err
+}
 
-        job.SkillsRequired = []string(skillsRequired)
-        if err := json.Unmarshal(attributesJSON, &job.Attributes); err != nil {
-            return nil, err
-        }
+// ProcessJobSchedules publishes scheduled jobs whose publish_at has passed and
+// closes active or paused jobs whose expires_at has passed.
+func (s *JobService) ProcessJobSchedules(ctx context.Context) (published int64, closed int64, err error) {
+    now := s.now()
 
-        jobs = append(jobs, &job)
+    if published, err = s.jobs.PublishDue(ctx, now); err != nil {
+        return 0, 0, err
     }
+    closed, err = s.jobs.CloseExpired(ctx, now)
+    return published, closed, err
+}
 
-    return jobs, nil
+var jobService *JobService
+
+// SetJobService replaces the service behind the package level job functions,
+// e.g. with one on memory repositories in tests. Init sets the Postgres one.
+func SetJobService(s *JobService) {
+    jobService = s
 }
 
-func GetJobsByUserId(ctx context.Context) ([]*models.Job, error) {
-    db := database.GetDB()
-    userID := ctx.Value("userID")
+func CreateJob(ctx context.Context, req *models.Job) error {
+    return jobService.CreateJob(ctx, req)
+}
 
-    var jobs []*models.Job
-    query := `SELECT job_id, job_title, job_description, job_status, skills_required, attributes 
-             FROM jobs WHERE user_id = $1`
-    
-    rows, err := db.QueryContext(ctx, query, userID)
-    if err != nil {
-        return nil, err
-    }
-    defer rows.Close()
-
-    for rows.Next() {
-        var job models.Job
-        var skillsRequired pq.StringArray
-        var attributesJSON []byte
-
-        err := rows.Scan(
-            &job.JobID,
-            &job.JobTitle,
-            &job.JobDescription,
-            &job.JobStatus,
-            &skillsRequired,
-            &attributesJSON,
-        )
-        if err != nil {
-            return nil, err
-        }
+func UpdateJob(ctx context.Context, req *models.Job) error {
+    return jobService.UpdateJob(ctx, req)
+}
 
-        job.SkillsRequired = []string(skillsRequired)
-        if err := json.Unmarshal(attributesJSON, &job.Attributes); err != nil {
-            return nil, err
-        }
+func GetJobById(ctx context.Context, jobID string) (*models.Job, error) {
+    return jobService.GetJobById(ctx, jobID)
+}
 
-        jobs = append(jobs, &job)
-    }
+func GetJobsByTitle(ctx context.Context, jobTitle string, opts ListOptions) (*JobList, error) {
+    return jobService.GetJobsByTitle(ctx, jobTitle, opts)
+}
 
-    return jobs, nil
+func GetJobsByStatus(ctx context.Context, status string, opts ListOptions) (*JobList, error) {
+    return jobService.GetJobsByStatus(ctx, status, opts)
+}
+
+func GetJobsByUserId(ctx context.Context, opts ListOptions) (*JobList, error) {
+    return jobService.GetJobsByUserId(ctx, opts)
+}
+
+func SearchJobs(ctx context.Context, search JobSearch, opts ListOptions) (*JobList, error) {
+    return jobService.SearchJobs(ctx, search, opts)
 }
 
 func DeleteJob(ctx context.Context, jobID string) error {
-    db := database.GetDB()
-    userID := ctx.Value("userID")
+    return jobService.DeleteJob(ctx, jobID)
+}
 
-    // Check if job exists for this user
-    var count int
-    err := db.GetContext(ctx, &count, "SELECT COUNT(*) FROM jobs WHERE job_id = $1 AND user_id = $2", jobID, userID)
-    if err != nil {
-        return err
-    }
-    if count == 0 {
-        return ErrJobDoesNotExist
-    }
+func ProcessJobSchedules(ctx context.Context) (published int64, closed int64, err error) {
+    return jobService.ProcessJobSchedules(ctx)
+}
 
-    // Delete the job
-    query := `DELETE FROM jobs WHERE job_id = $1 AND user_id = $2`
-    _, err = db.ExecContext(ctx, query, jobID, userID)
-    return err
+// RunJobScheduler calls ProcessJobSchedules every interval until ctx is cancelled.
+func RunJobScheduler(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        published, closed, err := ProcessJobSchedules(ctx)
+        if err != nil {
+            log.Printf("Job scheduler: %v", err)
+        } else if published > 0 || closed > 0 {
+            log.Printf("Job scheduler: published %d, closed %d jobs", published, closed)
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
 }
\ No newline at end of file
@@ -0,0 +1,284 @@
+package services
+
+import (
+    "backend/internal/models"
+    "backend/internal/repository"
+    "context"
+    "errors"
+    "fmt"
+    "testing"
+    "time"
+)
+
+// newTestJobService returns a job service on memory repositories with a verified
+// user 1 and an unverified user 2.
+func newTestJobService() *JobService {
+    users := repository.NewMemoryUsers()
+    verifiedAt := time.Now()
+    users.Put(&models.User{ID: 1, Email: "verified@example.com", EmailVerifiedAt: &verifiedAt})
+    users.Put(&models.User{ID: 2, Email: "unverified@example.com"})
+    return NewJobService(repository.NewMemoryJobs(), users)
+}
+
+// actingAs is the context the middleware sets up for a user in an organization.
+func actingAs(userID, orgID int) context.Context {
+    ctx := context.WithValue(context.Background(), "userID", userID)
+    return context.WithValue(ctx, "orgID", orgID)
+}
+
+func testJob(jobID, status string) *models.Job {
+    return &models.Job{
+        JobID:          jobID,
+        JobTitle:       "Backend developer " + jobID,
+        JobDescription: "Write Go services",
+        JobStatus:      status,
+        SkillsRequired: []string{"go", "sql"},
+        Attributes:     map[string]interface{}{"remote": true},
+    }
+}
+
+func TestCreateJob(t *testing.T) {
+    s := newTestJobService()
+    ctx := actingAs(1, 10)
+
+    if err := s.CreateJob(ctx, testJob("J1", models.JobStatusActive)); err != nil {
+        t.Fatalf("CreateJob: %v", err)
+    }
+    if err := s.CreateJob(ctx, testJob("J1", models.JobStatusDraft)); !errors.Is(err, ErrJobExists) {
+        t.Fatalf("duplicate job_id: got %v, want ErrJobExists", err)
+    }
+    // job_ids are unique per organization only
+    if err := s.CreateJob(actingAs(1, 11), testJob("J1", models.JobStatusDraft)); err != nil {
+        t.Fatalf("same job_id in another organization: %v", err)
+    }
+    if err := s.CreateJob(ctx, testJob("J2", models.JobStatusClosed)); !errors.Is(err, ErrIllegalJobStatusTransition) {
+        t.Fatalf("create as closed: got %v, want ErrIllegalJobStatusTransition", err)
+    }
+
+    job, err := s.GetJobById(ctx, "J1")
+    if err != nil {
+        t.Fatalf("GetJobById: %v", err)
+    }
+    if job.JobStatus != models.JobStatusActive || job.CreatedAt == "" {
+        t.Fatalf("stored job: %+v", job)
+    }
+    if _, err := s.GetJobById(actingAs(1, 12), "J1"); !errors.Is(err, ErrJobDoesNotExist) {
+        t.Fatalf("job of another organization: got %v, want ErrJobDoesNotExist", err)
+    }
+}
+
+func TestPublishingNeedsVerifiedEmail(t *testing.T) {
+    s := newTestJobService()
+    ctx := actingAs(2, 10)
+
+    if err := s.CreateJob(ctx, testJob("J1", models.JobStatusActive)); !errors.Is(err, ErrEmailNotVerified) {
+        t.Fatalf("publish unverified: got %v, want ErrEmailNotVerified", err)
+    }
+    if err := s.CreateJob(ctx, testJob("J1", models.JobStatusDraft)); err != nil {
+        t.Fatalf("draft unverified: %v", err)
+    }
+
+    job := testJob("J1", models.JobStatusActive)
+    if err := s.UpdateJob(ctx, job); !errors.Is(err, ErrEmailNotVerified) {
+        t.Fatalf("activate unverified: got %v, want ErrEmailNotVerified", err)
+    }
+}
+
+func TestJobRef(t *testing.T) {
+    s := newTestJobService()
+    ctx := actingAs(1, 10)
+
+    for _, jobID := range []string{"J1", "J2"} {
+        if err := s.CreateJob(ctx, testJob(jobID, models.JobStatusDraft)); err != nil {
+            t.Fatal(err)
+        }
+    }
+    first, err := s.jobRef(ctx, "J1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    second, _ := s.jobRef(ctx, "J2")
+    if first == second {
+        t.Fatalf("J1 and J2 both refer to %d", first)
+    }
+    if _, err := s.jobRef(actingAs(1, 11), "J1"); !errors.Is(err, ErrJobDoesNotExist) {
+        t.Fatalf("job of another organization: got %v, want ErrJobDoesNotExist", err)
+    }
+}
+
+func TestUpdateJob(t *testing.T) {
+    s := newTestJobService()
+    ctx := actingAs(1, 10)
+
+    if err := s.CreateJob(ctx, testJob("J1", models.JobStatusActive)); err != nil {
+        t.Fatal(err)
+    }
+
+    job := testJob("J1", models.JobStatusPaused)
+    job.JobTitle = "Renamed"
+    if err := s.UpdateJob(ctx, job); err != nil {
+        t.Fatalf("pause: %v", err)
+    }
+    stored, _ := s.GetJobById(ctx, "J1")
+    if stored.JobStatus != models.JobStatusPaused || stored.JobTitle != "Renamed" {
+        t.Fatalf("updated job: %+v", stored)
+    }
+
+    job.JobStatus = models.JobStatusDraft
+    if err := s.UpdateJob(ctx, job); !errors.Is(err, ErrIllegalJobStatusTransition) {
+        t.Fatalf("paused to draft: got %v, want ErrIllegalJobStatusTransition", err)
+    }
+    if err := s.UpdateJob(ctx, testJob("missing", models.JobStatusDraft)); !errors.Is(err, ErrJobDoesNotExist) {
+        t.Fatalf("update missing: got %v, want ErrJobDoesNotExist", err)
+    }
+}
+
+func TestDeleteJob(t *testing.T) {
+    s := newTestJobService()
+    ctx := actingAs(1, 10)
+
+    if err := s.CreateJob(ctx, testJob("J1", models.JobStatusDraft)); err != nil {
+        t.Fatal(err)
+    }
+    if err := s.DeleteJob(ctx, "J1"); err != nil {
+        t.Fatalf("DeleteJob: %v", err)
+    }
+    if err := s.DeleteJob(ctx, "J1"); !errors.Is(err, ErrJobDoesNotExist) {
+        t.Fatalf("delete twice: got %v, want ErrJobDoesNotExist", err)
+    }
+}
+
+func TestListJobsPages(t *testing.T) {
+    s := newTestJobService()
+    ctx := actingAs(1, 10)
+
+    for i := 0; i < 5; i++ {
+        if err := s.CreateJob(ctx, testJob(fmt.Sprintf("J%d", i), models.JobStatusDraft)); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    for _, tc := range []struct {
+        order string
+        want  string
+    }{
+        {"asc", "[J0 J1 J2 J3 J4]"},
+        {"desc", "[J4 J3 J2 J1 J0]"},
+    } {
+        opts := ListOptions{Limit: 2, SortBy: "job_title", Order: tc.order}
+        var seen []string
+        for pages := 0; ; pages++ {
+            if pages > 5 {
+                t.Fatalf("%s: paging does not end", tc.order)
+            }
+            list, err := s.GetJobsByUserId(ctx, opts)
+            if err != nil {
+                t.Fatalf("%s: %v", tc.order, err)
+            }
+            if list.Total != 5 {
+                t.Fatalf("%s: total %d, want 5", tc.order, list.Total)
+            }
+            for _, job := range list.Jobs {
+                seen = append(seen, job.JobID)
+            }
+            if list.NextCursor == "" {
+                break
+            }
+            opts.Cursor = list.NextCursor
+        }
+        if got := fmt.Sprint(seen); got != tc.want {
+            t.Fatalf("%s: got %s, want %s", tc.order, got, tc.want)
+        }
+    }
+
+    _, err := s.GetJobsByUserId(ctx, ListOptions{SortBy: "salary"})
+    if !errors.Is(err, ErrInvalidListOptions) {
+        t.Fatalf("unknown sort: got %v, want ErrInvalidListOptions", err)
+    }
+}
+
+func TestSearchJobs(t *testing.T) {
+    s := newTestJobService()
+    ctx := actingAs(1, 10)
+
+    onsite := testJob("J1", models.JobStatusDraft)
+    onsite.Attributes = map[string]interface{}{"remote": false, "level": 3}
+    onsite.SkillsRequired = []string{"java"}
+    for _, job := range []*models.Job{onsite, testJob("J2", models.JobStatusDraft), testJob("J3", models.JobStatusActive)} {
+        if err := s.CreateJob(ctx, job); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    for _, tc := range []struct {
+        name   string
+        search JobSearch
+        want   string
+    }{
+        {"attributes", JobSearch{Attributes: map[string]interface{}{"level": 3}}, "[J1]"},
+        {"skills all", JobSearch{SkillsAll: []string{"go", "sql"}}, "[J2 J3]"},
+        {"status and text", JobSearch{Text: "backend developer", Statuses: []string{models.JobStatusActive}}, "[J3]"},
+    } {
+        list, err := s.SearchJobs(ctx, tc.search, ListOptions{SortBy: "job_title", Order: "asc"})
+        if err != nil {
+            t.Fatalf("%s: %v", tc.name, err)
+        }
+        var got []string
+        for _, job := range list.Jobs {
+            got = append(got, job.JobID)
+        }
+        if fmt.Sprint(got) != tc.want {
+            t.Fatalf("%s: got %v, want %s", tc.name, got, tc.want)
+        }
+    }
+
+    _, err := s.SearchJobs(ctx, JobSearch{Statuses: []string{"open"}}, ListOptions{})
+    if !errors.Is(err, ErrInvalidJobStatus) {
+        t.Fatalf("unknown status: got %v, want ErrInvalidJobStatus", err)
+    }
+}
+
+func TestProcessJobSchedules(t *testing.T) {
+    s := newTestJobService()
+    ctx := actingAs(1, 10)
+    now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+    s.now = func() time.Time { return now }
+
+    scheduled := testJob("J1", models.JobStatusScheduled)
+    publishAt := now.Add(time.Hour)
+    expiresAt := now.Add(48 * time.Hour)
+    scheduled.PublishAt, scheduled.ExpiresAt = &publishAt, &expiresAt
+    if err := s.CreateJob(ctx, scheduled); err != nil {
+        t.Fatal(err)
+    }
+    active := testJob("J2", models.JobStatusActive)
+    activeUntil := now.Add(24 * time.Hour)
+    active.ExpiresAt = &activeUntil
+    if err := s.CreateJob(ctx, active); err != nil {
+        t.Fatal(err)
+    }
+
+    for _, step := range []struct {
+        at                time.Time
+        published, closed int64
+        j1, j2            string
+    }{
+        {publishAt.Add(-time.Second), 0, 0, models.JobStatusScheduled, models.JobStatusActive},
+        // Due at publish_at itself, and only once
+        {publishAt, 1, 0, models.JobStatusActive, models.JobStatusActive},
+        {publishAt, 0, 0, models.JobStatusActive, models.JobStatusActive},
+        {activeUntil, 0, 1, models.JobStatusActive, models.JobStatusClosed},
+        {expiresAt, 0, 1, models.JobStatusClosed, models.JobStatusClosed},
+    } {
+        now = step.at
+        published, closed, err := s.ProcessJobSchedules(ctx)
+        if err != nil || published != step.published || closed != step.closed {
+            t.Fatalf("at %s: published %d, closed %d, %v; want %d, %d", step.at, published, closed, err, step.published, step.closed)
+        }
+        for jobID, want := range map[string]string{"J1": step.j1, "J2": step.j2} {
+            if stored, _ := s.GetJobById(ctx, jobID); stored.JobStatus != want {
+                t.Fatalf("at %s: %s is %q, want %q", step.at, jobID, stored.JobStatus, want)
+            }
+        }
+    }
+}
@@ -0,0 +1,355 @@
+package services
+
+import (
+    "backend/internal/loginguard"
+    "backend/internal/models"
+    "backend/internal/repository"
+    "backend/internal/totp"
+    "context"
+    "crypto/rand"
+    "encoding/base32"
+    "errors"
+    "strings"
+    "time"
+)
+
+const (
+    mfaChallengeTTL   = 5 * time.Minute
+    maxMFAAttempts    = 5 // codes tried before a challenge stops working
+    recoveryCodeCount = 10
+    totpSkew          = 1 // steps of clock drift accepted either way
+)
+
+var (
+    ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+    ErrMFANotEnabled       = errors.New("two-factor authentication is not enabled")
+    ErrMFANotEnrolling     = errors.New("start two-factor enrollment first")
+    ErrInvalidMFACode      = errors.New("invalid two-factor code")
+    ErrInvalidMFAChallenge = errors.New("invalid or expired login challenge, log in again")
+)
+
+// MFAChallenge is returned by the first step of a login with two-factor
+// authentication enabled, instead of tokens.
+type MFAChallenge struct {
+    ChallengeToken string `json:"challenge_token"`
+    ExpiresIn      int    `json:"expires_in"` // seconds until the challenge expires
+}
+
+type MFALoginRequest struct {
+    ChallengeToken string      `json:"challenge_token" binding:"required"`
+    Code           string      `json:"code" binding:"required"` // authenticator code or recovery code
+    Meta           SessionMeta `json:"-"`
+}
+
+type MFACodeRequest struct {
+    Code string      `json:"code" binding:"required"`
+    Meta SessionMeta `json:"-"`
+}
+
+// TOTPEnrollment is what an authenticator app needs to start generating codes.
+type TOTPEnrollment struct {
+    Secret string `json:"secret"`
+    URI    string `json:"otpauth_uri"`
+}
+
+// RecoveryCodes each replace an authenticator code once; they are only shown when generated.
+type RecoveryCodes struct {
+    Codes []string `json:"recovery_codes"`
+}
+
+// MFAService runs the second step of logins and manages users' authenticators
+// and recovery codes.
+type MFAService struct {
+    mfa      repository.MFARepository
+    users    repository.UserRepository
+    sessions LoginSessions
+    guard    *loginguard.Guard
+    issuer   string           // shown next to the account in authenticator apps
+    now      func() time.Time // when codes are checked, replaced in tests
+}
+
+func NewMFAService(mfa repository.MFARepository, users repository.UserRepository, sessions LoginSessions, guard *loginguard.Guard, issuer string) *MFAService {
+    return &MFAService{mfa: mfa, users: users, sessions: sessions, guard: guard, issuer: issuer, now: time.Now}
+}
+
+// Challenge starts the second step of a login.
+func (s *MFAService) Challenge(ctx context.Context, userID int) (*MFAChallenge, error) {
+    token, hash, err := newOpaqueToken()
+    if err != nil {
+        return nil, err
+    }
+    if err := s.mfa.CreateChallenge(ctx, userID, hash, time.Now().Add(mfaChallengeTTL)); err != nil {
+        return nil, err
+    }
+    return &MFAChallenge{ChallengeToken: token, ExpiresIn: int(mfaChallengeTTL.Seconds())}, nil
+}
+
+// CompleteLogin finishes a login with a code and opens the session. Wrong codes
+// count as failed logins of the account, so the login guard's backoff applies to
+// guessing codes as it does to guessing passwords.
+func (s *MFAService) CompleteLogin(ctx context.Context, req *MFALoginRequest) (*AuthResponse, error) {
+    tokenHash := hashToken(req.ChallengeToken)
+    challenge, err := s.mfa.Challenge(ctx, tokenHash, maxMFAAttempts)
+    if errors.Is(err, repository.ErrNotFound) {
+        return nil, ErrInvalidMFAChallenge
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    failure := loginguard.Failure{Email: challenge.Email, IPAddress: req.Meta.IPAddress, UserAgent: req.Meta.UserAgent}
+    if err := s.checkGuard(ctx, failure); err != nil {
+        return nil, err
+    }
+
+    // The attempt is counted before the code is checked, so concurrent guesses
+    // cannot get past the limit
+    err = s.mfa.AttemptChallenge(ctx, tokenHash, maxMFAAttempts)
+    if errors.Is(err, repository.ErrNotFound) {
+        return nil, ErrInvalidMFAChallenge
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    if err := s.verifyCode(ctx, challenge.UserID, req.Code, failure); err != nil {
+        return nil, err
+    }
+
+    err = s.mfa.FinishChallenge(ctx, tokenHash)
+    if errors.Is(err, repository.ErrNotFound) {
+        return nil, ErrInvalidMFAChallenge
+    }
+    if err != nil {
+        return nil, err
+    }
+    if err := s.guard.Succeed(ctx, challenge.Email); err != nil {
+        return nil, err
+    }
+
+    user, err := s.users.GetByID(ctx, challenge.UserID)
+    if err != nil {
+        return nil, err
+    }
+    tokens, err := s.sessions.Start(ctx, user.ID, req.Meta)
+    if err != nil {
+        return nil, err
+    }
+
+    return &AuthResponse{
+        TokenPair: *tokens,
+        User: models.User{
+            Username:        user.Username,
+            Email:           user.Email,
+            EmailVerifiedAt: user.EmailVerifiedAt,
+        },
+    }, nil
+}
+
+// checkGuard refuses codes for an account or from an address that has to wait.
+func (s *MFAService) checkGuard(ctx context.Context, failure loginguard.Failure) error {
+    err := s.guard.Check(ctx, failure.Email, failure.IPAddress)
+    if errors.Is(err, loginguard.ErrLocked) {
+        failure.Reason = loginguard.ReasonLocked
+        if err := s.guard.Fail(ctx, failure); err != nil {
+            return err
+        }
+    }
+    return err
+}
+
+// verifyCode accepts an authenticator code or an unused recovery code of a user
+// with two-factor authentication enabled, each only once. A wrong code counts as
+// a failed login.
+func (s *MFAService) verifyCode(ctx context.Context, userID int, code string, failure loginguard.Failure) error {
+    err := s.acceptCode(ctx, userID, code)
+    if errors.Is(err, ErrInvalidMFACode) {
+        failure.Reason = loginguard.ReasonWrongMFACode
+        if err := s.guard.Fail(ctx, failure); err != nil {
+            return err
+        }
+    }
+    return err
+}
+
+func (s *MFAService) acceptCode(ctx context.Context, userID int, code string) error {
+    factor, err := s.mfa.SecondFactor(ctx, userID)
+    if err != nil {
+        return err
+    }
+    if factor.EnabledAt == nil {
+        return ErrMFANotEnabled
+    }
+
+    code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+    if step, ok := totp.Validate(factor.Secret, code, s.now(), totpSkew); ok {
+        // A code seen before, even within its validity window, was replayed
+        err := s.mfa.UseTOTPStep(ctx, userID, step)
+        if errors.Is(err, repository.ErrConflict) {
+            return ErrInvalidMFACode
+        }
+        return err
+    }
+
+    err = s.mfa.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(code)))
+    if errors.Is(err, repository.ErrNotFound) {
+        return ErrInvalidMFACode
+    }
+    return err
+}
+
+func normalizeRecoveryCode(code string) string {
+    return strings.ToLower(strings.ReplaceAll(code, "-", ""))
+}
+
+// newRecoveryCodes returns new recovery codes and the hashes to store for them.
+func newRecoveryCodes() (*RecoveryCodes, []string, error) {
+    codes := make([]string, recoveryCodeCount)
+    hashes := make([]string, recoveryCodeCount)
+    for i := range codes {
+        random := make([]byte, 7)
+        if _, err := rand.Read(random); err != nil {
+            return nil, nil, err
+        }
+        code := strings.ToLower(base32.StdEncoding.EncodeToString(random))[:10]
+        codes[i] = code[:5] + "-" + code[5:]
+        hashes[i] = hashToken(normalizeRecoveryCode(code))
+    }
+    return &RecoveryCodes{Codes: codes}, hashes, nil
+}
+
+// StartTOTPEnrollment gives the caller a new secret. Two-factor authentication is
+// only enabled once ConfirmTOTPEnrollment sees a code generated from it.
+func (s *MFAService) StartTOTPEnrollment(ctx context.Context) (*TOTPEnrollment, error) {
+    userID, _ := ctx.Value("userID").(int)
+
+    secret, err := totp.NewSecret()
+    if err != nil {
+        return nil, err
+    }
+
+    err = s.mfa.StartEnrollment(ctx, userID, secret)
+    if errors.Is(err, repository.ErrConflict) {
+        return nil, ErrMFAAlreadyEnabled
+    }
+    if err != nil {
+        return nil, err
+    }
+    factor, err := s.mfa.SecondFactor(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    return &TOTPEnrollment{
+        Secret: secret,
+        URI:    totp.URI(s.issuer, factor.Email, secret),
+    }, nil
+}
+
+// ConfirmTOTPEnrollment enables two-factor authentication with a code from the
+// new secret and returns the first recovery codes.
+func (s *MFAService) ConfirmTOTPEnrollment(ctx context.Context, req *MFACodeRequest) (*RecoveryCodes, error) {
+    userID, _ := ctx.Value("userID").(int)
+
+    factor, err := s.mfa.SecondFactor(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    if factor.EnabledAt != nil {
+        return nil, ErrMFAAlreadyEnabled
+    }
+    if factor.Secret == "" {
+        return nil, ErrMFANotEnrolling
+    }
+
+    step, ok := totp.Validate(factor.Secret, strings.TrimSpace(req.Code), s.now(), totpSkew)
+    if !ok {
+        return nil, ErrInvalidMFACode
+    }
+
+    codes, hashes, err := newRecoveryCodes()
+    if err != nil {
+        return nil, err
+    }
+    // A conflict means another enrollment started or finished in the meantime
+    err = s.mfa.Enable(ctx, userID, factor.Secret, step, hashes)
+    if errors.Is(err, repository.ErrConflict) {
+        return nil, ErrMFANotEnrolling
+    }
+    if err != nil {
+        return nil, err
+    }
+    return codes, nil
+}
+
+// confirmWithMFACode accepts the caller's code before a change to their second
+// factor. Wrong codes count as failed logins of the account, the same as at a
+// login challenge, so a stolen session cannot guess its way past the second factor.
+func (s *MFAService) confirmWithMFACode(ctx context.Context, req *MFACodeRequest) (int, error) {
+    userID, _ := ctx.Value("userID").(int)
+
+    factor, err := s.mfa.SecondFactor(ctx, userID)
+    if err != nil {
+        return 0, err
+    }
+
+    failure := loginguard.Failure{Email: factor.Email, IPAddress: req.Meta.IPAddress, UserAgent: req.Meta.UserAgent}
+    if err := s.checkGuard(ctx, failure); err != nil {
+        return 0, err
+    }
+    return userID, s.verifyCode(ctx, userID, req.Code, failure)
+}
+
+// DisableTOTP turns two-factor authentication off, after a last code.
+func (s *MFAService) DisableTOTP(ctx context.Context, req *MFACodeRequest) error {
+    userID, err := s.confirmWithMFACode(ctx, req)
+    if err != nil {
+        return err
+    }
+    return s.mfa.Disable(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes, e.g. when most are used up.
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, req *MFACodeRequest) (*RecoveryCodes, error) {
+    userID, err := s.confirmWithMFACode(ctx, req)
+    if err != nil {
+        return nil, err
+    }
+
+    codes, hashes, err := newRecoveryCodes()
+    if err != nil {
+        return nil, err
+    }
+    if err := s.mfa.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+        return nil, err
+    }
+    return codes, nil
+}
+
+var mfaService *MFAService
+
+// SetMFAService replaces the service behind the two-factor functions below, e.g.
+// with one on memory repositories in tests. Init sets the Postgres one.
+func SetMFAService(s *MFAService) {
+    mfaService = s
+}
+
+func CompleteMFALogin(ctx context.Context, req *MFALoginRequest) (*AuthResponse, error) {
+    return mfaService.CompleteLogin(ctx, req)
+}
+
+func StartTOTPEnrollment(ctx context.Context) (*TOTPEnrollment, error) {
+    return mfaService.StartTOTPEnrollment(ctx)
+}
+
+func ConfirmTOTPEnrollment(ctx context.Context, req *MFACodeRequest) (*RecoveryCodes, error) {
+    return mfaService.ConfirmTOTPEnrollment(ctx, req)
+}
+
+func DisableTOTP(ctx context.Context, req *MFACodeRequest) error {
+    return mfaService.DisableTOTP(ctx, req)
+}
+
+func RegenerateRecoveryCodes(ctx context.Context, req *MFACodeRequest) (*RecoveryCodes, error) {
+    return mfaService.RegenerateRecoveryCodes(ctx, req)
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+    "backend/internal/loginguard"
+    "backend/internal/models"
+    "backend/internal/repository"
+    "backend/internal/totp"
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+)
+
+type testMFA struct {
+    service  *MFAService
+    users    *repository.MemoryUsers
+    sessions *fakeSessions
+    now      time.Time
+    secret   string
+    recovery []string
+}
+
+// newTestMFAService returns a two-factor service on memory repositories whose
+// clock stands still, with user 1 enrolled at that time. Its guard only backs off
+// after many failures, so the challenge's own limit shows.
+func newTestMFAService(t *testing.T) *testMFA {
+    a := &testMFA{users: repository.NewMemoryUsers(), sessions: &fakeSessions{}, now: time.Unix(1700000000, 0)}
+    a.users.Put(&models.User{ID: 1, Username: "ada", Email: "ada@example.com"})
+    guard := loginguard.New(loginguard.NewMemory(),
+        loginguard.Policy{Threshold: 100, BaseDelay: time.Minute, MaxDelay: time.Hour},
+        loginguard.Policy{Threshold: 100, BaseDelay: time.Minute, MaxDelay: time.Hour},
+        time.Hour)
+    a.service = NewMFAService(repository.NewMemoryMFA(a.users), a.users, a.sessions, guard, "HireEasy")
+    a.service.now = func() time.Time { return a.now }
+
+    ctx := actingAs(1, 1)
+    enrollment, err := a.service.StartTOTPEnrollment(ctx)
+    if err != nil {
+        t.Fatalf("StartTOTPEnrollment: %v", err)
+    }
+    if !strings.HasPrefix(enrollment.URI, "otpauth://totp/HireEasy:ada@example.com?") {
+        t.Fatalf("URI %q", enrollment.URI)
+    }
+    a.secret = enrollment.Secret
+
+    codes, err := a.service.ConfirmTOTPEnrollment(ctx, &MFACodeRequest{Code: a.code(-1)})
+    if err != nil {
+        t.Fatalf("ConfirmTOTPEnrollment: %v", err)
+    }
+    a.recovery = codes.Codes
+    return a
+}
+
+// code returns the authenticator code offset steps from now.
+func (a *testMFA) code(offset int64) string {
+    code, _ := totp.Code(a.secret, totp.Step(a.now)+offset)
+    return code
+}
+
+func (a *testMFA) challenge(t *testing.T) string {
+    challenge, err := a.service.Challenge(context.Background(), 1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return challenge.ChallengeToken
+}
+
+func (a *testMFA) complete(challenge, code string) (*AuthResponse, error) {
+    return a.service.CompleteLogin(context.Background(), &MFALoginRequest{ChallengeToken: challenge, Code: code})
+}
+
+func TestMFAEnrollment(t *testing.T) {
+    a := newTestMFAService(t)
+    ctx := actingAs(1, 1)
+
+    user, _ := a.users.GetByID(ctx, 1)
+    if user.TOTPEnabledAt == nil {
+        t.Fatal("two-factor authentication is not enabled")
+    }
+    if len(a.recovery) != recoveryCodeCount {
+        t.Fatalf("%d recovery codes", len(a.recovery))
+    }
+    if _, err := a.service.StartTOTPEnrollment(ctx); !errors.Is(err, ErrMFAAlreadyEnabled) {
+        t.Fatalf("enroll twice: got %v, want ErrMFAAlreadyEnabled", err)
+    }
+
+    // The code that confirmed the enrollment does not log in as well
+    if _, err := a.complete(a.challenge(t), a.code(-1)); !errors.Is(err, ErrInvalidMFACode) {
+        t.Fatalf("enrollment code: got %v, want ErrInvalidMFACode", err)
+    }
+
+    a.users.Put(&models.User{ID: 2, Email: "grace@example.com"})
+    other := actingAs(2, 2)
+    if _, err := a.service.ConfirmTOTPEnrollment(other, &MFACodeRequest{Code: "123456"}); !errors.Is(err, ErrMFANotEnrolling) {
+        t.Fatalf("confirm before starting: got %v, want ErrMFANotEnrolling", err)
+    }
+    if _, err := a.service.StartTOTPEnrollment(other); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := a.service.ConfirmTOTPEnrollment(other, &MFACodeRequest{Code: "000000"}); !errors.Is(err, ErrInvalidMFACode) {
+        t.Fatalf("confirm with a wrong code: got %v, want ErrInvalidMFACode", err)
+    }
+}
+
+func TestMFALoginAcceptsClockSkew(t *testing.T) {
+    for _, tc := range []struct {
+        offset int64
+        err    error
+    }{
+        {-2, ErrInvalidMFACode},
+        {-1, nil},
+        {0, nil},
+        {1, nil},
+        {2, ErrInvalidMFACode},
+    } {
+        a := newTestMFAService(t)
+        // Far enough from the enrollment that its code is not the one tried
+        a.now = a.now.Add(3 * totp.Period)
+        resp, err := a.complete(a.challenge(t), a.code(tc.offset))
+        if !errors.Is(err, tc.err) {
+            t.Fatalf("offset %d: got %v, want %v", tc.offset, err, tc.err)
+        }
+        if err == nil && (resp.User.Email != "ada@example.com" || len(a.sessions.started) != 1) {
+            t.Fatalf("offset %d: response %+v, sessions %v", tc.offset, resp, a.sessions.started)
+        }
+    }
+}
+
+func TestMFALoginRejectsReplay(t *testing.T) {
+    a := newTestMFAService(t)
+
+    code := a.code(0)
+    if _, err := a.complete(a.challenge(t), code); err != nil {
+        t.Fatal(err)
+    }
+    // Still within its window, but seen already
+    if _, err := a.complete(a.challenge(t), code); !errors.Is(err, ErrInvalidMFACode) {
+        t.Fatalf("same code again: got %v, want ErrInvalidMFACode", err)
+    }
+    // Nor does an older code work once a newer one was used
+    a.now = a.now.Add(totp.Period)
+    if _, err := a.complete(a.challenge(t), a.code(0)); err != nil {
+        t.Fatalf("next code: %v", err)
+    }
+    if _, err := a.complete(a.challenge(t), a.code(-1)); !errors.Is(err, ErrInvalidMFACode) {
+        t.Fatalf("previous code after the next: got %v, want ErrInvalidMFACode", err)
+    }
+}
+
+func TestMFARecoveryCodes(t *testing.T) {
+    a := newTestMFAService(t)
+
+    // Codes are accepted as people type them: upper case, spaces, no dash
+    code := a.recovery[0]
+    typed := " " + strings.ToUpper(strings.Replace(code, "-", " ", 1)) + " "
+    if _, err := a.complete(a.challenge(t), typed); err != nil {
+        t.Fatalf("recovery code %q: %v", typed, err)
+    }
+    if _, err := a.complete(a.challenge(t), code); !errors.Is(err, ErrInvalidMFACode) {
+        t.Fatalf("recovery code used twice: got %v, want ErrInvalidMFACode", err)
+    }
+    if _, err := a.complete(a.challenge(t), strings.ReplaceAll(a.recovery[1], "-", "")); err != nil {
+        t.Fatalf("recovery code without dash: %v", err)
+    }
+
+    // Regenerating invalidates the old codes
+    ctx := actingAs(1, 1)
+    codes, err := a.service.RegenerateRecoveryCodes(ctx, &MFACodeRequest{Code: a.code(0)})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := a.complete(a.challenge(t), a.recovery[2]); !errors.Is(err, ErrInvalidMFACode) {
+        t.Fatalf("old recovery code: got %v, want ErrInvalidMFACode", err)
+    }
+    if _, err := a.complete(a.challenge(t), codes.Codes[0]); err != nil {
+        t.Fatalf("new recovery code: %v", err)
+    }
+}
+
+func TestMFAChallengeAttemptLimit(t *testing.T) {
+    a := newTestMFAService(t)
+    challenge := a.challenge(t)
+
+    for i := 0; i < maxMFAAttempts; i++ {
+        if _, err := a.complete(challenge, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+            t.Fatalf("attempt %d: got %v, want ErrInvalidMFACode", i+1, err)
+        }
+    }
+    // The right code is too late now, the user has to log in again
+    if _, err := a.complete(challenge, a.code(0)); !errors.Is(err, ErrInvalidMFAChallenge) {
+        t.Fatalf("after %d attempts: got %v, want ErrInvalidMFAChallenge", maxMFAAttempts, err)
+    }
+
+    // A challenge completes once
+    challenge = a.challenge(t)
+    if _, err := a.complete(challenge, a.code(0)); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := a.complete(challenge, a.recovery[0]); !errors.Is(err, ErrInvalidMFAChallenge) {
+        t.Fatalf("used challenge: got %v, want ErrInvalidMFAChallenge", err)
+    }
+    if _, err := a.complete("made up", a.recovery[0]); !errors.Is(err, ErrInvalidMFAChallenge) {
+        t.Fatalf("unknown challenge: got %v, want ErrInvalidMFAChallenge", err)
+    }
+}
+
+func TestDisableTOTP(t *testing.T) {
+    a := newTestMFAService(t)
+    ctx := actingAs(1, 1)
+
+    if err := a.service.DisableTOTP(ctx, &MFACodeRequest{Code: "000000"}); !errors.Is(err, ErrInvalidMFACode) {
+        t.Fatalf("wrong code: got %v, want ErrInvalidMFACode", err)
+    }
+    if err := a.service.DisableTOTP(ctx, &MFACodeRequest{Code: a.recovery[0]}); err != nil {
+        t.Fatal(err)
+    }
+    user, _ := a.users.GetByID(ctx, 1)
+    if user.TOTPEnabledAt != nil {
+        t.Fatal("two-factor authentication is still enabled")
+    }
+    if err := a.service.DisableTOTP(ctx, &MFACodeRequest{Code: a.code(0)}); !errors.Is(err, ErrMFANotEnabled) {
+        t.Fatalf("disable twice: got %v, want ErrMFANotEnabled", err)
+    }
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+    "backend/internal/database"
+    "backend/internal/models"
+    "backend/internal/repository"
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+
+    "github.com/jmoiron/sqlx"
+)
+
+var (
+    ErrOrganizationDoesNotExist = errors.New("organization does not exist or you are not a member")
+    ErrInvalidRole              = errors.New("invalid role")
+    ErrInsufficientRole         = errors.New("your role in this organization does not allow this")
+    ErrMemberExists             = errors.New("user is already a member of this organization")
+    ErrMemberDoesNotExist       = errors.New("member does not exist in this organization")
+    ErrLastOwner                = errors.New("organization must keep at least one owner")
+    ErrLastOrganization         = errors.New("you cannot delete your only organization")
+)
+
+// RolePermissions lists the permissions each role grants.
+var RolePermissions = map[string][]string{
+    models.RoleOwner: {
+        models.PermJobsRead, models.PermJobsWrite, models.PermJobsDelete,
+        models.PermApplicationsRead, models.PermApplicationsWrite,
+        models.PermMembersRead, models.PermMembersWrite, models.PermOrganizationDelete,
+    },
+    models.RoleAdmin: {
+        models.PermJobsRead, models.PermJobsWrite, models.PermJobsDelete,
+        models.PermApplicationsRead, models.PermApplicationsWrite,
+        models.PermMembersRead, models.PermMembersWrite,
+    },
+    models.RoleRecruiter: {
+        models.PermJobsRead, models.PermJobsWrite,
+        models.PermApplicationsRead, models.PermApplicationsWrite,
+        models.PermMembersRead,
+    },
+    models.RoleInterviewer: {
+        models.PermJobsRead,
+        models.PermApplicationsRead,
+        models.PermMembersRead,
+    },
+    models.RoleViewer: {
+        models.PermJobsRead,
+        models.PermMembersRead,
+    },
+}
+
+func validateRole(role string) error {
+    if _, ok := RolePermissions[role]; !ok {
+        return fmt.Errorf("%w: %q", ErrInvalidRole, role)
+    }
+    return nil
+}
+
+// HasPermission reports whether role grants permission.
+func HasPermission(role, permission string) bool {
+    return containsString(RolePermissions[role], permission)
+}
+
+type AddMemberRequest struct {
+    Email string `json:"email" binding:"required,email"`
+    Role  string `json:"role" binding:"required"`
+}
+
+type UpdateMemberRequest struct {
+    Role string `json:"role" binding:"required"`
+}
+
+// MemberRole returns the role of a user in an organization.
+func MemberRole(ctx context.Context, userID, orgID int) (string, error) {
+    db := database.GetDB()
+
+    var role string
+    err := db.GetContext(ctx, &role, "SELECT role FROM memberships WHERE org_id = $1 AND user_id = $2", orgID, userID)
+    if err == sql.ErrNoRows {
+        return "", ErrOrganizationDoesNotExist
+    }
+    return role, err
+}
+
+// DefaultOrganization returns the organization a user joined first, used when a
+// request does not name one.
+func DefaultOrganization(ctx context.Context, userID int) (int, string, error) {
+    db := database.GetDB()
+
+    var membership struct {
+        OrgID int    `db:"org_id"`
+        Role  string `db:"role"`
+    }
+    err := db.GetContext(ctx, &membership,
+        "SELECT org_id, role FROM memberships WHERE user_id = $1 ORDER BY created_at, org_id LIMIT 1", userID)
+    if err == sql.ErrNoRows {
+        return 0, "", ErrOrganizationDoesNotExist
+    }
+    return membership.OrgID, membership.Role, err
+}
+
+// CreateOrganization creates an organization with the caller as its owner.
+func CreateOrganization(ctx context.Context, name string) (*models.Organization, error) {
+    db := database.GetDB()
+    userID, _ := ctx.Value("userID").(int)
+
+    var org *models.Organization
+    err := database.WithTx(ctx, db, func(tx *sqlx.Tx) (err error) {
+        org, err = repository.CreateOrganization(ctx, tx, name, userID)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    return org, nil
+}
+
+// ListOrganizations returns the organizations the caller belongs to, with their role in each.
+func ListOrganizations(ctx context.Context) ([]*models.Organization, error) {
+    db := database.GetDB()
+    userID := ctx.Value("userID")
+
+    orgs := []*models.Organization{}
+    err := db.SelectContext(ctx, &orgs,
+        `SELECT o.id, o.name, m.role, o.created_at
+         FROM organizations o JOIN memberships m ON m.org_id = o.id
+         WHERE m.user_id = $1
+         ORDER BY m.created_at, o.id`, userID)
+    return orgs, err
+}
+
+// DeleteOrganization deletes the current organization with all of its jobs, unless
+// it is the caller's only one. Other members it was the only organization of get a
+// new personal one, as at registration, so nobody is left without an organization.
+func DeleteOrganization(ctx context.Context) error {
+    db := database.GetDB()
+    orgID := ctx.Value("orgID")
+    userID, _ := ctx.Value("userID").(int)
+
+    return database.WithTx(ctx, db, func(tx *sqlx.Tx) error {
+        // Lock the members in id order, so two deletions sharing a member cannot
+        // both count on the other organization and leave the member with none
+        _, err := tx.ExecContext(ctx,
+            `SELECT u.id FROM users u JOIN memberships m ON m.user_id = u.id
+             WHERE m.org_id = $1 ORDER BY u.id FOR UPDATE OF u`, orgID)
+        if err != nil {
+            return err
+        }
+
+        var stranded []struct {
+            UserID   int    `db:"user_id"`
+            Username string `db:"username"`
+        }
+        err = tx.SelectContext(ctx, &stranded,
+            `SELECT m.user_id, u.username FROM memberships m JOIN users u ON u.id = m.user_id
+             WHERE m.org_id = $1 AND NOT EXISTS (
+                 SELECT 1 FROM memberships other WHERE other.user_id = m.user_id AND other.org_id <> m.org_id)
+             ORDER BY m.user_id`, orgID)
+        if err != nil {
+            return err
+        }
+        for _, member := range stranded {
+            if member.UserID == userID {
+                return ErrLastOrganization
+            }
+        }
+
+        if _, err := tx.ExecContext(ctx, "DELETE FROM organizations WHERE id = $1", orgID); err != nil {
+            return err
+        }
+        for _, member := range stranded {
+            if _, err := repository.CreateOrganization(ctx, tx, member.Username, member.UserID); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+}
+
+// ListMembers returns the members of the current organization.
+func ListMembers(ctx context.Context) ([]*models.Member, error) {
+    db := database.GetDB()
+    orgID := ctx.Value("orgID")
+
+    members := []*models.Member{}
+    err := db.SelectContext(ctx, &members,
+        `SELECT m.user_id, u.username, u.email, m.role, m.created_at
+         FROM memberships m JOIN users u ON u.id = m.user_id
+         WHERE m.org_id = $1
+         ORDER BY m.created_at, m.user_id`, orgID)
+    return members, err
+}
+
+// checkCanAssign stops non owners from handing out or taking away ownership.
+func checkCanAssign(callerRole string, roles ...string) error {
+    for _, role := range roles {
+        if role == models.RoleOwner && callerRole != models.RoleOwner {
+            return fmt.Errorf("%w: only owners can manage owners", ErrInsufficientRole)
+        }
+    }
+    return nil
+}
+
+// AddMember adds an existing user to the current organization. An email nobody
+// registered with is not an error, so members cannot find out who has an account.
+func AddMember(ctx context.Context, req *AddMemberRequest) error {
+    db := database.GetDB()
+    orgID := ctx.Value("orgID")
+    callerRole, _ := ctx.Value("role").(string)
+
+    if err := validateRole(req.Role); err != nil {
+        return err
+    }
+    if err := checkCanAssign(callerRole, req.Role); err != nil {
+        return err
+    }
+
+    var memberID int
+    err := db.GetContext(ctx, &memberID, "SELECT id FROM users WHERE email = $1", req.Email)
+    if err == sql.ErrNoRows {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+
+    // Current members are listed to every member already, saying so reveals nothing
+    result, err := db.ExecContext(ctx,
+        `INSERT INTO memberships (org_id, user_id, role) VALUES ($1, $2, $3)
+         ON CONFLICT (org_id, user_id) DO NOTHING`, orgID, memberID, req.Role)
+    if err != nil {
+        return err
+    }
+    added, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if added == 0 {
+        return ErrMemberExists
+    }
+    return nil
+}
+
+// changeMember updates or, with role empty, removes a member of the current
+// organization. The organization row is locked so two concurrent changes cannot
+// both remove the last owner.
+func changeMember(ctx context.Context, userID int, role string) error {
+    db := database.GetDB()
+    orgID := ctx.Value("orgID")
+    callerRole, _ := ctx.Value("role").(string)
+
+    return database.WithTx(ctx, db, func(tx *sqlx.Tx) error {
+        if _, err := tx.ExecContext(ctx, "SELECT id FROM organizations WHERE id = $1 FOR UPDATE", orgID); err != nil {
+            return err
+        }
+
+        var currentRole string
+        err := tx.GetContext(ctx, &currentRole, "SELECT role FROM memberships WHERE org_id = $1 AND user_id = $2", orgID, userID)
+        if err == sql.ErrNoRows {
+            return ErrMemberDoesNotExist
+        }
+        if err != nil {
+            return err
+        }
+        if err := checkCanAssign(callerRole, currentRole, role); err != nil {
+            return err
+        }
+
+        if currentRole == models.RoleOwner && role != models.RoleOwner {
+            var owners int
+            err := tx.GetContext(ctx, &owners,
+                "SELECT COUNT(*) FROM memberships WHERE org_id = $1 AND role = $2", orgID, models.RoleOwner)
+            if err != nil {
+                return err
+            }
+            if owners <= 1 {
+                return ErrLastOwner
+            }
+        }
+
+        if role == "" {
+            _, err = tx.ExecContext(ctx, "DELETE FROM memberships WHERE org_id = $1 AND user_id = $2", orgID, userID)
+        } else {
+            _, err = tx.ExecContext(ctx, "UPDATE memberships SET role = $1 WHERE org_id = $2 AND user_id = $3", role, orgID, userID)
+        }
+        return err
+    })
+}
+
+// UpdateMemberRole changes the role of a member of the current organization.
+func UpdateMemberRole(ctx context.Context, userID int, req *UpdateMemberRequest) error {
+    if err := validateRole(req.Role); err != nil {
+        return err
+    }
+    return changeMember(ctx, userID, req.Role)
+}
+
+// RemoveMember removes a member from the current organization.
+func RemoveMember(ctx context.Context, userID int) error {
+    return changeMember(ctx, userID, "")
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+    "backend/internal/models"
+    "backend/internal/repository"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+)
+
+const (
+    DefaultListLimit = 20
+    MaxListLimit     = 100
+)
+
+var ErrInvalidListOptions = errors.New("invalid list options")
+
+// ListOptions selects one page of a listing. Pages are keyed on the sort column
+// plus id, so they stay stable while rows are added or removed.
+type ListOptions struct {
+    Limit  int
+    Cursor string // next_cursor of the previous page
+    SortBy string // created_at, updated_at or job_title
+    Order  string // asc or desc
+}
+
+type JobList struct {
+    Jobs       []*models.Job `json:"jobs"`
+    NextCursor string        `json:"next_cursor,omitempty"`
+    Total      int           `json:"total"`
+}
+
+type cursor struct {
+    SortBy string `json:"s"`
+    Order  string `json:"o"`
+    Value  string `json:"v"`
+    ID     int    `json:"i"`
+}
+
+// normalize fills in defaults and rejects unknown sort keys and out of range limits.
+func (o *ListOptions) normalize() error {
+    if o.Limit == 0 {
+        o.Limit = DefaultListLimit
+    }
+    if o.Limit < 1 || o.Limit > MaxListLimit {
+        return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidListOptions, MaxListLimit)
+    }
+    if o.SortBy == "" {
+        o.SortBy = "created_at"
+    }
+    if !containsString(repository.JobSortKeys, o.SortBy) {
+        return fmt.Errorf("%w: cannot sort by %q", ErrInvalidListOptions, o.SortBy)
+    }
+    if o.Order == "" {
+        o.Order = "desc"
+    }
+    if o.Order != "asc" && o.Order != "desc" {
+        return fmt.Errorf("%w: order must be asc or desc", ErrInvalidListOptions)
+    }
+    return nil
+}
+
+// page converts the options to a repository page, checking the cursor was issued
+// for the same sort.
+func (o ListOptions) page() (repository.Page, error) {
+    page := repository.Page{SortBy: o.SortBy, Desc: o.Order == "desc", Limit: o.Limit}
+    if o.Cursor != "" {
+        c, err := decodeCursor(o.Cursor)
+        if err != nil {
+            return repository.Page{}, err
+        }
+        if c.SortBy != o.SortBy || c.Order != o.Order {
+            return repository.Page{}, fmt.Errorf("%w: cursor was issued for a different sort", ErrInvalidListOptions)
+        }
+        page.After = &repository.Cursor{Value: c.Value, ID: c.ID}
+    }
+    return page, nil
+}
+
+// nextCursor encodes the position after the last job of a page.
+func (o ListOptions) nextCursor(after *repository.Cursor) (string, error) {
+    c := cursor{SortBy: o.SortBy, Order: o.Order, Value: after.Value, ID: after.ID}
+
+    raw, err := json.Marshal(c)
+    if err != nil {
+        return "", err
+    }
+    return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(s string) (*cursor, error) {
+    raw, err := base64.RawURLEncoding.DecodeString(s)
+    if err != nil {
+        return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidListOptions)
+    }
+
+    var c cursor
+    if err := json.Unmarshal(raw, &c); err != nil {
+        return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidListOptions)
+    }
+    return &c, nil
+}
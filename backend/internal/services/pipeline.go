@@ -0,0 +1,260 @@
+package services
+
+import (
+    "backend/internal/database"
+    "backend/internal/models"
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+
+    "github.com/jmoiron/sqlx"
+)
+
+var (
+    ErrInvalidPipeline   = errors.New("invalid pipeline")
+    ErrIllegalTransition = errors.New("illegal stage transition")
+)
+
+// knownStages are the stages a pipeline may use.
+var knownStages = map[string]bool{
+    models.StageApplied:   true,
+    models.StageScreening: true,
+    models.StageInterview: true,
+    models.StageOffer:     true,
+    models.StageHired:     true,
+    models.StageRejected:  true,
+    models.StageWithdrawn: true,
+}
+
+// DefaultPipeline is used by jobs that have not configured their own.
+func DefaultPipeline() map[string][]string {
+    return map[string][]string{
+        models.StageApplied:   {models.StageScreening, models.StageInterview, models.StageRejected, models.StageWithdrawn},
+        models.StageScreening: {models.StageInterview, models.StageRejected, models.StageWithdrawn},
+        models.StageInterview: {models.StageOffer, models.StageRejected, models.StageWithdrawn},
+        models.StageOffer:     {models.StageHired, models.StageRejected, models.StageWithdrawn},
+        models.StageHired:     {},
+        models.StageRejected:  {},
+        models.StageWithdrawn: {},
+    }
+}
+
+// validatePipeline checks that a pipeline starts at applied, only uses known stages
+// and lists each move once.
+func validatePipeline(transitions map[string][]string) error {
+    if _, ok := transitions[models.StageApplied]; !ok {
+        return fmt.Errorf("%w: pipeline must include the %q stage", ErrInvalidPipeline, models.StageApplied)
+    }
+
+    for from, targets := range transitions {
+        if !knownStages[from] {
+            return fmt.Errorf("%w: unknown stage %q", ErrInvalidPipeline, from)
+        }
+        for i, to := range targets {
+            if containsString(targets[:i], to) {
+                return fmt.Errorf("%w: stage %q lists %q twice", ErrInvalidPipeline, from, to)
+            }
+            if _, ok := transitions[to]; !ok {
+                return fmt.Errorf("%w: stage %q moves to %q which is not in the pipeline", ErrInvalidPipeline, from, to)
+            }
+            if to == from {
+                return fmt.Errorf("%w: stage %q cannot move to itself", ErrInvalidPipeline, from)
+            }
+        }
+    }
+    return nil
+}
+
+// checkTransition returns ErrIllegalTransition unless the pipeline moves from to to.
+func checkTransition(transitions map[string][]string, from, to string) error {
+    if !containsString(transitions[from], to) {
+        return fmt.Errorf("%w: cannot move from %q to %q", ErrIllegalTransition, from, to)
+    }
+    return nil
+}
+
+// getTransitions returns the job's configured pipeline, or the default one.
+func getTransitions(ctx context.Context, q sqlQueryer, jobRef int) (map[string][]string, error) {
+    var transitionsJSON []byte
+    err := q.QueryRowContext(ctx, "SELECT transitions FROM job_pipelines WHERE job_ref = $1", jobRef).Scan(&transitionsJSON)
+    if err == sql.ErrNoRows {
+        return DefaultPipeline(), nil
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    var transitions map[string][]string
+    if err := json.Unmarshal(transitionsJSON, &transitions); err != nil {
+        return nil, err
+    }
+    return transitions, nil
+}
+
+// sqlQueryer is satisfied by both *sqlx.DB and *sqlx.Tx.
+type sqlQueryer interface {
+    QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func GetPipeline(ctx context.Context, jobID string) (*models.Pipeline, error) {
+    jobRef, err := getJobRef(ctx, jobID)
+    if err != nil {
+        return nil, err
+    }
+
+    transitions, err := getTransitions(ctx, database.GetDB(), jobRef)
+    if err != nil {
+        return nil, err
+    }
+
+    return &models.Pipeline{JobID: jobID, Transitions: transitions}, nil
+}
+
+// SetPipeline replaces the job's pipeline. Applications already in a stage that
+// the new pipeline drops would be stranded, so that is rejected. The job row stays
+// locked until the new pipeline is stored, and TransitionApplication waits on it,
+// so no application moves into a dropped stage between the check and the write.
+func SetPipeline(ctx context.Context, jobID string, req *models.Pipeline) error {
+    db := database.GetDB()
+
+    if err := validatePipeline(req.Transitions); err != nil {
+        return err
+    }
+
+    jobRef, err := getJobRef(ctx, jobID)
+    if err != nil {
+        return err
+    }
+
+    transitionsJSON, err := json.Marshal(req.Transitions)
+    if err != nil {
+        return err
+    }
+
+    err = database.WithTx(ctx, db, func(tx *sqlx.Tx) error {
+        err := tx.QueryRowContext(ctx, "SELECT id FROM jobs WHERE id = $1 FOR UPDATE", jobRef).Scan(&jobRef)
+        if err == sql.ErrNoRows {
+            return ErrJobDoesNotExist
+        }
+        if err != nil {
+            return err
+        }
+
+        var stages []string
+        err = tx.SelectContext(ctx, &stages, "SELECT DISTINCT stage FROM applications WHERE job_ref = $1", jobRef)
+        if err != nil {
+            return err
+        }
+        for _, stage := range stages {
+            if _, ok := req.Transitions[stage]; !ok {
+                return fmt.Errorf("%w: applications are still in stage %q", ErrInvalidPipeline, stage)
+            }
+        }
+
+        query := `INSERT INTO job_pipelines (job_ref, transitions) VALUES ($1, $2)
+            ON CONFLICT (job_ref) DO UPDATE SET transitions = EXCLUDED.transitions, updated_at = CURRENT_TIMESTAMP`
+        _, err = tx.ExecContext(ctx, query, jobRef, transitionsJSON)
+        return err
+    })
+    if err != nil {
+        return err
+    }
+
+    req.JobID = jobID
+    return nil
+}
+
+// TransitionApplication moves an application to req.ToStage if the job's pipeline
+// allows it, recording who made the move.
+func TransitionApplication(ctx context.Context, jobID string, applicationID int, req *models.StageTransition) error {
+    db := database.GetDB()
+    userID := ctx.Value("userID")
+
+    jobRef, err := getJobRef(ctx, jobID)
+    if err != nil {
+        return err
+    }
+
+    return database.WithTx(ctx, db, func(tx *sqlx.Tx) error {
+        // A share lock is enough: it conflicts with the FOR UPDATE of SetPipeline, so the
+        // pipeline cannot change under this move, yet lets moves of other applications
+        // of the job run at the same time. Moves of the same application are ordered by
+        // the application's own lock below.
+        if _, err := tx.ExecContext(ctx, "SELECT id FROM jobs WHERE id = $1 FOR SHARE", jobRef); err != nil {
+            return err
+        }
+
+        // Lock the application so concurrent moves see each other's result
+        var current string
+        err := tx.QueryRowContext(ctx,
+            "SELECT stage FROM applications WHERE id = $1 AND job_ref = $2 FOR UPDATE",
+            applicationID, jobRef).Scan(&current)
+        if err == sql.ErrNoRows {
+            return ErrApplicationDoesNotExist
+        }
+        if err != nil {
+            return err
+        }
+
+        transitions, err := getTransitions(ctx, tx, jobRef)
+        if err != nil {
+            return err
+        }
+        if err := checkTransition(transitions, current, req.ToStage); err != nil {
+            return err
+        }
+
+        _, err = tx.ExecContext(ctx,
+            "UPDATE applications SET stage = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+            req.ToStage, applicationID)
+        if err != nil {
+            return err
+        }
+
+        query := `INSERT INTO application_transitions (application_id, from_stage, to_stage, actor_user_id, note)
+            VALUES ($1, $2, $3, $4, $5)
+            RETURNING id, actor_user_id, created_at`
+        err = tx.QueryRowContext(ctx, query, applicationID, current, req.ToStage, userID, req.Note).Scan(
+            &req.ID,
+            &req.ActorUserID,
+            &req.CreatedAt)
+        if err != nil {
+            return err
+        }
+
+        req.ApplicationID = applicationID
+        req.FromStage = current
+        return nil
+    })
+}
+
+// ListTransitions returns the stage history of an application, oldest first.
+func ListTransitions(ctx context.Context, jobID string, applicationID int) ([]*models.StageTransition, error) {
+    db := database.GetDB()
+
+    jobRef, err := getJobRef(ctx, jobID)
+    if err != nil {
+        return nil, err
+    }
+
+    var exists bool
+    err = db.GetContext(ctx, &exists,
+        "SELECT EXISTS(SELECT 1 FROM applications WHERE id = $1 AND job_ref = $2)", applicationID, jobRef)
+    if err != nil {
+        return nil, err
+    }
+    if !exists {
+        return nil, ErrApplicationDoesNotExist
+    }
+
+    transitions := []*models.StageTransition{}
+    query := `SELECT id, application_id, from_stage, to_stage, actor_user_id, COALESCE(note, '') AS note, created_at
+             FROM application_transitions WHERE application_id = $1 ORDER BY created_at, id`
+    if err := db.SelectContext(ctx, &transitions, query, applicationID); err != nil {
+        return nil, err
+    }
+
+    return transitions, nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+    "backend/internal/models"
+    "errors"
+    "testing"
+)
+
+func TestValidatePipeline(t *testing.T) {
+    if err := validatePipeline(DefaultPipeline()); err != nil {
+        t.Fatalf("default pipeline: %v", err)
+    }
+
+    for name, transitions := range map[string]map[string][]string{
+        "no applied stage": {
+            models.StageScreening: {models.StageHired},
+            models.StageHired:     {},
+        },
+        "unknown stage": {
+            models.StageApplied: {"onsite"},
+            "onsite":            {},
+        },
+        "target not in pipeline": {
+            models.StageApplied: {models.StageHired},
+        },
+        "move to itself": {
+            models.StageApplied: {models.StageApplied},
+        },
+        "duplicate target": {
+            models.StageApplied: {models.StageHired, models.StageHired},
+            models.StageHired:   {},
+        },
+    } {
+        if err := validatePipeline(transitions); !errors.Is(err, ErrInvalidPipeline) {
+            t.Fatalf("%s: got %v, want ErrInvalidPipeline", name, err)
+        }
+    }
+}
+
+func TestCheckTransition(t *testing.T) {
+    transitions := DefaultPipeline()
+
+    if err := checkTransition(transitions, models.StageApplied, models.StageScreening); err != nil {
+        t.Fatal(err)
+    }
+    for _, tc := range []struct{ from, to string }{
+        {models.StageApplied, models.StageHired},     // skipping stages
+        {models.StageScreening, models.StageApplied}, // going back
+        {models.StageHired, models.StageRejected},    // out of a final stage
+        {models.StageApplied, models.StageApplied},   // staying
+        {"onsite", models.StageScreening},            // from a stage the pipeline dropped
+        {models.StageApplied, "onsite"},              // to an unknown stage
+    } {
+        if err := checkTransition(transitions, tc.from, tc.to); !errors.Is(err, ErrIllegalTransition) {
+            t.Fatalf("%s to %s: got %v, want ErrIllegalTransition", tc.from, tc.to, err)
+        }
+    }
+}
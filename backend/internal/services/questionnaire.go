@@ -0,0 +1,196 @@
+package services
+
+import (
+    "backend/internal/database"
+    "backend/internal/models"
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+)
+
+var (
+    ErrQuestionnaireExists       = errors.New("questionnaire already exists for this job")
+    ErrQuestionnaireDoesNotExist = errors.New("questionnaire does not exist for this job")
+    ErrInvalidQuestionnaire      = errors.New("invalid questionnaire")
+)
+
+// getJobRef resolves an organization's job_id to the internal jobs.id used by foreign keys.
+func getJobRef(ctx context.Context, jobID string) (int, error) {
+    return jobService.jobRef(ctx, jobID)
+}
+
+// validateQuestions checks that question ids are unique and that options match the question type.
+func validateQuestions(questions []models.Question) error {
+    seen := make(map[string]bool, len(questions))
+    for _, q := range questions {
+        if seen[q.ID] {
+            return fmt.Errorf("%w: duplicate question id %q", ErrInvalidQuestionnaire, q.ID)
+        }
+        seen[q.ID] = true
+
+        switch q.Type {
+        case models.QuestionTypeRadio, models.QuestionTypeCheckbox:
+            if len(q.Options) == 0 {
+                return fmt.Errorf("%w: question %q needs at least one option", ErrInvalidQuestionnaire, q.ID)
+            }
+        case models.QuestionTypeText, models.QuestionTypeFile:
+            if len(q.Options) > 0 {
+                return fmt.Errorf("%w: question %q of type %s cannot have options", ErrInvalidQuestionnaire, q.ID, q.Type)
+            }
+        default:
+            return fmt.Errorf("%w: question %q has unknown type %q", ErrInvalidQuestionnaire, q.ID, q.Type)
+        }
+    }
+    return nil
+}
+
+func CreateQuestionnaire(ctx context.Context, jobID string, req *models.Questionnaire) error {
+    db := database.GetDB()
+
+    if err := validateQuestions(req.Questions); err != nil {
+        return err
+    }
+
+    jobRef, err := getJobRef(ctx, jobID)
+    if err != nil {
+        return err
+    }
+
+    // Check if questionnaire already exists for this job
+    var exists bool
+    err = db.GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM questionnaires WHERE job_ref = $1)", jobRef)
+    if err != nil {
+        return err
+    }
+    if exists {
+        return ErrQuestionnaireExists
+    }
+
+    questionsJSON, err := json.Marshal(req.Questions)
+    if err != nil {
+        return err
+    }
+
+    query := `INSERT INTO questionnaires (job_ref, form_id, questions)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at, updated_at`
+    err = db.QueryRowContext(ctx, query, jobRef, req.FormID, questionsJSON).Scan(
+        &req.ID,
+        &req.CreatedAt,
+        &req.UpdatedAt)
+    if err != nil {
+        return err
+    }
+
+    req.JobID = jobID
+    return nil
+}
+
+func GetQuestionnaire(ctx context.Context, jobID string) (*models.Questionnaire, error) {
+    jobRef, err := getJobRef(ctx, jobID)
+    if err != nil {
+        return nil, err
+    }
+
+    questionnaire, err := getQuestionnaireByJobRef(ctx, jobRef)
+    if err != nil {
+        return nil, err
+    }
+
+    questionnaire.JobID = jobID
+    return questionnaire, nil
+}
+
+// getQuestionnaireByJobRef loads a questionnaire without any ownership check.
+func getQuestionnaireByJobRef(ctx context.Context, jobRef int) (*models.Questionnaire, error) {
+    db := database.GetDB()
+
+    var questionnaire models.Questionnaire
+    var questionsJSON []byte
+
+    query := `SELECT id, form_id, questions, created_at, updated_at
+             FROM questionnaires WHERE job_ref = $1`
+
+    err := db.QueryRowContext(ctx, query, jobRef).Scan(
+        &questionnaire.ID,
+        &questionnaire.FormID,
+        &questionsJSON,
+        &questionnaire.CreatedAt,
+        &questionnaire.UpdatedAt,
+    )
+    if err == sql.ErrNoRows {
+        return nil, ErrQuestionnaireDoesNotExist
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    if err := json.Unmarshal(questionsJSON, &questionnaire.Questions); err != nil {
+        return nil, err
+    }
+
+    return &questionnaire, nil
+}
+
+func UpdateQuestionnaire(ctx context.Context, jobID string, req *models.Questionnaire) error {
+    db := database.GetDB()
+
+    if err := validateQuestions(req.Questions); err != nil {
+        return err
+    }
+
+    jobRef, err := getJobRef(ctx, jobID)
+    if err != nil {
+        return err
+    }
+
+    questionsJSON, err := json.Marshal(req.Questions)
+    if err != nil {
+        return err
+    }
+
+    query := `UPDATE questionnaires SET
+        form_id = $1,
+        questions = $2,
+        updated_at = CURRENT_TIMESTAMP
+        WHERE job_ref = $3
+        RETURNING id, created_at, updated_at`
+    err = db.QueryRowContext(ctx, query, req.FormID, questionsJSON, jobRef).Scan(
+        &req.ID,
+        &req.CreatedAt,
+        &req.UpdatedAt)
+    if err == sql.ErrNoRows {
+        return ErrQuestionnaireDoesNotExist
+    }
+    if err != nil {
+        return err
+    }
+
+    req.JobID = jobID
+    return nil
+}
+
+func DeleteQuestionnaire(ctx context.Context, jobID string) error {
+    db := database.GetDB()
+
+    jobRef, err := getJobRef(ctx, jobID)
+    if err != nil {
+        return err
+    }
+
+    result, err := db.ExecContext(ctx, "DELETE FROM questionnaires WHERE job_ref = $1", jobRef)
+    if err != nil {
+        return err
+    }
+
+    affected, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if affected == 0 {
+        return ErrQuestionnaireDoesNotExist
+    }
+    return nil
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+    "backend/internal/repository"
+    "errors"
+    "time"
+)
+
+var ErrInvalidJobSearch = errors.New("invalid job search")
+
+// JobSearch combines filters over the organization's jobs; empty fields are ignored.
+type JobSearch struct {
+    Text          string                 // full text over title and description, websearch syntax
+    Statuses      []string               // any of these statuses
+    SkillsAny     []string               // requires at least one of these skills
+    SkillsAll     []string               // requires all of these skills
+    CreatedAfter  *time.Time
+    CreatedBefore *time.Time
+    Attributes    map[string]interface{} // attributes contain these key/value pairs
+}
+
+// filter validates the search and turns it into a repository filter.
+func (s JobSearch) filter() (repository.JobFilter, error) {
+    for _, status := range s.Statuses {
+        if err := validateJobStatus(status); err != nil {
+            return repository.JobFilter{}, err
+        }
+    }
+
+    return repository.JobFilter{
+        Text:          s.Text,
+        Statuses:      s.Statuses,
+        SkillsAny:     s.SkillsAny,
+        SkillsAll:     s.SkillsAll,
+        CreatedAfter:  s.CreatedAfter,
+        CreatedBefore: s.CreatedBefore,
+        Attributes:    s.Attributes,
+    }, nil
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+    "backend/internal/config"
+    "backend/internal/database"
+    "backend/internal/loginguard"
+    "backend/internal/oidc"
+    "backend/internal/repository"
+)
+
+// Init builds the job, auth, two-factor and single sign-on services on the Postgres
+// repositories. It needs the database, the login guard, the mailer and the
+// OpenID Connect provider, call it after their Init.
+func Init() {
+    db := database.GetDB()
+    users := repository.NewPostgresUsers(db)
+
+    SetJobService(NewJobService(repository.NewPostgresJobs(db), users))
+    SetAuthService(NewAuthService(users, postgresSessions{}, mailVerifier{}, loginguard.Get()))
+    SetMFAService(NewMFAService(repository.NewPostgresMFA(db), users, postgresSessions{}, loginguard.Get(),
+        config.GetConfig().Auth.TOTPIssuer))
+    passwordResetLimiter = loginguard.Get().Limiter("password_reset",
+        passwordResetsPerEmail, passwordResetsPerIP, passwordResetWindow)
+    SetSSOService(NewSSOService(oidc.Get(), config.GetConfig().OIDC.AllowedDomains,
+        repository.NewPostgresSSO(db), users, postgresSessions{}))
+}
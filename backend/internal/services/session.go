@@ -0,0 +1,235 @@
+package services
+
+import (
+    "backend/internal/config"
+    "backend/internal/database"
+    "backend/internal/models"
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "database/sql"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "time"
+
+    "github.com/jmoiron/sqlx"
+)
+
+var (
+    ErrInvalidRefreshToken = errors.New("invalid refresh token")
+    ErrRefreshTokenReused  = errors.New("refresh token was already used, session revoked")
+    ErrSessionDoesNotExist = errors.New("session does not exist for this user")
+)
+
+// SessionMeta describes the client a session was opened from.
+type SessionMeta struct {
+    UserAgent string
+    IPAddress string
+}
+
+type RefreshRequest struct {
+    RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenPair is what a client holds for a session: a short lived access token for
+// API calls and a single use refresh token to get the next pair.
+type TokenPair struct {
+    Token        string `json:"token"`
+    RefreshToken string `json:"refresh_token"`
+    ExpiresIn    int    `json:"expires_in"` // seconds until token expires
+}
+
+// newOpaqueToken returns a random token for clients and the hash to store for it.
+func newOpaqueToken() (token string, hash string, err error) {
+    random := make([]byte, 32)
+    if _, err := rand.Read(random); err != nil {
+        return "", "", err
+    }
+    token = base64.RawURLEncoding.EncodeToString(random)
+    return token, hashToken(token), nil
+}
+
+// hashToken is how opaque tokens are stored, so a database leak does not leak them.
+func hashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
+
+// issueTokens signs an access token for the session and stores a new refresh token in it.
+func issueTokens(ctx context.Context, q sqlExecer, userID int, sessionID string) (*TokenPair, error) {
+    cfg := config.GetConfig().Auth
+
+    refreshToken, refreshHash, err := newOpaqueToken()
+    if err != nil {
+        return nil, err
+    }
+
+    _, err = q.ExecContext(ctx,
+        "INSERT INTO refresh_tokens (session_id, token_hash, expires_at) VALUES ($1, $2, $3)",
+        sessionID, refreshHash, time.Now().Add(cfg.RefreshTokenTTL))
+    if err != nil {
+        return nil, err
+    }
+
+    token, err := generateToken(userID, sessionID)
+    if err != nil {
+        return nil, err
+    }
+
+    return &TokenPair{
+        Token:        token,
+        RefreshToken: refreshToken,
+        ExpiresIn:    int(cfg.AccessTokenTTL.Seconds()),
+    }, nil
+}
+
+// sqlExecer is satisfied by both *sqlx.DB and *sqlx.Tx.
+type sqlExecer interface {
+    ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// startSession opens a session for a user who just proved who they are.
+func startSession(ctx context.Context, userID int, meta SessionMeta) (*TokenPair, error) {
+    var tokens *TokenPair
+    err := database.WithTx(ctx, database.GetDB(), func(tx *sqlx.Tx) error {
+        var sessionID string
+        err := tx.GetContext(ctx, &sessionID,
+            `INSERT INTO sessions (user_id, user_agent, ip_address, expires_at)
+             VALUES ($1, $2, $3, $4) RETURNING id`,
+            userID, meta.UserAgent, meta.IPAddress, time.Now().Add(config.GetConfig().Auth.RefreshTokenTTL))
+        if err != nil {
+            return err
+        }
+
+        tokens, err = issueTokens(ctx, tx, userID, sessionID)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    return tokens, nil
+}
+
+// RefreshSession trades a refresh token for a new token pair. Each refresh token
+// works once; presenting a used one means it was stolen, so the whole session
+// (every token descended from the same login) is revoked. Refreshing does not
+// extend the session: it ends at the expires_at set when the user logged in.
+func RefreshSession(ctx context.Context, req *RefreshRequest) (*TokenPair, error) {
+    var tokens *TokenPair
+    reused := false
+    err := database.WithTx(ctx, database.GetDB(), func(tx *sqlx.Tx) error {
+        var token struct {
+            ID               int          `db:"id"`
+            SessionID        string       `db:"session_id"`
+            UserID           int          `db:"user_id"`
+            ExpiresAt        time.Time    `db:"expires_at"`
+            UsedAt           sql.NullTime `db:"used_at"`
+            RevokedAt        sql.NullTime `db:"revoked_at"`
+            SessionExpiresAt time.Time    `db:"session_expires_at"`
+        }
+        err := tx.GetContext(ctx, &token,
+            `SELECT rt.id, rt.session_id, s.user_id, rt.expires_at, rt.used_at, s.revoked_at,
+                    s.expires_at AS session_expires_at
+             FROM refresh_tokens rt JOIN sessions s ON s.id = rt.session_id
+             WHERE rt.token_hash = $1
+             FOR UPDATE`, hashToken(req.RefreshToken))
+        if err == sql.ErrNoRows {
+            return ErrInvalidRefreshToken
+        }
+        if err != nil {
+            return err
+        }
+
+        now := time.Now()
+        if token.RevokedAt.Valid || now.After(token.ExpiresAt) || now.After(token.SessionExpiresAt) {
+            return ErrInvalidRefreshToken
+        }
+
+        // The revocation has to commit, so this is not returned as an error
+        if token.UsedAt.Valid {
+            reused = true
+            _, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE id = $1", token.SessionID)
+            return err
+        }
+
+        if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1", token.ID); err != nil {
+            return err
+        }
+        if _, err := tx.ExecContext(ctx, "UPDATE sessions SET last_used_at = NOW() WHERE id = $1", token.SessionID); err != nil {
+            return err
+        }
+
+        tokens, err = issueTokens(ctx, tx, token.UserID, token.SessionID)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    if reused {
+        return nil, ErrRefreshTokenReused
+    }
+    return tokens, nil
+}
+
+// SessionActive reports whether an access token's session may still be used.
+func SessionActive(ctx context.Context, userID int, sessionID string) (bool, error) {
+    db := database.GetDB()
+
+    var active bool
+    err := db.GetContext(ctx, &active,
+        `SELECT EXISTS(SELECT 1 FROM sessions
+         WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW())`,
+        sessionID, userID)
+    return active, err
+}
+
+// Logout revokes the session of the current access token.
+func Logout(ctx context.Context) error {
+    sessionID, _ := ctx.Value("sessionID").(string)
+    return RevokeSession(ctx, sessionID)
+}
+
+// ListSessions returns the caller's active sessions, most recently used first.
+func ListSessions(ctx context.Context) ([]*models.Session, error) {
+    db := database.GetDB()
+    userID := ctx.Value("userID")
+    current, _ := ctx.Value("sessionID").(string)
+
+    sessions := []*models.Session{}
+    err := db.SelectContext(ctx, &sessions,
+        `SELECT id, user_agent, ip_address, created_at, last_used_at, expires_at
+         FROM sessions
+         WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+         ORDER BY last_used_at DESC`, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    for _, session := range sessions {
+        session.Current = session.ID == current
+    }
+    return sessions, nil
+}
+
+// RevokeSession ends one of the caller's sessions; its tokens stop working immediately.
+func RevokeSession(ctx context.Context, sessionID string) error {
+    db := database.GetDB()
+    userID := ctx.Value("userID")
+
+    result, err := db.ExecContext(ctx,
+        `UPDATE sessions SET revoked_at = NOW()
+         WHERE id::text = $1 AND user_id = $2 AND revoked_at IS NULL`, sessionID, userID)
+    if err != nil {
+        return err
+    }
+
+    affected, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if affected == 0 {
+        return ErrSessionDoesNotExist
+    }
+    return nil
+}
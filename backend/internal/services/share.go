@@ -0,0 +1,141 @@
+package services
+
+import (
+    "backend/internal/database"
+    "backend/internal/models"
+    "context"
+    "crypto/rand"
+    "database/sql"
+    "encoding/base64"
+    "errors"
+    "github.com/lib/pq"
+)
+
+var (
+    ErrShareLinkExists       = errors.New("job already has a share link")
+    ErrShareLinkDoesNotExist = errors.New("job does not have a share link")
+)
+
+// newShareToken returns an unguessable, URL safe token.
+func newShareToken() (string, error) {
+    random := make([]byte, 32)
+    if _, err := rand.Read(random); err != nil {
+        return "", err
+    }
+    return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+func GetShareLink(ctx context.Context, jobID string) (*models.ShareLink, error) {
+    db := database.GetDB()
+    orgID := ctx.Value("orgID")
+
+    var token, createdAt sql.NullString
+    err := db.QueryRowContext(ctx,
+        "SELECT share_token, share_token_created_at FROM jobs WHERE job_id = $1 AND org_id = $2",
+        jobID, orgID).Scan(&token, &createdAt)
+    if err == sql.ErrNoRows {
+        return nil, ErrJobDoesNotExist
+    }
+    if err != nil {
+        return nil, err
+    }
+    if !token.Valid {
+        return nil, ErrShareLinkDoesNotExist
+    }
+
+    return &models.ShareLink{JobID: jobID, ShareToken: token.String, CreatedAt: createdAt.String}, nil
+}
+
+// CreateShareLink gives a job a share token. With rotate set an existing token is
+// replaced, otherwise ErrShareLinkExists is returned.
+func CreateShareLink(ctx context.Context, jobID string, rotate bool) (*models.ShareLink, error) {
+    db := database.GetDB()
+    orgID := ctx.Value("orgID")
+
+    token, err := newShareToken()
+    if err != nil {
+        return nil, err
+    }
+
+    query := `UPDATE jobs SET share_token = $1, share_token_created_at = CURRENT_TIMESTAMP
+        WHERE job_id = $2 AND org_id = $3 AND (share_token IS NULL) = $4
+        RETURNING share_token_created_at`
+
+    link := models.ShareLink{JobID: jobID, ShareToken: token}
+    err = db.QueryRowContext(ctx, query, token, jobID, orgID, !rotate).Scan(&link.CreatedAt)
+    if err == sql.ErrNoRows {
+        // Either the job does not exist or its token is not in the expected state
+        if _, err := GetShareLink(ctx, jobID); err != nil {
+            return nil, err
+        }
+        return nil, ErrShareLinkExists
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    return &link, nil
+}
+
+// RevokeShareLink removes a job's share token, breaking links already posted.
+func RevokeShareLink(ctx context.Context, jobID string) error {
+    db := database.GetDB()
+    orgID := ctx.Value("orgID")
+
+    result, err := db.ExecContext(ctx,
+        `UPDATE jobs SET share_token = NULL, share_token_created_at = NULL
+        WHERE job_id = $1 AND org_id = $2 AND share_token IS NOT NULL`, jobID, orgID)
+    if err != nil {
+        return err
+    }
+
+    affected, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if affected == 0 {
+        if _, err := getJobRef(ctx, jobID); err != nil {
+            return err
+        }
+        return ErrShareLinkDoesNotExist
+    }
+    return nil
+}
+
+// GetPublicJob returns the candidate view of an active job by its share token.
+func GetPublicJob(ctx context.Context, shareToken string) (*models.PublicJob, error) {
+    db := database.GetDB()
+
+    var job models.PublicJob
+    var jobRef int
+    var skillsRequired pq.StringArray
+
+    query := `SELECT id, job_title, job_description, skills_required, expires_at
+             FROM jobs WHERE share_token = $1 AND job_status = $2`
+    err := db.QueryRowContext(ctx, query, shareToken, models.JobStatusActive).Scan(
+        &jobRef,
+        &job.JobTitle,
+        &job.JobDescription,
+        &skillsRequired,
+        &job.ExpiresAt,
+    )
+    if err == sql.ErrNoRows {
+        return nil, ErrJobDoesNotExist
+    }
+    if err != nil {
+        return nil, err
+    }
+    job.SkillsRequired = []string(skillsRequired)
+
+    questionnaire, err := getQuestionnaireByJobRef(ctx, jobRef)
+    switch err {
+    case nil:
+        job.Questions = questionnaire.Questions
+    case ErrQuestionnaireDoesNotExist:
+        job.Questions = []models.Question{}
+    default:
+        return nil, err
+    }
+
+    return &job, nil
+}
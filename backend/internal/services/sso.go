@@ -0,0 +1,223 @@
+package services
+
+import (
+    "backend/internal/models"
+    "backend/internal/oidc"
+    "backend/internal/repository"
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+)
+
+const ssoLoginTTL = 10 * time.Minute
+
+var (
+    ErrSSODisabled         = errors.New("single sign-on is not configured")
+    ErrInvalidSSOState     = errors.New("invalid or expired single sign-on login, start again")
+    ErrSSOEmailNotVerified = errors.New("identity provider did not confirm an email address")
+    ErrSSODomainNotAllowed = errors.New("email domain is not allowed to log in")
+)
+
+// SSOStart is where the frontend sends the user to log in at the provider.
+type SSOStart struct {
+    AuthorizationURL string `json:"authorization_url"`
+    // Binding goes to the browser in a cookie only; the callback must come with it
+    Binding string `json:"-"`
+}
+
+// SSOCallbackRequest carries what the provider redirected back to the frontend with.
+type SSOCallbackRequest struct {
+    Code    string      `json:"code" binding:"required"`
+    State   string      `json:"state" binding:"required"`
+    Binding string      `json:"-"` // from the cookie set by StartSSOLogin
+    Meta    SessionMeta `json:"-"`
+}
+
+// SSOService logs users in through an OpenID Connect provider.
+type SSOService struct {
+    provider       *oidc.Provider // nil turns single sign-on off
+    allowedDomains []string       // email domains that may log in, empty allows any
+    sso            repository.SSORepository
+    users          repository.UserRepository
+    sessions       LoginSessions
+}
+
+func NewSSOService(provider *oidc.Provider, allowedDomains []string, sso repository.SSORepository,
+    users repository.UserRepository, sessions LoginSessions) *SSOService {
+    return &SSOService{provider: provider, allowedDomains: allowedDomains, sso: sso, users: users, sessions: sessions}
+}
+
+// Start begins an authorization code login with PKCE. The state, nonce and code
+// verifier stay on the server until Complete, which only accepts the state from
+// the browser holding the returned binding. Without it, anyone could send a
+// victim to the callback with a code for the attacker's account (login CSRF).
+func (s *SSOService) Start(ctx context.Context) (*SSOStart, error) {
+    if s.provider == nil {
+        return nil, ErrSSODisabled
+    }
+
+    state, stateHash, err := newOpaqueToken()
+    if err != nil {
+        return nil, err
+    }
+    binding, bindingHash, err := newOpaqueToken()
+    if err != nil {
+        return nil, err
+    }
+    nonce, err := oidc.NewVerifier()
+    if err != nil {
+        return nil, err
+    }
+    verifier, err := oidc.NewVerifier()
+    if err != nil {
+        return nil, err
+    }
+
+    authURL, err := s.provider.AuthCodeURL(ctx, state, nonce, verifier)
+    if err != nil {
+        return nil, err
+    }
+
+    err = s.sso.SaveLogin(ctx, &repository.SSOLogin{
+        StateHash:    stateHash,
+        BindingHash:  bindingHash,
+        Nonce:        nonce,
+        CodeVerifier: verifier,
+        ExpiresAt:    time.Now().Add(ssoLoginTTL),
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return &SSOStart{AuthorizationURL: authURL, Binding: binding}, nil
+}
+
+// Complete exchanges the code, finds or creates the user of the ID token and
+// opens a session. Like Login, a user with two-factor authentication enabled
+// gets a challenge instead of tokens.
+func (s *SSOService) Complete(ctx context.Context, req *SSOCallbackRequest) (*AuthResponse, *MFAChallenge, error) {
+    if s.provider == nil {
+        return nil, nil, ErrSSODisabled
+    }
+    if req.Binding == "" {
+        return nil, nil, ErrInvalidSSOState
+    }
+
+    // Each state works once, so a code cannot be replayed through this endpoint
+    login, err := s.sso.ConsumeLogin(ctx, hashToken(req.State), hashToken(req.Binding))
+    if errors.Is(err, repository.ErrNotFound) {
+        return nil, nil, ErrInvalidSSOState
+    }
+    if err != nil {
+        return nil, nil, err
+    }
+
+    claims, err := s.provider.Exchange(ctx, req.Code, login.CodeVerifier, login.Nonce)
+    if err != nil {
+        return nil, nil, err
+    }
+    if err := s.checkDomain(claims.Email); err != nil {
+        return nil, nil, err
+    }
+
+    userID, err := s.linkedUser(ctx, claims)
+    if err != nil {
+        return nil, nil, err
+    }
+    user, err := s.users.GetByID(ctx, userID)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    if user.TOTPEnabledAt != nil {
+        challenge, err := s.sessions.Challenge(ctx, user.ID)
+        return nil, challenge, err
+    }
+
+    tokens, err := s.sessions.Start(ctx, user.ID, req.Meta)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    return &AuthResponse{
+        TokenPair: *tokens,
+        User: models.User{
+            Username:        user.Username,
+            Email:           user.Email,
+            EmailVerifiedAt: user.EmailVerifiedAt,
+        },
+    }, nil, nil
+}
+
+// checkDomain applies the OIDC_ALLOWED_DOMAINS allow-list to an email.
+func (s *SSOService) checkDomain(email string) error {
+    if len(s.allowedDomains) == 0 {
+        return nil
+    }
+
+    at := strings.LastIndex(email, "@")
+    if at < 0 {
+        return ErrSSODomainNotAllowed
+    }
+    domain := strings.ToLower(email[at+1:])
+    for _, d := range s.allowedDomains {
+        if strings.EqualFold(strings.TrimSpace(d), domain) {
+            return nil
+        }
+    }
+    return fmt.Errorf("%w: %s", ErrSSODomainNotAllowed, domain)
+}
+
+// linkedUser returns the user linked to the provider account of claims. An
+// unlinked account is linked to the user with its email, or a new user is
+// created, only when the provider vouches for the email.
+func (s *SSOService) linkedUser(ctx context.Context, claims *oidc.Claims) (int, error) {
+    userID, err := s.sso.LinkedUser(ctx, claims.Issuer, claims.Subject)
+    if !errors.Is(err, repository.ErrNotFound) {
+        return userID, err
+    }
+
+    if claims.Email == "" || !claims.EmailVerified {
+        return 0, ErrSSOEmailNotVerified
+    }
+
+    username := claims.PreferredUsername
+    if username == "" {
+        username = strings.Split(claims.Email, "@")[0]
+    }
+    userID, err = s.sso.Link(ctx, repository.Identity{
+        Issuer:   claims.Issuer,
+        Subject:  claims.Subject,
+        Email:    claims.Email,
+        Username: username,
+    })
+    if errors.Is(err, repository.ErrDuplicate) {
+        // A concurrent first login of the same account linked it first
+        return s.sso.LinkedUser(ctx, claims.Issuer, claims.Subject)
+    }
+    if err != nil {
+        return 0, err
+    }
+
+    log.Printf("Linked user %d to single sign-on subject %s", userID, claims.Subject)
+    return userID, nil
+}
+
+var ssoService *SSOService
+
+// SetSSOService replaces the service behind StartSSOLogin and CompleteSSOLogin,
+// e.g. with one for an oidctest.Issuer in tests. Init sets the configured one.
+func SetSSOService(s *SSOService) {
+    ssoService = s
+}
+
+func StartSSOLogin(ctx context.Context) (*SSOStart, error) {
+    return ssoService.Start(ctx)
+}
+
+func CompleteSSOLogin(ctx context.Context, req *SSOCallbackRequest) (*AuthResponse, *MFAChallenge, error) {
+    return ssoService.Complete(ctx, req)
+}
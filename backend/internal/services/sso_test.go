@@ -0,0 +1,247 @@
+package services
+
+import (
+    "backend/internal/models"
+    "backend/internal/oidc"
+    "backend/internal/oidc/oidctest"
+    "backend/internal/repository"
+    "context"
+    "errors"
+    "net/url"
+    "testing"
+    "time"
+)
+
+type testSSO struct {
+    service  *SSOService
+    issuer   *oidctest.Issuer
+    users    *repository.MemoryUsers
+    sso      *repository.MemorySSO
+    sessions *fakeSessions
+}
+
+// newTestSSOService returns a single sign-on service on memory repositories, logging
+// in through a local mock issuer that logs in ada@example.com.
+func newTestSSOService(t *testing.T, allowedDomains ...string) *testSSO {
+    issuer, err := oidctest.NewIssuer("hiring", "client-secret", oidctest.User{
+        Subject:           "ada-1",
+        Email:             "ada@example.com",
+        EmailVerified:     true,
+        PreferredUsername: "ada",
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(issuer.Close)
+
+    a := &testSSO{issuer: issuer, users: repository.NewMemoryUsers(), sessions: &fakeSessions{}}
+    a.sso = repository.NewMemorySSO(a.users)
+    provider := issuer.Provider("http://localhost:5173/sso/callback")
+    a.service = NewSSOService(provider, allowedDomains, a.sso, a.users, a.sessions)
+    return a
+}
+
+// login runs a whole login like the browser and frontend would: start, log in at
+// the provider, then post the code and state back with the binding cookie.
+// tamper may change the authorization URL before the browser follows it.
+func (a *testSSO) login(t *testing.T, tamper func(query url.Values)) (*AuthResponse, *MFAChallenge, error) {
+    ctx := context.Background()
+    start, err := a.service.Start(ctx)
+    if err != nil {
+        t.Fatalf("Start: %v", err)
+    }
+
+    authURL := start.AuthorizationURL
+    if tamper != nil {
+        u, _ := url.Parse(authURL)
+        query := u.Query()
+        tamper(query)
+        u.RawQuery = query.Encode()
+        authURL = u.String()
+    }
+    code, state, err := a.issuer.Authorize(authURL)
+    if err != nil {
+        t.Fatalf("Authorize: %v", err)
+    }
+
+    return a.service.Complete(ctx, &SSOCallbackRequest{Code: code, State: state, Binding: start.Binding})
+}
+
+func TestSSOProvisionsUser(t *testing.T) {
+    a := newTestSSOService(t)
+
+    resp, challenge, err := a.login(t, nil)
+    if err != nil || challenge != nil {
+        t.Fatalf("login: %v, %+v", err, challenge)
+    }
+    if resp.Token == "" || resp.User.Username != "ada" || resp.User.EmailVerifiedAt == nil {
+        t.Fatalf("response: %+v", resp)
+    }
+
+    user, err := a.users.GetByEmail(context.Background(), "ada@example.com")
+    if err != nil {
+        t.Fatalf("user was not created: %v", err)
+    }
+    if user.PasswordHash != "" || user.EmailVerifiedAt == nil {
+        t.Fatalf("created user: %+v", user)
+    }
+    if orgs := a.users.Organizations(user.ID); len(orgs) != 1 || orgs[0].Name != "ada" {
+        t.Fatalf("organizations of the new user: %+v", orgs)
+    }
+
+    // The next login finds the linked user instead of creating another
+    if _, _, err := a.login(t, nil); err != nil {
+        t.Fatalf("second login: %v", err)
+    }
+    if len(a.sessions.started) != 2 || a.sessions.started[0] != a.sessions.started[1] {
+        t.Fatalf("sessions were started for %v", a.sessions.started)
+    }
+}
+
+func TestSSOPicksFreeUsername(t *testing.T) {
+    a := newTestSSOService(t)
+    a.users.Put(&models.User{ID: 1, Username: "ada", Email: "someone-else@example.com"})
+
+    resp, _, err := a.login(t, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if resp.User.Username != "ada-2" {
+        t.Fatalf("username %q, want ada-2", resp.User.Username)
+    }
+}
+
+func TestSSOTakesOverUnverifiedUser(t *testing.T) {
+    a := newTestSSOService(t)
+    enabledAt := time.Now()
+    a.users.Put(&models.User{ID: 5, Username: "squatter", Email: "ADA@example.com",
+        PasswordHash: "hash set by whoever registered", TOTPEnabledAt: &enabledAt})
+
+    if _, _, err := a.login(t, nil); err != nil {
+        t.Fatal(err)
+    }
+    user, _ := a.users.GetByID(context.Background(), 5)
+    if user.PasswordHash != "" || user.TOTPEnabledAt != nil || user.EmailVerifiedAt == nil {
+        t.Fatalf("unverified user kept what someone else set up: %+v", user)
+    }
+    if a.sessions.started[0] != 5 {
+        t.Fatalf("logged in as user %d, want 5", a.sessions.started[0])
+    }
+}
+
+func TestSSOKeepsVerifiedUser(t *testing.T) {
+    a := newTestSSOService(t)
+    verifiedAt := time.Now()
+    enabledAt := time.Now()
+    a.users.Put(&models.User{ID: 5, Username: "ada", Email: "ada@example.com",
+        PasswordHash: "own hash", EmailVerifiedAt: &verifiedAt, TOTPEnabledAt: &enabledAt})
+
+    // Their two-factor setup stays, so they get a challenge
+    resp, challenge, err := a.login(t, nil)
+    if err != nil || resp != nil || challenge == nil {
+        t.Fatalf("login: %+v, %+v, %v", resp, challenge, err)
+    }
+    user, _ := a.users.GetByID(context.Background(), 5)
+    if user.PasswordHash != "own hash" {
+        t.Fatal("the password of a verified user was removed")
+    }
+}
+
+func TestSSORejectsUnverifiedProviderEmail(t *testing.T) {
+    a := newTestSSOService(t)
+    a.issuer.User.EmailVerified = false
+
+    if _, _, err := a.login(t, nil); !errors.Is(err, ErrSSOEmailNotVerified) {
+        t.Fatalf("got %v, want ErrSSOEmailNotVerified", err)
+    }
+}
+
+func TestSSODomainAllowList(t *testing.T) {
+    a := newTestSSOService(t, "example.org", " Example.com ")
+    if _, _, err := a.login(t, nil); err != nil {
+        t.Fatalf("allowed domain: %v", err)
+    }
+
+    a = newTestSSOService(t, "example.org")
+    if _, _, err := a.login(t, nil); !errors.Is(err, ErrSSODomainNotAllowed) {
+        t.Fatalf("other domain: got %v, want ErrSSODomainNotAllowed", err)
+    }
+}
+
+func TestSSOStateIsBoundToBrowser(t *testing.T) {
+    a := newTestSSOService(t)
+    ctx := context.Background()
+
+    start, err := a.service.Start(ctx)
+    if err != nil {
+        t.Fatal(err)
+    }
+    code, state, err := a.issuer.Authorize(start.AuthorizationURL)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Another browser's binding, or none, does not complete this login
+    other, _ := a.service.Start(ctx)
+    for _, binding := range []string{"", other.Binding} {
+        _, _, err := a.service.Complete(ctx, &SSOCallbackRequest{Code: code, State: state, Binding: binding})
+        if !errors.Is(err, ErrInvalidSSOState) {
+            t.Fatalf("binding %q: got %v, want ErrInvalidSSOState", binding, err)
+        }
+    }
+
+    req := &SSOCallbackRequest{Code: code, State: state, Binding: start.Binding}
+    if _, _, err := a.service.Complete(ctx, req); err != nil {
+        t.Fatalf("own binding: %v", err)
+    }
+    if _, _, err := a.service.Complete(ctx, req); !errors.Is(err, ErrInvalidSSOState) {
+        t.Fatalf("state used twice: got %v, want ErrInvalidSSOState", err)
+    }
+}
+
+func TestSSOChecksPKCEAndNonce(t *testing.T) {
+    a := newTestSSOService(t)
+
+    // The provider only hands out tokens for the verifier of the challenge it saw
+    _, _, err := a.login(t, func(query url.Values) {
+        query.Set("code_challenge", oidc.Challenge("verifier of an attacker"))
+    })
+    if !errors.Is(err, oidc.ErrExchange) {
+        t.Fatalf("other code challenge: got %v, want ErrExchange", err)
+    }
+
+    // An ID token issued for another login's nonce is not accepted
+    _, _, err = a.login(t, func(query url.Values) {
+        query.Set("nonce", "nonce of another login")
+    })
+    if !errors.Is(err, oidc.ErrInvalidIDToken) {
+        t.Fatalf("other nonce: got %v, want ErrInvalidIDToken", err)
+    }
+
+    if users, _ := a.users.GetByEmail(context.Background(), "ada@example.com"); users != nil {
+        t.Fatal("a failed login created the user")
+    }
+}
+
+func TestSSOPurgesExpiredLogins(t *testing.T) {
+    a := newTestSSOService(t)
+    ctx := context.Background()
+
+    expired := &repository.SSOLogin{StateHash: "expired", BindingHash: "expired", ExpiresAt: time.Now().Add(-time.Second)}
+    if err := a.sso.SaveLogin(ctx, expired); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := a.service.Start(ctx); err != nil {
+        t.Fatal(err)
+    }
+    if n := a.sso.Logins(); n != 1 {
+        t.Fatalf("%d logins stored, want only the new one", n)
+    }
+}
+
+func TestSSODisabled(t *testing.T) {
+    s := NewSSOService(nil, nil, repository.NewMemorySSO(repository.NewMemoryUsers()), repository.NewMemoryUsers(), &fakeSessions{})
+    if _, err := s.Start(context.Background()); !errors.Is(err, ErrSSODisabled) {
+        t.Fatalf("got %v, want ErrSSODisabled", err)
+    }
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+    "backend/internal/config"
+    "backend/internal/database"
+    "backend/internal/models"
+    "backend/internal/storage"
+    "bytes"
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "strings"
+    "time"
+
+    "github.com/gabriel-vasile/mimetype"
+    "github.com/jmoiron/sqlx"
+)
+
+// uploadQuotaWindow is how far back uploads count against STORAGE_UPLOAD_QUOTA_*.
+const uploadQuotaWindow = 24 * time.Hour
+
+// uploadSweepBatch bounds how many files one SweepUploads call deletes.
+const uploadSweepBatch = 500
+
+var (
+    ErrFileTooLarge        = errors.New("file is too large")
+    ErrUnsupportedFileType = errors.New("unsupported file type")
+    ErrFileDoesNotExist    = errors.New("file does not exist for this application")
+    ErrUploadQuotaExceeded = errors.New("too many uploads for this job, try again later")
+)
+
+// allowedUploadTypes are the detected content types accepted for file questions.
+var allowedUploadTypes = map[string]bool{
+    "application/pdf":    true,
+    "application/msword": true,
+    "application/vnd.openxmlformats-officedocument.wordprocessingml.document": true,
+    "application/rtf": true,
+    "text/plain":      true,
+    "image/png":       true,
+    "image/jpeg":      true,
+}
+
+type UploadedFile struct {
+    Key         string `json:"key"`
+    ContentType string `json:"content_type"`
+    Size        int64  `json:"size"`
+}
+
+type FileLink struct {
+    URL       string    `json:"url"`
+    ExpiresAt time.Time `json:"expires_at"`
+}
+
+// uploadPrefix is the storage prefix for files uploaded to a job.
+func uploadPrefix(jobRef int) string {
+    return fmt.Sprintf("applications/%d/", jobRef)
+}
+
+// UploadApplicationFile stores a candidate's file for a job that accepts applications.
+// The returned key is what the candidate submits as the answer to a file question.
+// Uploads from ipAddress count against the job's upload quota.
+func UploadApplicationFile(ctx context.Context, shareToken, filename string, r io.Reader, size int64, ipAddress string) (*UploadedFile, error) {
+    if size > config.GetConfig().Storage.MaxUploadBytes {
+        return nil, ErrFileTooLarge
+    }
+
+    jobRef, status, err := getPublicJobRef(ctx, shareToken)
+    if err != nil {
+        return nil, err
+    }
+    if status != models.JobStatusActive {
+        return nil, ErrJobNotAcceptingApplications
+    }
+
+    // Sniff the content instead of trusting the client supplied type
+    head := make([]byte, 3072)
+    n, err := io.ReadFull(r, head)
+    if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+        return nil, err
+    }
+    head = head[:n]
+
+    detected := mimetype.Detect(head)
+    contentType := strings.Split(detected.String(), ";")[0]
+    if !allowedUploadTypes[contentType] {
+        return nil, fmt.Errorf("%w: %s", ErrUnsupportedFileType, contentType)
+    }
+
+    key, err := storage.NewKey(uploadPrefix(jobRef), filename)
+    if err != nil {
+        return nil, err
+    }
+
+    if err := reserveUpload(ctx, jobRef, key, size, ipAddress); err != nil {
+        return nil, err
+    }
+
+    body := io.MultiReader(bytes.NewReader(head), r)
+    if err := storage.Get().Put(ctx, key, body, size, contentType); err != nil {
+        // Give the quota back, the sweep would otherwise only drop the row after UploadTTL
+        if _, err := database.GetDB().ExecContext(context.Background(), "DELETE FROM uploads WHERE storage_key = $1", key); err != nil {
+            log.Printf("Could not release upload %s: %v", key, err)
+        }
+        return nil, err
+    }
+
+    return &UploadedFile{Key: key, ContentType: contentType, Size: size}, nil
+}
+
+// reserveUpload records an upload of size bytes from ipAddress, unless that address
+// used up its quota for the job. The job row lock keeps concurrent uploads from
+// passing the check together.
+func reserveUpload(ctx context.Context, jobRef int, key string, size int64, ipAddress string) error {
+    cfg := config.GetConfig().Storage
+
+    return database.WithTx(ctx, database.GetDB(), func(tx *sqlx.Tx) error {
+        if _, err := tx.ExecContext(ctx, "SELECT id FROM jobs WHERE id = $1 FOR UPDATE", jobRef); err != nil {
+            return err
+        }
+
+        var used struct {
+            Files int   `db:"files"`
+            Bytes int64 `db:"bytes"`
+        }
+        err := tx.GetContext(ctx, &used,
+            `SELECT COUNT(*) AS files, COALESCE(SUM(size), 0) AS bytes FROM uploads
+             WHERE job_ref = $1 AND ip_address = $2 AND created_at > $3`,
+            jobRef, ipAddress, time.Now().Add(-uploadQuotaWindow))
+        if err != nil {
+            return err
+        }
+        if used.Files >= cfg.UploadQuotaFiles || used.Bytes+size > cfg.UploadQuotaBytes {
+            return ErrUploadQuotaExceeded
+        }
+
+        _, err = tx.ExecContext(ctx,
+            "INSERT INTO uploads (job_ref, storage_key, size, ip_address) VALUES ($1, $2, $3, $4)",
+            jobRef, key, size, ipAddress)
+        return err
+    })
+}
+
+// SweepUploads deletes files older than STORAGE_UPLOAD_TTL that no application of
+// their job refers to, e.g. of candidates who never applied or of deleted jobs.
+// Each file is checked under the job row lock SubmitApplication also takes, so a
+// file cannot be deleted while an application referring to it is being stored.
+func SweepUploads(ctx context.Context) (deleted int, err error) {
+    db := database.GetDB()
+
+    var candidates []struct {
+        ID     int64 `db:"id"`
+        JobRef int   `db:"job_ref"`
+    }
+    err = db.SelectContext(ctx, &candidates,
+        "SELECT id, job_ref FROM uploads WHERE created_at < $1 ORDER BY id LIMIT $2",
+        time.Now().Add(-config.GetConfig().Storage.UploadTTL), uploadSweepBatch)
+    if err != nil {
+        return 0, err
+    }
+
+    for _, upload := range candidates {
+        removed := false
+        err := database.WithTx(ctx, db, func(tx *sqlx.Tx) error {
+            if _, err := tx.ExecContext(ctx, "SELECT id FROM jobs WHERE id = $1 FOR UPDATE", upload.JobRef); err != nil {
+                return err
+            }
+
+            var key string
+            err := tx.GetContext(ctx, &key,
+                `DELETE FROM uploads u WHERE id = $1 AND NOT EXISTS (
+                     SELECT 1 FROM applications a, jsonb_each_text(a.responses) r
+                     WHERE a.job_ref = u.job_ref AND r.value = u.storage_key)
+                 RETURNING storage_key`, upload.ID)
+            if err == sql.ErrNoRows {
+                return nil // an application refers to it, keep it
+            }
+            if err != nil {
+                return err
+            }
+
+            // A failed delete rolls the row back, so the next sweep tries again
+            if err := storage.Get().Delete(ctx, key); err != nil {
+                return err
+            }
+            removed = true
+            return nil
+        })
+        if err != nil {
+            return deleted, err
+        }
+        if removed {
+            deleted++
+        }
+    }
+    return deleted, nil
+}
+
+// RunUploadSweeper calls SweepUploads every interval until ctx is cancelled.
+func RunUploadSweeper(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        deleted, err := SweepUploads(ctx)
+        if err != nil {
+            log.Printf("Upload sweeper: %v", err)
+        } else if deleted > 0 {
+            log.Printf("Upload sweeper: deleted %d unreferenced files", deleted)
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// GetApplicationFileLink returns an expiring download link for a file answer of an
+// application to one of the organization's jobs.
+func GetApplicationFileLink(ctx context.Context, jobID string, applicationID int, questionID string) (*FileLink, error) {
+    db := database.GetDB()
+
+    jobRef, err := getJobRef(ctx, jobID)
+    if err != nil {
+        return nil, err
+    }
+
+    var responsesJSON []byte
+    err = db.GetContext(ctx, &responsesJSON,
+        "SELECT responses FROM applications WHERE id = $1 AND job_ref = $2", applicationID, jobRef)
+    if err == sql.ErrNoRows {
+        return nil, ErrApplicationDoesNotExist
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    var responses map[string]interface{}
+    if err := json.Unmarshal(responsesJSON, &responses); err != nil {
+        return nil, err
+    }
+
+    key, ok := responses[questionID].(string)
+    if !ok || !strings.HasPrefix(key, uploadPrefix(jobRef)) {
+        return nil, ErrFileDoesNotExist
+    }
+
+    expiry := config.GetConfig().Storage.URLExpiry
+    url, err := storage.Get().SignedURL(ctx, key, expiry)
+    if err != nil {
+        return nil, err
+    }
+
+    return &FileLink{URL: url, ExpiresAt: time.Now().Add(expiry).UTC()}, nil
+}
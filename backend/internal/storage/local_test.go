@@ -0,0 +1,110 @@
+package storage
+
+import (
+    "context"
+    "errors"
+    "io"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+)
+
+func newTestLocal(t *testing.T) (*Local, *time.Time) {
+    l, err := NewLocal(t.TempDir(), "signing secret")
+    if err != nil {
+        t.Fatal(err)
+    }
+    now := time.Unix(1700000000, 0)
+    l.now = func() time.Time { return now }
+    return l, &now
+}
+
+// link returns the expires and signature parameters of a SignedURL for key.
+func link(t *testing.T, l *Local, key string, expiry time.Duration) (string, string) {
+    signed, err := l.SignedURL(context.Background(), key, expiry)
+    if err != nil {
+        t.Fatal(err)
+    }
+    u, err := url.Parse(signed)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if u.Path != "/files/"+key {
+        t.Fatalf("link path %q", u.Path)
+    }
+    return u.Query().Get("expires"), u.Query().Get("signature")
+}
+
+func TestLocalSignedURL(t *testing.T) {
+    l, now := newTestLocal(t)
+    ctx := context.Background()
+    key := "applications/1/0123/cv.txt"
+    if err := l.Put(ctx, key, strings.NewReader("resume"), 6, "text/plain"); err != nil {
+        t.Fatal(err)
+    }
+
+    expires, signature := link(t, l, key, time.Minute)
+    f, err := l.Open(key, expires, signature)
+    if err != nil {
+        t.Fatalf("Open: %v", err)
+    }
+    content, _ := io.ReadAll(f)
+    f.Close()
+    if string(content) != "resume" {
+        t.Fatalf("content %q", content)
+    }
+
+    // The signature covers the key and the expiry
+    for name, open := range map[string]func() error{
+        "other key":      func() error { _, err := l.Open("applications/1/0123/other.txt", expires, signature); return err },
+        "later expiry":   func() error { _, err := l.Open(key, expires+"0", signature); return err },
+        "no signature":   func() error { _, err := l.Open(key, expires, ""); return err },
+        "invalid expiry": func() error { _, err := l.Open(key, "soon", signature); return err },
+        "other secret": func() error {
+            other, _ := NewLocal(t.TempDir(), "other secret")
+            other.now = l.now
+            _, err := other.Open(key, expires, signature)
+            return err
+        },
+    } {
+        if err := open(); !errors.Is(err, ErrInvalidSignature) {
+            t.Fatalf("%s: got %v, want ErrInvalidSignature", name, err)
+        }
+    }
+
+    // Links work until they expire
+    *now = now.Add(time.Minute)
+    if f, err := l.Open(key, expires, signature); err != nil {
+        t.Fatalf("at expiry: %v", err)
+    } else {
+        f.Close()
+    }
+    *now = now.Add(time.Second)
+    if _, err := l.Open(key, expires, signature); !errors.Is(err, ErrInvalidSignature) {
+        t.Fatalf("expired: got %v, want ErrInvalidSignature", err)
+    }
+}
+
+func TestLocalOpenMissingFile(t *testing.T) {
+    l, _ := newTestLocal(t)
+    expires, signature := link(t, l, "applications/1/missing.txt", time.Minute)
+    if _, err := l.Open("applications/1/missing.txt", expires, signature); !errors.Is(err, ErrNotFound) {
+        t.Fatalf("got %v, want ErrNotFound", err)
+    }
+}
+
+func TestLocalPathRejectsTraversal(t *testing.T) {
+    l, _ := newTestLocal(t)
+    for _, key := range []string{"", "/", "..", "../secret", "applications/../../etc/passwd", "applications/1/..", "a/..%2f/b/.."} {
+        if p, err := l.path(key); !errors.Is(err, ErrNotFound) {
+            t.Fatalf("path(%q) = %q, %v; want ErrNotFound", key, p, err)
+        }
+    }
+
+    // Absolute keys stay below the root
+    p, err := l.path("/applications/1/cv.pdf")
+    if err != nil || !strings.HasPrefix(p, l.root+"/") {
+        t.Fatalf("path of an absolute key: %q, %v", p, err)
+    }
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+const (
+    s3Algorithm     = "AWS4-HMAC-SHA256"
+    s3Service       = "s3"
+    unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3 keeps files in an S3 compatible bucket. Requests are signed with AWS
+// Signature Version 4 using credentials from the standard AWS_* environment
+// variables. When an endpoint is configured (MinIO, LocalStack) path-style
+// addressing is used, otherwise the bucket's virtual-hosted AWS endpoint.
+type S3 struct {
+    region       string
+    bucket       string
+    baseURL      *url.URL
+    pathStyle    bool
+    accessKey    string
+    secretKey    string
+    sessionToken string
+    client       *http.Client
+    now          func() time.Time
+}
+
+func NewS3(region, endpoint, bucket string) (*S3, error) {
+    if region == "" || bucket == "" {
+        return nil, errors.New("s3 storage needs AWS_REGION and S3_BUCKET")
+    }
+
+    s := &S3{
+        region:       region,
+        bucket:       bucket,
+        accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+        secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+        sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+        client:       &http.Client{Timeout: time.Minute},
+        now:          time.Now,
+    }
+    if s.accessKey == "" || s.secretKey == "" {
+        return nil, errors.New("s3 storage needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+    }
+
+    var err error
+    if endpoint != "" {
+        s.pathStyle = true
+        s.baseURL, err = url.Parse(endpoint)
+    } else {
+        s.baseURL, err = url.Parse(fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region))
+    }
+    if err != nil {
+        return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+    }
+
+    return s, nil
+}
+
+func (s *S3) objectURL(key string) *url.URL {
+    u := *s.baseURL
+    if s.pathStyle {
+        u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + key
+    } else {
+        u.Path = "/" + key
+    }
+    return &u
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), io.LimitReader(r, size))
+    if err != nil {
+        return err
+    }
+    req.ContentLength = size
+    req.Header.Set("Content-Type", contentType)
+
+    return s.do(req, key, http.StatusOK)
+}
+
+// Delete removes key. S3 answers 204 whether or not the object existed.
+func (s *S3) Delete(ctx context.Context, key string) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+    if err != nil {
+        return err
+    }
+
+    return s.do(req, key, http.StatusNoContent)
+}
+
+// do signs req with its headers and sends it, expecting the want status.
+func (s *S3) do(req *http.Request, key string, want int) error {
+    now := s.now().UTC()
+    req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+    req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+    if s.sessionToken != "" {
+        req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+    }
+
+    headers := map[string]string{"host": req.URL.Host}
+    for _, name := range []string{"Content-Type", "X-Amz-Date", "X-Amz-Content-Sha256", "X-Amz-Security-Token"} {
+        if value := req.Header.Get(name); value != "" {
+            headers[strings.ToLower(name)] = value
+        }
+    }
+
+    signedHeaders, signature := s.sign(req.Method, req.URL, url.Values{}, headers, now)
+    req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+        s3Algorithm, s.accessKey, s.scope(now), signedHeaders, signature))
+
+    resp, err := s.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != want {
+        body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+        return fmt.Errorf("s3 %s %s: %s: %s", strings.ToLower(req.Method), key, resp.Status, body)
+    }
+    return nil
+}
+
+// SignedURL returns a presigned GET URL for key.
+func (s *S3) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+    u := s.objectURL(key)
+    now := s.now().UTC()
+
+    query := url.Values{}
+    query.Set("X-Amz-Algorithm", s3Algorithm)
+    query.Set("X-Amz-Credential", s.accessKey+"/"+s.scope(now))
+    query.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+    query.Set("X-Amz-Expires", strconv.Itoa(int(expiry.Seconds())))
+    query.Set("X-Amz-SignedHeaders", "host")
+    if s.sessionToken != "" {
+        query.Set("X-Amz-Security-Token", s.sessionToken)
+    }
+
+    _, signature := s.sign(http.MethodGet, u, query, map[string]string{"host": u.Host}, now)
+    query.Set("X-Amz-Signature", signature)
+
+    u.RawQuery = canonicalQuery(query)
+    return u.String(), nil
+}
+
+func (s *S3) scope(t time.Time) string {
+    return t.Format("20060102") + "/" + s.region + "/" + s3Service + "/aws4_request"
+}
+
+// sign computes the SigV4 signature of a request whose payload is not signed.
+func (s *S3) sign(method string, u *url.URL, query url.Values, headers map[string]string, t time.Time) (string, string) {
+    names := make([]string, 0, len(headers))
+    for name := range headers {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    var canonicalHeaders strings.Builder
+    for _, name := range names {
+        canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+    }
+    signedHeaders := strings.Join(names, ";")
+
+    canonicalRequest := strings.Join([]string{
+        method,
+        uriEncode(u.Path, false),
+        canonicalQuery(query),
+        canonicalHeaders.String(),
+        signedHeaders,
+        unsignedPayload,
+    }, "\n")
+
+    hash := sha256.Sum256([]byte(canonicalRequest))
+    stringToSign := strings.Join([]string{
+        s3Algorithm,
+        t.Format("20060102T150405Z"),
+        s.scope(t),
+        hex.EncodeToString(hash[:]),
+    }, "\n")
+
+    key := hmacSHA256([]byte("AWS4"+s.secretKey), t.Format("20060102"))
+    key = hmacSHA256(key, s.region)
+    key = hmacSHA256(key, s3Service)
+    key = hmacSHA256(key, "aws4_request")
+
+    return signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+    mac := hmac.New(sha256.New, key)
+    mac.Write([]byte(data))
+    return mac.Sum(nil)
+}
+
+func canonicalQuery(query url.Values) string {
+    keys := make([]string, 0, len(query))
+    for k := range query {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+
+    parts := make([]string, 0, len(keys))
+    for _, k := range keys {
+        for _, v := range query[k] {
+            parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+        }
+    }
+    return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but RFC 3986 unreserved characters,
+// keeping '/' unless encodeSlash is set, as SigV4 requires.
+func uriEncode(s string, encodeSlash bool) string {
+    var b strings.Builder
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        switch {
+        case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+            c == '-', c == '_', c == '.', c == '~':
+            b.WriteByte(c)
+        case c == '/' && !encodeSlash:
+            b.WriteByte(c)
+        default:
+            fmt.Fprintf(&b, "%%%02X", c)
+        }
+    }
+    return b.String()
+}
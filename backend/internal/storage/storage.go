@@ -0,0 +1,76 @@
+package storage
+
+import (
+    "backend/internal/config"
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "path"
+    "regexp"
+    "strings"
+    "time"
+)
+
+var (
+    ErrNotFound         = errors.New("file not found")
+    ErrInvalidSignature = errors.New("invalid or expired download link")
+)
+
+// Storage keeps uploaded files, such as resumes, outside of the database.
+type Storage interface {
+    // Put stores size bytes read from r under key.
+    Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+    // SignedURL returns a link to key that stops working after expiry.
+    SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+    // Delete removes key, succeeding when it does not exist.
+    Delete(ctx context.Context, key string) error
+}
+
+var store Storage
+
+// Init sets up the storage backend selected in the config.
+func Init() {
+    cfg := config.GetConfig()
+
+    var err error
+    switch cfg.Storage.Backend {
+    case "s3":
+        store, err = NewS3(cfg.AWSRegion, cfg.AWSEndpoint, cfg.Storage.S3Bucket)
+    case "local":
+        store, err = NewLocal(cfg.Storage.LocalPath, cfg.Storage.SigningSecret)
+    default:
+        err = fmt.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+    }
+    if err != nil {
+        log.Fatalf("Unable to set up file storage: %v", err)
+    }
+
+    log.Printf("Using %s file storage", cfg.Storage.Backend)
+}
+
+// Get returns the storage backend.
+func Get() Storage {
+    return store
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// NewKey builds a unique key under prefix that keeps a sanitized version of the original filename.
+func NewKey(prefix, filename string) (string, error) {
+    random := make([]byte, 16)
+    if _, err := rand.Read(random); err != nil {
+        return "", err
+    }
+
+    name := unsafeChars.ReplaceAllString(path.Base(filename), "_")
+    name = strings.Trim(name, "._")
+    if name == "" {
+        name = "file"
+    }
+
+    return path.Join(prefix, hex.EncodeToString(random), name), nil
+}
@@ -0,0 +1,89 @@
+// Package totp implements time based one time passwords (RFC 6238) as used by
+// authenticator apps: HMAC-SHA1, 6 digits, 30 second steps.
+package totp
+
+import (
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha1"
+    "crypto/subtle"
+    "encoding/base32"
+    "encoding/binary"
+    "fmt"
+    "net/url"
+    "strings"
+    "time"
+)
+
+const (
+    Digits = 6
+    Period = 30 * time.Second
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random 160 bit secret, base32 encoded.
+func NewSecret() (string, error) {
+    secret := make([]byte, 20)
+    if _, err := rand.Read(secret); err != nil {
+        return "", err
+    }
+    return encoding.EncodeToString(secret), nil
+}
+
+// Step returns the time step t falls in.
+func Step(t time.Time) int64 {
+    return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns the code of secret for a time step.
+func Code(secret string, step int64) (string, error) {
+    key, err := encoding.DecodeString(strings.ToUpper(secret))
+    if err != nil {
+        return "", fmt.Errorf("invalid totp secret: %w", err)
+    }
+
+    var msg [8]byte
+    binary.BigEndian.PutUint64(msg[:], uint64(step))
+    mac := hmac.New(sha1.New, key)
+    mac.Write(msg[:])
+    sum := mac.Sum(nil)
+
+    // Dynamic truncation, RFC 4226 section 5.3
+    offset := sum[len(sum)-1] & 0x0f
+    value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+    return fmt.Sprintf("%0*d", Digits, value%1000000), nil
+}
+
+// Validate checks code against the steps around t, allowing skew steps of clock
+// drift either way, and returns the step it matched.
+func Validate(secret, code string, t time.Time, skew int64) (int64, bool) {
+    if len(code) != Digits {
+        return 0, false
+    }
+
+    now := Step(t)
+    for step := now - skew; step <= now+skew; step++ {
+        expected, err := Code(secret, step)
+        if err != nil {
+            return 0, false
+        }
+        if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+            return step, true
+        }
+    }
+    return 0, false
+}
+
+// URI returns the otpauth URI authenticator apps read, usually from a QR code.
+func URI(issuer, account, secret string) string {
+    label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+    query := url.Values{
+        "secret":    {secret},
+        "issuer":    {issuer},
+        "algorithm": {"SHA1"},
+        "digits":    {fmt.Sprint(Digits)},
+        "period":    {fmt.Sprint(int(Period / time.Second))},
+    }
+    return "otpauth://totp/" + label + "?" + query.Encode()
+}
@@ -0,0 +1,76 @@
+package totp
+
+import (
+    "strings"
+    "testing"
+    "time"
+)
+
+// The SHA1 secret of RFC 6238 appendix B, "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+    // Appendix B lists 8 digit codes, these are their last 6 digits
+    for _, tc := range []struct {
+        unix int64
+        want string
+    }{
+        {59, "287082"},
+        {1111111109, "081804"},
+        {1111111111, "050471"},
+        {1234567890, "005924"},
+        {2000000000, "279037"},
+        {20000000000, "353130"},
+    } {
+        got, err := Code(rfc6238Secret, Step(time.Unix(tc.unix, 0)))
+        if err != nil || got != tc.want {
+            t.Fatalf("T=%d: got %q (%v), want %q", tc.unix, got, err, tc.want)
+        }
+    }
+
+    // Secrets are accepted as apps show them, in lower case too
+    if got, _ := Code(strings.ToLower(rfc6238Secret), Step(time.Unix(59, 0))); got != "287082" {
+        t.Fatalf("lower case secret: got %q", got)
+    }
+}
+
+func TestValidate(t *testing.T) {
+    now := time.Unix(1111111111, 0)
+    step := Step(now)
+
+    for _, tc := range []struct {
+        offset int64
+        ok     bool
+    }{
+        {-2, false}, {-1, true}, {0, true}, {1, true}, {2, false},
+    } {
+        code, _ := Code(rfc6238Secret, step+tc.offset)
+        matched, ok := Validate(rfc6238Secret, code, now, 1)
+        if ok != tc.ok || (ok && matched != step+tc.offset) {
+            t.Fatalf("offset %d: step %d, %v", tc.offset, matched, ok)
+        }
+    }
+
+    for _, code := range []string{"", "50471", "0504711", "abcdef"} {
+        if _, ok := Validate(rfc6238Secret, code, now, 1); ok {
+            t.Fatalf("code %q accepted", code)
+        }
+    }
+    if _, ok := Validate("not base32!", "050471", now, 1); ok {
+        t.Fatal("code accepted for an invalid secret")
+    }
+}
+
+func TestNewSecret(t *testing.T) {
+    secret, err := NewSecret()
+    if err != nil {
+        t.Fatal(err)
+    }
+    // 160 bits are 32 base32 characters
+    if len(secret) != 32 {
+        t.Fatalf("secret %q", secret)
+    }
+    if _, err := Code(secret, 1); err != nil {
+        t.Fatalf("new secret does not decode: %v", err)
+    }
+}
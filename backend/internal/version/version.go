@@ -0,0 +1,60 @@
+// Package version describes the running build. Commit and BuildTime are set when
+// building a release:
+//
+//	go build -ldflags "-X backend/internal/version.Commit=$(git rev-parse HEAD) \
+//	    -X backend/internal/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/server
+//
+// Without them they fall back to the VCS information Go embeds in the binary.
+package version
+
+import (
+    "runtime"
+    "runtime/debug"
+    "sync"
+)
+
+var (
+    Commit    string
+    BuildTime string
+)
+
+// Info describes the build.
+type Info struct {
+    Commit    string `json:"commit"`
+    BuildTime string `json:"build_time,omitempty"`
+    Modified  bool   `json:"modified,omitempty"` // built from a tree with uncommitted changes
+    GoVersion string `json:"go_version"`
+}
+
+var (
+    info     Info
+    infoOnce sync.Once
+)
+
+// Get returns the build information, "unknown" as commit when none is available.
+func Get() Info {
+    infoOnce.Do(func() {
+        info = Info{Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+
+        if build, ok := debug.ReadBuildInfo(); ok {
+            for _, setting := range build.Settings {
+                switch setting.Key {
+                case "vcs.revision":
+                    if info.Commit == "" {
+                        info.Commit = setting.Value
+                    }
+                case "vcs.time":
+                    if info.BuildTime == "" {
+                        info.BuildTime = setting.Value
+                    }
+                case "vcs.modified":
+                    info.Modified = setting.Value == "true"
+                }
+            }
+        }
+        if info.Commit == "" {
+            info.Commit = "unknown"
+        }
+    })
+    return info
+}